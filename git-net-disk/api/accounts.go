@@ -0,0 +1,558 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/auth"
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/proxy"
+	"git-net-disk/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// localUserKey 本地账户在 gin.Context 中的键
+const localUserKey = "localUser"
+
+// 主目录的创建方式
+const (
+	// homeModeRepo 每个账户一个私有仓库
+	homeModeRepo = "repo"
+	// homeModeFolder 所有账户共用一个仓库，每人一个 home/<username> 目录
+	homeModeFolder = "folder"
+)
+
+// homeConfig 新账户主目录的配置
+type homeConfig struct {
+	mode string
+	// repoPrefix 仓库模式下仓库名的前缀
+	repoPrefix string
+	// org 仓库模式下在该组织中创建仓库，为空时创建在服务器 token 的用户名下
+	org string
+	// owner、repo 目录模式下共用的仓库
+	owner string
+	repo  string
+}
+
+// newHomeConfigFromEnv 读取 HOME_MODE、HOME_REPO_PREFIX、HOME_REPO_ORG 和 HOME_REPO
+func newHomeConfigFromEnv() (homeConfig, error) {
+	config := homeConfig{
+		mode:       os.Getenv("HOME_MODE"),
+		repoPrefix: os.Getenv("HOME_REPO_PREFIX"),
+		org:        os.Getenv("HOME_REPO_ORG"),
+	}
+	if config.mode == "" {
+		config.mode = homeModeRepo
+	}
+	if config.repoPrefix == "" {
+		config.repoPrefix = "home-"
+	}
+
+	switch config.mode {
+	case homeModeRepo:
+	case homeModeFolder:
+		config.owner, config.repo = github.ParseRepoPath(os.Getenv("HOME_REPO"))
+		if config.owner == "" {
+			return config, fmt.Errorf("HOME_REPO must be set to owner/repo when HOME_MODE=folder")
+		}
+	default:
+		return config, fmt.Errorf("unsupported HOME_MODE: %s", config.mode)
+	}
+	return config, nil
+}
+
+// newUserStoreFromEnv 打开 USERS_FILE 指向的账户数据库，未设置时返回 nil
+// 账户为空且设置了 ADMIN_USERNAME、ADMIN_PASSWORD 时创建初始管理员
+func newUserStoreFromEnv() (*auth.UserStore, error) {
+	path := os.Getenv("USERS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+
+	users, err := auth.NewUserStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if username := os.Getenv("ADMIN_USERNAME"); username != "" && users.Len() == 0 {
+		if _, err := users.Create(username, os.Getenv("ADMIN_PASSWORD"), auth.RoleAdmin, auth.Grant{}); err != nil {
+			return nil, fmt.Errorf("failed to create initial admin: %v", err)
+		}
+		fmt.Printf("[INFO] Created initial admin account %s\n", username)
+	}
+	return users, nil
+}
+
+// accountView 返回给客户端的账户信息，不包含密码哈希
+type accountView struct {
+	Username  string       `json:"username"`
+	Role      string       `json:"role"`
+	Home      *auth.Grant  `json:"home,omitempty"`
+	Grants    []auth.Grant `json:"grants"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// newAccountView 去掉账户中的敏感字段
+func newAccountView(user *auth.User) accountView {
+	view := accountView{
+		Username:  user.Username,
+		Role:      user.Role,
+		Grants:    user.Grants,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+	if user.Home.Repo != "" {
+		home := user.Home
+		view.Home = &home
+	}
+	if view.Grants == nil {
+		view.Grants = []auth.Grant{}
+	}
+	return view
+}
+
+// localUserFromContext 返回发起请求的本地账户，不是本地账户时返回 nil
+func localUserFromContext(c *gin.Context) *auth.User {
+	if value, ok := c.Get(localUserKey); ok {
+		return value.(*auth.User)
+	}
+	return nil
+}
+
+// localAccountMiddleware 识别本地账户的会话，并把成员限制在自己的主目录和被授权的目录中
+func (p *backendProvider) localAccountMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p.users == nil {
+			c.Next()
+			return
+		}
+
+		// API key 中间件可能已识别出本地账户
+		user := localUserFromContext(c)
+		if user == nil {
+			session, ok := p.localSession(c)
+			if !ok {
+				// 本地 git 存储没有其他认证方式，启用账户后必须先登录
+				if p.local != nil && apiKeyFromContext(c) == nil && !strings.HasPrefix(c.FullPath(), "/api/auth/") {
+					c.AbortWithStatusJSON(401, gin.H{"error": "Sign in required"})
+					return
+				}
+				c.Next()
+				return
+			}
+
+			var err error
+			user, err = p.users.Get(session.Login)
+			if err != nil {
+				c.AbortWithStatusJSON(401, gin.H{"error": "Account no longer exists, please sign in again"})
+				return
+			}
+			c.Set(localUserKey, user)
+		}
+
+		if user.Role != auth.RoleAdmin && !memberMayAccess(c, user) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Your account does not have access to this location"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// localSession 返回请求携带的本地账户会话
+func (p *backendProvider) localSession(c *gin.Context) (*auth.Session, bool) {
+	id, err := c.Cookie(sessionCookie)
+	if err != nil || id == "" {
+		return nil, false
+	}
+	session, _, err := p.sessions.Get(id)
+	if err != nil || !session.Local {
+		return nil, false
+	}
+	return session, true
+}
+
+// memberMayAccess 检查成员能否访问当前路由
+// 文件接口按路径检查授权，仓库级别的写操作需要整个仓库的写授权，仓库管理和组织功能只对管理员开放
+func memberMayAccess(c *gin.Context, user *auth.User) bool {
+	route := c.FullPath()
+	write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead
+	owner, repo := c.Param("owner"), c.Param("repo")
+
+	switch {
+	case strings.HasPrefix(route, "/api/auth/"), strings.HasPrefix(route, "/api/keys"):
+		// API key 的作用范围在使用时还要受账户授权限制
+		return true
+	case route == "/api/user" || route == "/api/repos" || route == "/api/quota":
+		// 仓库列表在处理器中按授权过滤
+		return !write
+	case strings.HasPrefix(route, "/api/audit"):
+		// 成员只能查看自己的审计记录
+		return !write
+	case isFileRoute(route):
+		// 授权检查和处理器必须看到同一个路径，否则 home/../other 能绕过目录授权
+		if !cleanPathParam(c) {
+			return false
+		}
+		return user.Allowed(owner, repo, c.Param("path"), write)
+	case strings.HasPrefix(route, "/api/snapshots/"),
+		route == "/api/repos/:owner/:repo/drive":
+		return user.Allowed(owner, repo, "", write)
+	}
+	return false
+}
+
+// cleanPathParam 规范化 *path 参数并写回 c.Params，处理器随后读到的就是授权检查用的路径
+// 路径含 .. 段或 owner、repo 为 . 或 .. 时返回 false
+func cleanPathParam(c *gin.Context) bool {
+	for i, param := range c.Params {
+		switch param.Key {
+		case "owner", "repo":
+			if param.Value == "." || param.Value == ".." {
+				return false
+			}
+		case "path":
+			for _, segment := range strings.Split(param.Value, "/") {
+				if segment == ".." {
+					return false
+				}
+			}
+			// 保留开头的斜杠，与 Gin 的 *path 参数一致
+			c.Params[i].Value = path.Clean("/" + param.Value)
+		}
+	}
+	return true
+}
+
+// pathParamMiddleware 在访问控制之前规范化路径参数，拒绝含 .. 的路径
+func pathParamMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cleanPathParam(c) {
+			c.AbortWithStatusJSON(400, gin.H{"error": "Path must not contain . or .. segments"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// filterRepositoriesForUser 只保留本地成员有授权的仓库
+func filterRepositoriesForUser(user *auth.User, repos []github.Repository) []github.Repository {
+	filtered := make([]github.Repository, 0, len(repos))
+	for _, repo := range repos {
+		if user.CanSeeRepository(repo.Owner.Login, repo.Name) {
+			filtered = append(filtered, repo)
+		}
+	}
+	return filtered
+}
+
+// serverBackend 返回使用服务器 token 的存储后端，用于创建主目录
+func (p *backendProvider) serverBackend() (storage.Backend, error) {
+	if p.local != nil {
+		return p.local, nil
+	}
+	if p.serverToken != "" {
+		return github.NewClientWithEndpoints(p.serverToken, proxy.ProxyConfig{Enabled: false}, p.githubEndpoints)
+	}
+	if p.app != nil {
+		return github.NewAppClient(p.app, proxy.ProxyConfig{Enabled: false}, p.githubEndpoints)
+	}
+	return nil, fmt.Errorf("GITHUB_TOKEN is required for local accounts")
+}
+
+// AccountsHandler 本地账户管理的 API 处理器，仅管理员可用
+type AccountsHandler struct {
+	backends *backendProvider
+	home     homeConfig
+}
+
+// NewAccountsHandler 创建账户管理处理器，未启用本地账户时不读取主目录配置
+func NewAccountsHandler(backends *backendProvider) (*AccountsHandler, error) {
+	h := &AccountsHandler{backends: backends}
+	if backends.users == nil {
+		return h, nil
+	}
+
+	home, err := newHomeConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	h.home = home
+	return h, nil
+}
+
+// requireAdmin 检查请求来自本地管理员，否则已写入响应
+func (h *AccountsHandler) requireAdmin(c *gin.Context) bool {
+	if h.backends.users == nil {
+		c.JSON(501, gin.H{"error": "Local accounts are not enabled on this server"})
+		return false
+	}
+	user := localUserFromContext(c)
+	if user == nil {
+		c.JSON(401, gin.H{"error": "Sign in with a local admin account"})
+		return false
+	}
+	if user.Role != auth.RoleAdmin {
+		c.JSON(403, gin.H{"error": "Only admins can manage accounts"})
+		return false
+	}
+	return true
+}
+
+// ListAccounts 列出所有本地账户
+func (h *AccountsHandler) ListAccounts(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	users := h.backends.users.List()
+	views := make([]accountView, 0, len(users))
+	for i := range users {
+		views = append(views, newAccountView(&users[i]))
+	}
+
+	middleware.Success(c, views, "Accounts listed successfully")
+}
+
+// CreateAccount 创建本地账户并为其创建主目录
+func (h *AccountsHandler) CreateAccount(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	var req struct {
+		Username string       `json:"username" binding:"required"`
+		Password string       `json:"password" binding:"required"`
+		Role     string       `json:"role"` // admin 或 member，默认 member
+		Grants   []auth.Grant `json:"grants"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+	if req.Role == "" {
+		req.Role = auth.RoleMember
+	}
+	if err := auth.ValidateUsername(req.Username); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+	if err := auth.ValidateRole(req.Role); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+	for i := range req.Grants {
+		if err := auth.ValidateGrant(&req.Grants[i]); err != nil {
+			respondAccountError(c, err)
+			return
+		}
+	}
+	if _, err := h.backends.users.Get(req.Username); err == nil {
+		c.JSON(409, gin.H{"error": fmt.Sprintf("Account %s already exists", req.Username)})
+		return
+	}
+
+	home, err := h.createHome(c.Request.Context(), req.Username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.backends.users.Create(req.Username, req.Password, req.Role, home)
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+	if len(req.Grants) > 0 {
+		user, err = h.backends.users.Update(user.Username, func(user *auth.User) error {
+			user.Grants = req.Grants
+			return nil
+		})
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	middleware.Success(c, newAccountView(user), "Account created successfully")
+}
+
+// UpdateAccount 修改账户的密码、角色或授权
+func (h *AccountsHandler) UpdateAccount(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	var req struct {
+		Password *string       `json:"password"`
+		Role     *string       `json:"role"`
+		Grants   *[]auth.Grant `json:"grants"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+
+	username := c.Param("username")
+	if req.Password != nil {
+		if err := h.backends.users.SetPassword(username, *req.Password); err != nil {
+			respondAccountError(c, err)
+			return
+		}
+		// 修改密码后使已登录的会话失效
+		if err := h.backends.sessions.DeleteUser(username); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	user, err := h.backends.users.Update(username, func(user *auth.User) error {
+		if req.Role != nil {
+			if err := auth.ValidateRole(*req.Role); err != nil {
+				return err
+			}
+			user.Role = *req.Role
+		}
+		if req.Grants != nil {
+			for i := range *req.Grants {
+				if err := auth.ValidateGrant(&(*req.Grants)[i]); err != nil {
+					return err
+				}
+			}
+			user.Grants = *req.Grants
+		}
+		return nil
+	})
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	middleware.Success(c, newAccountView(user), "Account updated successfully")
+}
+
+// DeleteAccount 删除账户并注销其会话，主目录中的数据保留
+func (h *AccountsHandler) DeleteAccount(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	username := c.Param("username")
+	if current := localUserFromContext(c); current.Username == username {
+		c.JSON(400, gin.H{"error": "You cannot delete your own account"})
+		return
+	}
+
+	if err := h.backends.users.Delete(username); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+	if err := h.backends.sessions.DeleteUser(username); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := h.backends.apiKeys.DeleteOwner(username, true); err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"message": "Account deleted successfully"}, "Account deleted successfully")
+}
+
+// createHome 按配置为账户创建主目录
+func (h *AccountsHandler) createHome(ctx context.Context, username string) (auth.Grant, error) {
+	backend, err := h.backends.serverBackend()
+	if err != nil {
+		return auth.Grant{}, err
+	}
+
+	if h.home.mode == homeModeFolder {
+		path := "home/" + username
+		keep := path + "/.gitkeep"
+		sha, err := backend.GetFileSHA(ctx, h.home.owner, h.home.repo, keep, "")
+		if err != nil {
+			return auth.Grant{}, err
+		}
+		// git 不保存空目录，写入占位文件
+		if sha == "" {
+			if _, err := backend.CreateOrUpdateFile(ctx, h.home.owner, h.home.repo, keep, base64.StdEncoding.EncodeToString(nil), "Create home for "+username, "", ""); err != nil {
+				return auth.Grant{}, err
+			}
+		}
+		return auth.Grant{Owner: h.home.owner, Repo: h.home.repo, Path: path, Access: auth.AccessWrite}, nil
+	}
+
+	name := h.home.repoPrefix + username
+	description := "Home of " + username
+	client, isGitHub := backend.(*github.Client)
+	useOrg := isGitHub && h.home.org != ""
+
+	// 删除账户时保留主目录，重新创建同名账户时直接复用
+	var existing []github.Repository
+	if useOrg {
+		existing, err = client.ListOrgRepositories(ctx, h.home.org)
+	} else {
+		existing, err = backend.ListRepositories(ctx, github.RepoListOptions{Affiliation: "owner"})
+	}
+	if err != nil {
+		return auth.Grant{}, err
+	}
+	for _, repo := range existing {
+		if strings.EqualFold(repo.Name, name) {
+			return auth.Grant{Owner: repo.Owner.Login, Repo: repo.Name, Access: auth.AccessWrite}, nil
+		}
+	}
+
+	var repo *github.Repository
+	if useOrg {
+		repo, err = client.CreateOrgRepository(ctx, h.home.org, name, description, true, true)
+	} else {
+		repo, err = backend.CreateRepository(ctx, name, description, true, true)
+	}
+	if err != nil {
+		return auth.Grant{}, fmt.Errorf("failed to create home repository %s: %v", name, err)
+	}
+	return auth.Grant{Owner: repo.Owner.Login, Repo: repo.Name, Access: auth.AccessWrite}, nil
+}
+
+// respondAccountError 将账户存储的错误映射为对应的 HTTP 状态码
+func respondAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrUserExists):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrInvalidAccount):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.Error(err)
+	}
+}
+
+// RegisterAccountsRoutes 注册本地账户管理的路由
+func RegisterAccountsRoutes(router *gin.RouterGroup, backends *backendProvider) error {
+	handler, err := NewAccountsHandler(backends)
+	if err != nil {
+		return err
+	}
+
+	router.GET("/accounts", handler.ListAccounts)
+	router.POST("/accounts", handler.CreateAccount)
+	router.PATCH("/accounts/:username", handler.UpdateAccount)
+	router.DELETE("/accounts/:username", handler.DeleteAccount)
+
+	return nil
+}
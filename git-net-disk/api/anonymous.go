@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAnonymousRateLimit 每个 IP 每分钟允许的匿名请求数
+const defaultAnonymousRateLimit = 30
+
+// anonymousLimiter 按客户端 IP 限制匿名请求的令牌桶
+// 匿名请求共用服务器出口 IP 的 GitHub 配额（每小时 60 次），需要在服务端先行限流
+type anonymousLimiter struct {
+	mu      sync.Mutex
+	perMin  int
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket 单个客户端的令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newAnonymousLimiterFromEnv 读取 ANONYMOUS_RATE_LIMIT（每分钟请求数），为 0 时禁用匿名访问
+func newAnonymousLimiterFromEnv() (*anonymousLimiter, error) {
+	perMin := defaultAnonymousRateLimit
+	if v := os.Getenv("ANONYMOUS_RATE_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid ANONYMOUS_RATE_LIMIT: %s", v)
+		}
+		perMin = n
+	}
+	if perMin == 0 {
+		return nil, nil
+	}
+
+	return &anonymousLimiter{
+		perMin:  perMin,
+		buckets: make(map[string]*tokenBucket),
+	}, nil
+}
+
+// allow 消耗一个令牌，没有令牌时返回需要等待的时间
+func (l *anonymousLimiter) allow(ip string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	rate := float64(l.perMin) / 60 // 每秒补充的令牌数
+
+	bucket, ok := l.buckets[ip]
+	if !ok {
+		// 顺便清理已经补满的桶，避免表无限增长
+		if len(l.buckets) > 10000 {
+			for key, b := range l.buckets {
+				if now.Sub(b.last) > time.Minute {
+					delete(l.buckets, key)
+				}
+			}
+		}
+		bucket = &tokenBucket{tokens: float64(l.perMin), last: now}
+		l.buckets[ip] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * rate
+	if bucket.tokens > float64(l.perMin) {
+		bucket.tokens = float64(l.perMin)
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// admitAnonymous 检查匿名请求是否允许，不允许时已写入响应
+func (p *backendProvider) admitAnonymous(c *gin.Context) bool {
+	if p.anonymous == nil {
+		c.JSON(401, gin.H{"error": "Missing authentication token"})
+		return false
+	}
+
+	allowed, wait := p.anonymous.allow(c.ClientIP())
+	if !allowed {
+		seconds := int(wait.Seconds()) + 1
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(429, gin.H{
+			"error": fmt.Sprintf("Too many anonymous requests, retry in %d seconds or sign in with a token", seconds),
+		})
+		return false
+	}
+	return true
+}
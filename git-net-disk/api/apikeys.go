@@ -0,0 +1,277 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API key 在 gin.Context 中的键
+const (
+	apiKeyContextKey = "apiKey"
+	// apiKeyTokenKey key 对应的 GitHub token，本地账户的 key 为服务器 token
+	apiKeyTokenKey = "apiKeyToken"
+)
+
+// apiKeyFromContext 返回请求使用的 API key，没有使用时返回 nil
+func apiKeyFromContext(c *gin.Context) *auth.APIKey {
+	if value, ok := c.Get(apiKeyContextKey); ok {
+		return value.(*auth.APIKey)
+	}
+	return nil
+}
+
+// apiKeyFromHeader 从 X-API-Key 或 Authorization 头中取出 API key，不是 API key 时返回空字符串
+func apiKeyFromHeader(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader("X-API-Key")); key != "" {
+		return key
+	}
+	header := strings.TrimSpace(c.GetHeader("Authorization"))
+	header = strings.TrimPrefix(header, "Bearer ")
+	header = strings.TrimPrefix(header, "token ")
+	if strings.HasPrefix(header, auth.APIKeyPrefix) {
+		return header
+	}
+	return ""
+}
+
+// isFileRoute 检查路由是否为按路径访问文件的接口
+func isFileRoute(route string) bool {
+	return strings.HasPrefix(route, "/api/files/") ||
+		strings.HasPrefix(route, "/api/file/") ||
+		strings.HasPrefix(route, "/api/raw/") ||
+		strings.HasPrefix(route, "/api/history/")
+}
+
+// apiKeyMiddleware 识别 API key，并把 key 限制在文件接口上，具体的作用范围由文件处理器检查
+func (p *backendProvider) apiKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := apiKeyFromHeader(c)
+		if secret == "" {
+			c.Next()
+			return
+		}
+
+		key, token, err := p.apiKeys.Authenticate(secret, c.ClientIP())
+		if err != nil {
+			c.AbortWithStatusJSON(401, gin.H{"error": err.Error()})
+			return
+		}
+		if !isFileRoute(c.FullPath()) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "API keys can only be used with file endpoints"})
+			return
+		}
+
+		if key.Local {
+			if p.users == nil {
+				c.AbortWithStatusJSON(401, gin.H{"error": "Local accounts are not enabled on this server"})
+				return
+			}
+			user, err := p.users.Get(key.Owner)
+			if err != nil {
+				c.AbortWithStatusJSON(401, gin.H{"error": "The account that created this API key no longer exists"})
+				return
+			}
+			c.Set(localUserKey, user)
+			token = p.serverToken
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Set(apiKeyTokenKey, token)
+		c.Next()
+	}
+}
+
+// requireKeyScope 请求使用 API key 时检查 key 是否允许对路径执行操作，不允许时已写入响应
+func requireKeyScope(c *gin.Context, owner, repo, path, operation string) bool {
+	key := apiKeyFromContext(c)
+	if key == nil || key.Allows(owner, repo, path, operation) {
+		return true
+	}
+	c.JSON(403, gin.H{
+		"error":     fmt.Sprintf("API key %q does not allow %s access to %s/%s/%s", key.Name, operation, owner, repo, path),
+		"operation": operation,
+		"scopes":    key.Scopes,
+	})
+	return false
+}
+
+// apiKeyView 返回给客户端的 key 信息，不包含哈希和加密的 token
+type apiKeyView struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Hint       string          `json:"hint"`
+	Scopes     []auth.KeyScope `json:"scopes"`
+	Operations []string        `json:"operations"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ExpiresAt  *time.Time      `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time      `json:"last_used_at,omitempty"`
+	LastUsedIP string          `json:"last_used_ip,omitempty"`
+	// Key 明文，只在创建时返回一次
+	Key string `json:"key,omitempty"`
+}
+
+// newAPIKeyView 去掉 key 中的敏感字段
+func newAPIKeyView(key *auth.APIKey) apiKeyView {
+	return apiKeyView{
+		ID:         key.ID,
+		Name:       key.Name,
+		Hint:       key.Hint,
+		Scopes:     key.Scopes,
+		Operations: key.Operations,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+	}
+}
+
+// APIKeysHandler API key 管理的处理器
+type APIKeysHandler struct {
+	backends *backendProvider
+}
+
+// NewAPIKeysHandler 创建 API key 处理器
+func NewAPIKeysHandler(backends *backendProvider) *APIKeysHandler {
+	return &APIKeysHandler{
+		backends: backends,
+	}
+}
+
+// keyOwner 识别管理 key 的用户，本地账户返回用户名，GitHub 用户返回登录名和 token，失败时已写入响应
+func (h *APIKeysHandler) keyOwner(c *gin.Context) (owner string, local bool, token string, ok bool) {
+	if user := localUserFromContext(c); user != nil {
+		return user.Username, true, "", true
+	}
+	if h.backends.local != nil {
+		c.JSON(400, gin.H{"error": "API keys require a local account on this server"})
+		return "", false, "", false
+	}
+
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return "", false, "", false
+	}
+	token = h.backends.tokenFromRequest(c)
+	if token == "" {
+		c.JSON(401, gin.H{"error": "Sign in with a GitHub token to manage API keys"})
+		return "", false, "", false
+	}
+	user, err := client.GetUser(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return "", false, "", false
+	}
+	return user.Login, false, token, true
+}
+
+// ListKeys 列出当前用户创建的 API key
+func (h *APIKeysHandler) ListKeys(c *gin.Context) {
+	owner, local, _, ok := h.keyOwner(c)
+	if !ok {
+		return
+	}
+
+	keys := h.backends.apiKeys.List(owner, local)
+	views := make([]apiKeyView, 0, len(keys))
+	for i := range keys {
+		views = append(views, newAPIKeyView(&keys[i]))
+	}
+
+	middleware.Success(c, views, "API keys listed successfully")
+}
+
+// CreateKey 签发 API key，明文只在响应中出现一次
+// 请求体: name, scopes [{owner, repo, path}], operations [read, write, delete], expires_at 或 expires_in_days
+func (h *APIKeysHandler) CreateKey(c *gin.Context) {
+	var req struct {
+		Name          string          `json:"name" binding:"required"`
+		Scopes        []auth.KeyScope `json:"scopes"`
+		Operations    []string        `json:"operations"`
+		ExpiresAt     *time.Time      `json:"expires_at"`
+		ExpiresInDays int             `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+	if err := auth.ValidateKeyScopes(req.Scopes, req.Operations); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	expiresAt := req.ExpiresAt
+	if expiresAt == nil && req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		c.JSON(400, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	owner, local, token, ok := h.keyOwner(c)
+	if !ok {
+		return
+	}
+
+	// 本地成员只能为自己有权访问的位置签发 key
+	if user := localUserFromContext(c); user != nil {
+		for _, scope := range req.Scopes {
+			if !user.Allowed(scope.Owner, scope.Repo, scope.Path, false) {
+				c.JSON(403, gin.H{"error": fmt.Sprintf("Your account does not have access to %s/%s/%s", scope.Owner, scope.Repo, scope.Path)})
+				return
+			}
+		}
+	}
+
+	key, secret, err := h.backends.apiKeys.Create(req.Name, owner, local, token, req.Scopes, req.Operations, expiresAt)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	view := newAPIKeyView(key)
+	view.Key = secret
+	middleware.Success(c, view, "API key created successfully")
+}
+
+// DeleteKey 撤销 API key
+func (h *APIKeysHandler) DeleteKey(c *gin.Context) {
+	owner, local, _, ok := h.keyOwner(c)
+	if !ok {
+		return
+	}
+
+	if err := h.backends.apiKeys.Delete(c.Param("id"), owner, local); err != nil {
+		if errors.Is(err, auth.ErrAPIKeyNotFound) {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"message": "API key revoked successfully"}, "API key revoked successfully")
+}
+
+// RegisterAPIKeysRoutes 注册 API key 管理的路由
+func RegisterAPIKeysRoutes(router *gin.RouterGroup, backends *backendProvider) error {
+	handler := NewAPIKeysHandler(backends)
+
+	router.GET("/keys", handler.ListKeys)
+	router.POST("/keys", handler.CreateKey)
+	router.DELETE("/keys/:id", handler.DeleteKey)
+
+	return nil
+}
@@ -0,0 +1,375 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/audit"
+	"git-net-disk/internal/auth"
+	"git-net-disk/internal/github"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 审计信息在 gin.Context 中的键，处理器可以补充中间件无法从路由参数得到的信息
+const (
+	auditOwnerKey = "auditOwner"
+	auditRepoKey  = "auditRepo"
+	auditLoginKey = "auditLogin"
+)
+
+// auditLookupTimeout 查询操作者 GitHub 登录名的时限
+const auditLookupTimeout = 10 * time.Second
+
+// auditActions 需要记录的写操作，按 "方法 路由" 索引，值为空表示不记录
+var auditActions = map[string]string{
+	"PUT /api/file/:owner/:repo/*path":    "file.write",
+	"DELETE /api/file/:owner/:repo/*path": "file.delete",
+	"PUT /api/raw/:owner/:repo/*path":     "file.upload",
+
+	"POST /api/repos":                     "repo.create",
+	"PATCH /api/repos/:owner/:repo":       "repo.update",
+	"DELETE /api/repos/:owner/:repo":      "repo.delete",
+	"PUT /api/repos/:owner/:repo/topics":  "repo.topics",
+	"POST /api/repos/:owner/:repo/topics": "repo.topics",
+	"PUT /api/repos/:owner/:repo/drive":   "drive.update",
+	"POST /api/orgs/:org/repos":           "repo.create",
+
+	"POST /api/snapshots/:owner/:repo":               "snapshot.create",
+	"POST /api/snapshots/:owner/:repo/:name/restore": "snapshot.restore",
+
+	"PUT /api/repos/:owner/:repo/collaborators/:username":    "share.create",
+	"DELETE /api/repos/:owner/:repo/collaborators/:username": "share.delete",
+	"POST /api/invitations/:id/accept":                       "invitation.accept",
+	"DELETE /api/invitations/:id":                            "invitation.decline",
+	"PUT /api/orgs/:org/teams/:team/repos/:repo":             "team.grant",
+	"DELETE /api/orgs/:org/teams/:team/repos/:repo":          "team.revoke",
+
+	"POST /api/accounts":                "account.create",
+	"PATCH /api/accounts/:username":     "account.update",
+	"DELETE /api/accounts/:username":    "account.delete",
+	"POST /api/keys":                    "key.create",
+	"DELETE /api/keys/:id":              "key.delete",
+	"POST /api/auth/local/login":        "auth.login",
+	"PUT /api/auth/local/password":      "auth.password",
+	"POST /api/auth/logout":             "auth.logout",
+	"POST /api/auth/github/device":      "",
+	"POST /api/auth/github/device/poll": "",
+}
+
+// auditLogin 解析出的操作者身份
+type auditLogin struct {
+	login  string
+	actor  string
+	apiKey string
+}
+
+// auditor 审计日志及其辅助状态
+type auditor struct {
+	log *audit.Log
+	// logins 按 token 哈希缓存的 GitHub 登录名，避免每次写操作都多请求一次 /user
+	logins sync.Map
+}
+
+// newAuditorFromEnv 根据 AUDIT_LOG_FILE 打开审计日志，默认为 ./data/audit.jsonl，设置为 off 时禁用
+func newAuditorFromEnv() (*auditor, error) {
+	path := os.Getenv("AUDIT_LOG_FILE")
+	if path == "off" {
+		return nil, nil
+	}
+	if path == "" {
+		path = "./data/audit.jsonl"
+	}
+
+	log, err := audit.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditor{log: log}, nil
+}
+
+// adminsFromEnv 读取 AUDIT_ADMINS，逗号分隔的 GitHub 登录名，本地管理员总是管理员
+func adminsFromEnv() map[string]bool {
+	admins := make(map[string]bool)
+	for _, login := range strings.Split(os.Getenv("AUDIT_ADMINS"), ",") {
+		if login = strings.TrimSpace(login); login != "" {
+			admins[strings.ToLower(login)] = true
+		}
+	}
+	return admins
+}
+
+// setAuditTarget 记录操作的目标仓库，用于路由参数中没有仓库的接口，例如创建仓库，空字符串表示未知
+func setAuditTarget(c *gin.Context, owner, repo string) {
+	c.Set(auditOwnerKey, owner)
+	c.Set(auditRepoKey, repo)
+}
+
+// setAuditLogin 记录尚未登录的操作者，例如登录请求中的用户名
+func setAuditLogin(c *gin.Context, login string) {
+	c.Set(auditLoginKey, login)
+}
+
+// auditMiddleware 在写操作完成后追加审计记录，需在其他访问控制中间件之前添加，以便记录被拒绝的请求
+func (p *backendProvider) auditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if p.audit == nil {
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return
+		}
+
+		route := c.FullPath()
+		action, ok := auditActions[c.Request.Method+" "+route]
+		if ok && action == "" {
+			return
+		}
+		if !ok {
+			action = c.Request.Method + " " + route
+		}
+
+		entry := audit.Entry{
+			Time:      time.Now().UTC(),
+			RequestID: c.GetString("requestId"),
+			IP:        c.ClientIP(),
+			Action:    action,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Owner:     c.Param("owner"),
+			Repo:      c.Param("repo"),
+			File:      strings.Trim(c.Param("path"), "/"),
+		}
+		if org := c.Param("org"); org != "" {
+			entry.Owner = org
+		}
+		if owner := c.GetString(auditOwnerKey); owner != "" {
+			entry.Owner = owner
+		}
+		if repo := c.GetString(auditRepoKey); repo != "" {
+			entry.Repo = repo
+		}
+
+		identity := p.auditIdentity(c)
+		entry.Login, entry.Actor, entry.APIKey = identity.login, identity.actor, identity.apiKey
+
+		// 处理器通过 c.Error 返回的错误由外层的错误中间件写入响应，此时还没有状态码
+		entry.Status = c.Writer.Status()
+		if last := c.Errors.Last(); last != nil {
+			entry.Error = last.Error()
+			if !c.Writer.Written() {
+				entry.Status = middleware.StatusForError(last.Err)
+			}
+		}
+		entry.Result = audit.ResultSuccess
+		if entry.Status >= 400 {
+			entry.Result = audit.ResultFailure
+		}
+
+		if err := p.audit.log.Append(entry); err != nil {
+			fmt.Printf("[WARN] Failed to write audit log: %v\n", err)
+		}
+	}
+}
+
+// auditIdentity 识别发起请求的用户
+func (p *backendProvider) auditIdentity(c *gin.Context) auditLogin {
+	if key := apiKeyFromContext(c); key != nil {
+		return auditLogin{login: key.Owner, actor: "api_key", apiKey: key.ID}
+	}
+	if user := localUserFromContext(c); user != nil {
+		return auditLogin{login: user.Username, actor: "local"}
+	}
+	if login := c.GetString(auditLoginKey); login != "" {
+		return auditLogin{login: login, actor: "local"}
+	}
+	if id, err := c.Cookie(sessionCookie); err == nil && id != "" {
+		if session, _, err := p.sessions.Get(id); err == nil && !session.Local {
+			return auditLogin{login: session.Login, actor: "github"}
+		}
+	}
+	if token := getTokenFromHeader(c); token != "" && p.local == nil {
+		return auditLogin{login: p.githubLogin(c, token), actor: "github"}
+	}
+	return auditLogin{actor: "anonymous"}
+}
+
+// githubLogin 查询 token 对应的 GitHub 登录名，结果按 token 哈希缓存，查询失败时返回空字符串
+func (p *backendProvider) githubLogin(c *gin.Context, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	cacheKey := hex.EncodeToString(sum[:])
+	if login, ok := p.audit.logins.Load(cacheKey); ok {
+		return login.(string)
+	}
+
+	account, err := p.accountFromRequest(c)
+	if err != nil || account.Type != backendGitHub {
+		return ""
+	}
+	client, err := github.NewClientWithEndpoints(token, getProxyConfigFromHeader(c), p.githubEndpointsFor(account))
+	if err != nil {
+		return ""
+	}
+	// 审计在处理器返回后执行，请求可能已超时或被客户端取消
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), auditLookupTimeout)
+	defer cancel()
+	user, err := client.GetUser(ctx)
+	if err != nil {
+		return ""
+	}
+	p.audit.logins.Store(cacheKey, user.Login)
+	return user.Login
+}
+
+// AuditHandler 审计日志查询的处理器
+type AuditHandler struct {
+	backends *backendProvider
+}
+
+// NewAuditHandler 创建审计日志处理器
+func NewAuditHandler(backends *backendProvider) *AuditHandler {
+	return &AuditHandler{
+		backends: backends,
+	}
+}
+
+// filterFromRequest 根据查询参数构造过滤条件，普通用户只能查看自己的记录，失败时已写入响应
+// 查询参数: login, action, owner, repo, path, result, since, until
+func (h *AuditHandler) filterFromRequest(c *gin.Context) (audit.Filter, bool) {
+	if h.backends.audit == nil {
+		c.JSON(501, gin.H{"error": "Audit logging is disabled on this server"})
+		return audit.Filter{}, false
+	}
+
+	since, err := parseTimeParam(c, "since")
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return audit.Filter{}, false
+	}
+	until, err := parseTimeParam(c, "until")
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return audit.Filter{}, false
+	}
+	result := c.Query("result")
+	if result != "" && result != audit.ResultSuccess && result != audit.ResultFailure {
+		c.JSON(400, gin.H{"error": "result must be success or failure"})
+		return audit.Filter{}, false
+	}
+
+	filter := audit.Filter{
+		Login:  c.Query("login"),
+		Action: c.Query("action"),
+		Owner:  c.Query("owner"),
+		Repo:   c.Query("repo"),
+		File:   c.Query("path"),
+		Result: result,
+		Since:  since,
+		Until:  until,
+	}
+
+	login, all, ok := h.backends.viewer(c)
+	if !ok {
+		return audit.Filter{}, false
+	}
+	if !all {
+		if filter.Login != "" && !strings.EqualFold(filter.Login, login) {
+			c.JSON(403, gin.H{"error": "You can only view your own audit entries"})
+			return audit.Filter{}, false
+		}
+		filter.Login = login
+	}
+	return filter, true
+}
+
+// viewer 识别查看审计日志或服务器状态的用户，失败时已写入响应
+// admin 为 true 表示服务器管理员：本地管理员、没有本地账户时的本地存储所有者，或 AUDIT_ADMINS 中的 GitHub 用户
+func (p *backendProvider) viewer(c *gin.Context) (login string, admin bool, ok bool) {
+	if user := localUserFromContext(c); user != nil {
+		return user.Username, user.Role == auth.RoleAdmin, true
+	}
+	if p.local != nil {
+		// 没有本地账户的本地存储只有服务器所有者一个用户
+		return "", true, true
+	}
+
+	client, ok := p.githubClientFromRequest(c)
+	if !ok {
+		return "", false, false
+	}
+	if p.tokenFromRequest(c) == "" {
+		c.JSON(401, gin.H{"error": "Sign in to continue"})
+		return "", false, false
+	}
+	user, err := client.GetUser(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return "", false, false
+	}
+	return user.Login, p.admins[strings.ToLower(user.Login)], true
+}
+
+// ListEntries 按条件查询审计记录，最新的在前，支持分页
+func (h *AuditHandler) ListEntries(c *gin.Context) {
+	filter, ok := h.filterFromRequest(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.backends.audit.log.Query(filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	page, ok := paginate(c, entries)
+	if !ok {
+		return
+	}
+	middleware.Success(c, page, "Audit entries listed successfully")
+}
+
+// ExportEntries 以 JSON Lines 格式按时间顺序导出满足条件的审计记录
+func (h *AuditHandler) ExportEntries(c *gin.Context) {
+	filter, ok := h.filterFromRequest(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.jsonl"`, time.Now().UTC().Format("20060102-150405")))
+	c.Status(200)
+
+	encoder := json.NewEncoder(c.Writer)
+	err := h.backends.audit.log.Each(filter, func(entry *audit.Entry) bool {
+		return encoder.Encode(entry) == nil
+	})
+	if err != nil {
+		// 响应头已发送，只能记录错误
+		fmt.Printf("[WARN] Failed to export audit log: %v\n", err)
+	}
+}
+
+// RegisterAuditRoutes 注册审计日志的路由
+func RegisterAuditRoutes(router *gin.RouterGroup, backends *backendProvider) error {
+	handler := NewAuditHandler(backends)
+
+	router.GET("/audit", handler.ListEntries)
+	router.GET("/audit/export", handler.ExportEntries)
+
+	return nil
+}
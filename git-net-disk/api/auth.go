@@ -0,0 +1,387 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/auth"
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 会话相关的 cookie 名称
+const (
+	sessionCookie    = "gnd_session"
+	oauthStateCookie = "gnd_oauth_state"
+)
+
+// defaultSessionTTL 会话默认有效期
+const defaultSessionTTL = 30 * 24 * time.Hour
+
+// AuthHandler GitHub OAuth 登录和会话相关的 API 处理器
+type AuthHandler struct {
+	backends *backendProvider
+	// oauth 未配置 GITHUB_OAUTH_CLIENT_ID 时为 nil
+	oauth       *github.OAuthApp
+	redirectURL string
+	successURL  string
+	scopes      []string
+	// crossSite 为 true 时 cookie 使用 SameSite=None，供跨站部署的前端使用
+	crossSite bool
+}
+
+// sessionSecretFromEnv 读取加密 token 的 SESSION_SECRET，未设置时生成随机值
+// 会话或 API key 需要持久化时必须设置
+func sessionSecretFromEnv() (string, error) {
+	if secret := os.Getenv("SESSION_SECRET"); secret != "" {
+		return secret, nil
+	}
+	if os.Getenv("SESSION_STORE_FILE") != "" {
+		return "", fmt.Errorf("SESSION_SECRET is required when SESSION_STORE_FILE is set")
+	}
+	if os.Getenv("API_KEYS_FILE") != "" {
+		return "", fmt.Errorf("SESSION_SECRET is required when API_KEYS_FILE is set")
+	}
+	fmt.Println("[WARN] SESSION_SECRET is not set, sessions will not survive a restart")
+	return auth.RandomString(32)
+}
+
+// newSessionStoreFromEnv 根据 SESSION_STORE_FILE、SESSION_TTL 创建会话存储
+func newSessionStoreFromEnv(secret string) (*auth.SessionStore, error) {
+	ttl := defaultSessionTTL
+	if v := os.Getenv("SESSION_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SESSION_TTL: %v", err)
+		}
+		ttl = d
+	}
+
+	return auth.NewSessionStore(secret, os.Getenv("SESSION_STORE_FILE"), ttl)
+}
+
+// NewAuthHandler 创建登录处理器，OAuth App 通过 GITHUB_OAUTH_* 环境变量配置
+func NewAuthHandler(backends *backendProvider) (*AuthHandler, error) {
+	h := &AuthHandler{
+		backends:    backends,
+		redirectURL: os.Getenv("GITHUB_OAUTH_REDIRECT_URL"),
+		successURL:  os.Getenv("OAUTH_SUCCESS_URL"),
+		scopes:      strings.Fields(strings.ReplaceAll(os.Getenv("GITHUB_OAUTH_SCOPES"), ",", " ")),
+		crossSite:   os.Getenv("SESSION_COOKIE_CROSS_SITE") == "true",
+	}
+	if h.successURL == "" {
+		h.successURL = "/"
+	}
+	if len(h.scopes) == 0 {
+		h.scopes = []string{"repo", "read:org"}
+	}
+
+	if clientID := os.Getenv("GITHUB_OAUTH_CLIENT_ID"); clientID != "" {
+		app, err := github.NewOAuthApp(clientID, os.Getenv("GITHUB_OAUTH_CLIENT_SECRET"), backends.githubEndpoints)
+		if err != nil {
+			return nil, err
+		}
+		h.oauth = app
+	}
+	return h, nil
+}
+
+// requireOAuth 检查 OAuth App 是否已配置，未配置时已写入响应
+func (h *AuthHandler) requireOAuth(c *gin.Context) bool {
+	if h.oauth == nil {
+		c.JSON(501, gin.H{"error": "GitHub OAuth is not configured on this server"})
+		return false
+	}
+	return true
+}
+
+// Login 网页授权流程：跳转到 GitHub 授权页面
+func (h *AuthHandler) Login(c *gin.Context) {
+	if !h.requireOAuth(c) {
+		return
+	}
+
+	state, err := auth.RandomString(16)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.setCookie(c, oauthStateCookie, state, 10*time.Minute)
+
+	c.Redirect(http.StatusFound, h.oauth.AuthorizeURL(state, h.redirectURL, h.scopes))
+}
+
+// Callback 网页授权流程：GitHub 回调，换取 token 后创建会话
+func (h *AuthHandler) Callback(c *gin.Context) {
+	if !h.requireOAuth(c) {
+		return
+	}
+
+	state, err := c.Cookie(oauthStateCookie)
+	if err != nil || state == "" || state != c.Query("state") {
+		c.JSON(400, gin.H{"error": "Invalid OAuth state"})
+		return
+	}
+	h.setCookie(c, oauthStateCookie, "", -1)
+
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(400, gin.H{"error": "GitHub authorization failed: " + errCode})
+		return
+	}
+
+	token, err := h.oauth.ExchangeCode(c.Request.Context(), c.Query("code"), h.redirectURL)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if _, err := h.startSession(c, token.AccessToken); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.successURL)
+}
+
+// StartDeviceFlow 设备授权流程：申请用户码
+func (h *AuthHandler) StartDeviceFlow(c *gin.Context) {
+	if !h.requireOAuth(c) {
+		return
+	}
+
+	code, err := h.oauth.RequestDeviceCode(c.Request.Context(), h.scopes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, code, "Device code issued successfully")
+}
+
+// PollDeviceFlow 设备授权流程：轮询授权结果，完成后创建会话
+func (h *AuthHandler) PollDeviceFlow(c *gin.Context) {
+	if !h.requireOAuth(c) {
+		return
+	}
+
+	var req struct {
+		DeviceCode string `json:"device_code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+
+	token, err := h.oauth.PollDeviceToken(c.Request.Context(), req.DeviceCode)
+	if err != nil {
+		var oauthErr *github.OAuthError
+		if errors.As(err, &oauthErr) && oauthErr.Pending() {
+			c.JSON(202, gin.H{"status": oauthErr.Code, "interval": oauthErr.Interval})
+			return
+		}
+		if errors.As(err, &oauthErr) {
+			c.JSON(400, gin.H{"error": oauthErr.Error(), "status": oauthErr.Code})
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	session, err := h.startSession(c, token.AccessToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"login": session.Login, "expires_at": session.ExpiresAt}, "Signed in successfully")
+}
+
+// LocalLogin 使用本地账户的用户名和密码登录
+func (h *AuthHandler) LocalLogin(c *gin.Context) {
+	if h.backends.users == nil {
+		c.JSON(501, gin.H{"error": "Local accounts are not enabled on this server"})
+		return
+	}
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+
+	setAuditLogin(c, req.Username)
+	user, err := h.backends.users.Authenticate(req.Username, req.Password)
+	if err != nil {
+		c.JSON(401, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.backends.sessions.CreateLocal(user.Username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.setCookie(c, sessionCookie, session.ID, time.Until(session.ExpiresAt))
+
+	middleware.Success(c, gin.H{"login": session.Login, "local": true, "expires_at": session.ExpiresAt}, "Signed in successfully")
+}
+
+// ChangeLocalPassword 本地账户修改自己的密码，其他已登录的会话随之失效
+func (h *AuthHandler) ChangeLocalPassword(c *gin.Context) {
+	user := localUserFromContext(c)
+	if user == nil {
+		c.JSON(401, gin.H{"error": "Sign in with a local account"})
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+
+	if _, err := h.backends.users.Authenticate(user.Username, req.CurrentPassword); err != nil {
+		c.JSON(401, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if err := h.backends.users.SetPassword(user.Username, req.NewPassword); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	// 注销所有会话后为当前客户端重新登录
+	if err := h.backends.sessions.DeleteUser(user.Username); err != nil {
+		c.Error(err)
+		return
+	}
+	session, err := h.backends.sessions.CreateLocal(user.Username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.setCookie(c, sessionCookie, session.ID, time.Until(session.ExpiresAt))
+
+	middleware.Success(c, gin.H{"message": "Password changed successfully"}, "Password changed successfully")
+}
+
+// GetSession 返回当前会话信息
+func (h *AuthHandler) GetSession(c *gin.Context) {
+	id, err := c.Cookie(sessionCookie)
+	if err != nil || id == "" {
+		c.JSON(401, gin.H{"error": "Not signed in"})
+		return
+	}
+
+	session, _, err := h.backends.sessions.Get(id)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Not signed in"})
+		return
+	}
+
+	middleware.Success(c, gin.H{"login": session.Login, "local": session.Local, "expires_at": session.ExpiresAt}, "Session retrieved successfully")
+}
+
+// InspectToken 校验当前 token，返回所属用户、作用域、是否为细粒度令牌、过期时间和配额
+func (h *AuthHandler) InspectToken(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	info, err := client.InspectToken(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, info, "Token inspected successfully")
+}
+
+// Logout 删除会话
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if id, err := c.Cookie(sessionCookie); err == nil && id != "" {
+		if err := h.backends.sessions.Delete(id); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	h.setCookie(c, sessionCookie, "", -1)
+
+	middleware.Success(c, gin.H{"message": "Signed out successfully"}, "Signed out successfully")
+}
+
+// startSession 验证 token 并创建会话，写入会话 cookie
+func (h *AuthHandler) startSession(c *gin.Context, token string) (*auth.Session, error) {
+	client, err := github.NewClientWithEndpoints(token, proxy.ProxyConfig{Enabled: false}, h.backends.githubEndpoints)
+	if err != nil {
+		return nil, err
+	}
+	user, err := client.GetUser(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := h.backends.sessions.Create(user.Login, token)
+	if err != nil {
+		return nil, err
+	}
+	h.setCookie(c, sessionCookie, session.ID, time.Until(session.ExpiresAt))
+	return session, nil
+}
+
+// setCookie 写入 HttpOnly cookie，maxAge 为负时删除
+func (h *AuthHandler) setCookie(c *gin.Context, name, value string, maxAge time.Duration) {
+	sameSite := http.SameSiteLaxMode
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	if h.crossSite {
+		sameSite = http.SameSiteNoneMode
+		secure = true
+	}
+
+	seconds := int(maxAge.Seconds())
+	if maxAge < 0 {
+		seconds = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   seconds,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: sameSite,
+	})
+}
+
+// RegisterAuthRoutes 注册登录相关的路由
+func RegisterAuthRoutes(router *gin.RouterGroup, backends *backendProvider) error {
+	handler, err := NewAuthHandler(backends)
+	if err != nil {
+		return err
+	}
+
+	router.GET("/auth/github/login", handler.Login)
+	router.GET("/auth/github/callback", handler.Callback)
+	router.POST("/auth/github/device", handler.StartDeviceFlow)
+	router.POST("/auth/github/device/poll", handler.PollDeviceFlow)
+	router.GET("/auth/session", handler.GetSession)
+	router.GET("/auth/token", handler.InspectToken)
+	router.POST("/auth/logout", handler.Logout)
+	router.POST("/auth/local/login", handler.LocalLogin)
+	router.PUT("/auth/local/password", handler.ChangeLocalPassword)
+
+	return nil
+}
@@ -0,0 +1,427 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"git-net-disk/internal/auth"
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/proxy"
+	"git-net-disk/internal/storage"
+	"git-net-disk/internal/storage/gitea"
+	"git-net-disk/internal/storage/gitlab"
+	"git-net-disk/internal/storage/localgit"
+	"git-net-disk/internal/writequeue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的远程存储类型
+const (
+	backendGitHub = "github"
+	backendGitea  = "gitea" // 同样适用于 Forgejo
+	backendGitLab = "gitlab"
+)
+
+// StorageAccount 服务端配置的存储账户，请求通过 X-Storage-Account 头按名称选用
+type StorageAccount struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // github, gitea, gitlab
+	URL  string `json:"url"`  // 实例根地址，GitHub 类型为空时使用服务器默认地址
+
+	// 仅 GitHub 类型使用，覆盖由 URL 推导出的接口地址
+	APIURL    string `json:"api_url,omitempty"`
+	UploadURL string `json:"upload_url,omitempty"`
+	RawURL    string `json:"raw_url,omitempty"`
+}
+
+// backendProvider 为每个请求选择存储后端
+type backendProvider struct {
+	// local 非 nil 时所有请求都使用本地 git 存储，不需要 GitHub token
+	local storage.Backend
+	// accounts 按名称索引的存储账户
+	accounts map[string]StorageAccount
+	// allowCustomURL 为 true 时允许请求通过 X-Storage-URL 指定任意实例地址
+	allowCustomURL bool
+	// githubEndpoints 服务器默认的 GitHub 接口地址
+	githubEndpoints github.Endpoints
+	// anonymous 匿名只读访问的限流器，nil 表示禁用匿名访问
+	anonymous *anonymousLimiter
+	// sessions OAuth 登录会话，token 加密保存在服务端
+	sessions *auth.SessionStore
+	// app 非 nil 时没有 token 的请求以 GitHub App 安装身份访问默认 GitHub 实例
+	app *github.AppAuth
+	// users 本地账户，未设置 USERS_FILE 时为 nil
+	users *auth.UserStore
+	// serverToken 服务器配置的 GITHUB_TOKEN，本地账户的请求使用它访问 GitHub
+	serverToken string
+	// apiKeys 服务器签发的 API key，API_KEYS_FILE 未设置时只保存在内存中
+	apiKeys *auth.APIKeyStore
+	// audit 写操作的审计日志，AUDIT_LOG_FILE=off 时为 nil
+	audit *auditor
+	// writes 按分支串行执行文件写入的队列
+	writes *writequeue.Queue
+	// admins 来自 AUDIT_ADMINS 的 GitHub 管理员登录名，小写，可以查看所有人的审计记录和服务器状态
+	admins map[string]bool
+}
+
+// newBackendProviderFromEnv 根据环境变量创建后端选择器
+// STORAGE_BACKEND=local 时使用 LOCAL_STORAGE_DIR 下的裸仓库，所有者为 LOCAL_STORAGE_OWNER
+// 本地存储只能用 USERS_FILE 中的本地账户认证，未设置时拒绝启动
+// LOCAL_STORAGE_NO_AUTH=true 显式关闭认证：任何能连上端口的人都可以读写全部仓库，只应在本机或可信网络中使用
+// STORAGE_ACCOUNTS_FILE 指向存储账户列表的 JSON 文件
+func newBackendProviderFromEnv() (*backendProvider, error) {
+	provider := &backendProvider{
+		accounts:        make(map[string]StorageAccount),
+		allowCustomURL:  os.Getenv("ALLOW_CUSTOM_STORAGE_URL") == "true",
+		githubEndpoints: github.EndpointsFromEnv(),
+		serverToken:     os.Getenv("GITHUB_TOKEN"),
+		writes:          writequeue.New(writequeue.ConfigFromEnv()),
+	}
+
+	anonymous, err := newAnonymousLimiterFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	provider.anonymous = anonymous
+
+	app, err := github.AppAuthFromEnv(provider.githubEndpoints)
+	if err != nil {
+		return nil, err
+	}
+	if app != nil {
+		fmt.Println("[INFO] Using GitHub App installation for local accounts and rate-limited anonymous reads")
+	}
+	provider.app = app
+
+	secret, err := sessionSecretFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := newSessionStoreFromEnv(secret)
+	if err != nil {
+		return nil, err
+	}
+	provider.sessions = sessions
+
+	apiKeys, err := auth.NewAPIKeyStore(secret, os.Getenv("API_KEYS_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	provider.apiKeys = apiKeys
+
+	auditLog, err := newAuditorFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	provider.audit = auditLog
+	provider.admins = adminsFromEnv()
+
+	users, err := newUserStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if users != nil && provider.serverToken == "" && provider.app == nil && os.Getenv("STORAGE_BACKEND") != "local" {
+		return nil, fmt.Errorf("USERS_FILE requires GITHUB_TOKEN or a GitHub App so that local accounts can reach GitHub")
+	}
+	provider.users = users
+
+	if path := os.Getenv("STORAGE_ACCOUNTS_FILE"); path != "" {
+		accounts, err := loadStorageAccounts(path)
+		if err != nil {
+			return nil, err
+		}
+		provider.accounts = accounts
+	}
+
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "", backendGitHub:
+		return provider, nil
+	case "local":
+		dir := os.Getenv("LOCAL_STORAGE_DIR")
+		if dir == "" {
+			dir = "./data/repos"
+		}
+		owner := os.Getenv("LOCAL_STORAGE_OWNER")
+		if owner == "" {
+			owner = "local"
+		}
+		backend, err := localgit.New(dir, owner)
+		if err != nil {
+			return nil, err
+		}
+		if provider.users == nil {
+			if os.Getenv("LOCAL_STORAGE_NO_AUTH") != "true" {
+				return nil, fmt.Errorf("STORAGE_BACKEND=local requires USERS_FILE for authentication; set LOCAL_STORAGE_NO_AUTH=true to serve all repositories without authentication")
+			}
+			fmt.Println("[WARN] ======================================================================")
+			fmt.Println("[WARN] LOCAL_STORAGE_NO_AUTH=true: authentication is DISABLED.")
+			fmt.Printf("[WARN] Anyone who can reach this server can read and write every repository in %s\n", dir)
+			fmt.Println("[WARN] ======================================================================")
+		}
+		fmt.Printf("[INFO] Using local git storage at %s\n", dir)
+		provider.local = backend
+		return provider, nil
+	default:
+		return nil, fmt.Errorf("unsupported STORAGE_BACKEND: %s", os.Getenv("STORAGE_BACKEND"))
+	}
+}
+
+// loadStorageAccounts 加载存储账户配置文件
+func loadStorageAccounts(path string) (map[string]StorageAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage accounts: %v", err)
+	}
+
+	var list []StorageAccount
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid storage accounts file: %v", err)
+	}
+
+	accounts := make(map[string]StorageAccount, len(list))
+	for _, account := range list {
+		switch account.Type {
+		case backendGitHub, backendGitea, backendGitLab:
+		default:
+			return nil, fmt.Errorf("storage account %s has unsupported type %q", account.Name, account.Type)
+		}
+		accounts[account.Name] = account
+	}
+	return accounts, nil
+}
+
+// accountFromRequest 解析请求选用的存储账户
+// 优先使用 X-Storage-Account，其次是 X-Storage-Backend 和 X-Storage-URL，默认为 GitHub
+// 本地账户的会话和 API key 使用服务器的 token，只能访问默认的 GitHub 实例，不能通过这些头把服务器 token 发往其他地址
+func (p *backendProvider) accountFromRequest(c *gin.Context) (StorageAccount, error) {
+	if localUserFromContext(c) != nil {
+		backend := c.GetHeader("X-Storage-Backend")
+		if c.GetHeader("X-Storage-Account") != "" || c.GetHeader("X-Storage-URL") != "" || (backend != "" && !strings.EqualFold(backend, backendGitHub)) {
+			return StorageAccount{}, fmt.Errorf("local accounts can only use the server's default GitHub storage")
+		}
+		return StorageAccount{Type: backendGitHub}, nil
+	}
+
+	if name := c.GetHeader("X-Storage-Account"); name != "" {
+		account, ok := p.accounts[name]
+		if !ok {
+			return StorageAccount{}, fmt.Errorf("unknown storage account: %s", name)
+		}
+		return account, nil
+	}
+
+	account := StorageAccount{
+		Type: strings.ToLower(c.GetHeader("X-Storage-Backend")),
+		URL:  c.GetHeader("X-Storage-URL"),
+	}
+	if account.Type == "" {
+		account.Type = backendGitHub
+	}
+	if account.Type == "forgejo" {
+		account.Type = backendGitea
+	}
+	if account.URL != "" && !p.allowCustomURL {
+		return StorageAccount{}, fmt.Errorf("custom storage URLs are disabled on this server")
+	}
+	return account, nil
+}
+
+// backendFromRequest 返回请求使用的存储后端，失败时已写入响应
+func (p *backendProvider) backendFromRequest(c *gin.Context) (storage.Backend, bool) {
+	return p.backendFor(c, false)
+}
+
+// readBackendFromRequest 与 backendFromRequest 相同，但没有 token 时以匿名身份只读访问公开仓库
+func (p *backendProvider) readBackendFromRequest(c *gin.Context) (storage.Backend, bool) {
+	return p.backendFor(c, true)
+}
+
+// backendFor 选择存储后端，allowAnonymous 为 true 时允许不带 token 的限流请求
+func (p *backendProvider) backendFor(c *gin.Context, allowAnonymous bool) (storage.Backend, bool) {
+	if p.local != nil {
+		// 本地账户中间件已拒绝未登录的请求，这里再确认一次，避免新路由绕过它
+		if p.users != nil && localUserFromContext(c) == nil {
+			c.JSON(401, gin.H{"error": "Sign in required"})
+			return nil, false
+		}
+		return p.local, true
+	}
+
+	account, err := p.accountFromRequest(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if account.Type == backendGitHub {
+		client, ok := p.newGitHubClient(c, account, allowAnonymous)
+		if !ok {
+			return nil, false
+		}
+		return client, true
+	}
+
+	userToken := p.tokenFromRequest(c)
+	if userToken == "" {
+		if !allowAnonymous {
+			c.JSON(401, gin.H{"error": "Missing authentication token"})
+			return nil, false
+		}
+		if !p.admitAnonymous(c) {
+			return nil, false
+		}
+	}
+	proxyConfig := getProxyConfigFromHeader(c)
+
+	var backend storage.Backend
+	switch account.Type {
+	case backendGitea:
+		backend, err = gitea.NewClient(account.URL, userToken, proxyConfig)
+	case backendGitLab:
+		backend, err = gitlab.NewClient(account.URL, userToken, proxyConfig)
+	default:
+		err = fmt.Errorf("unsupported storage backend: %s", account.Type)
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return backend, true
+}
+
+// githubClientFromRequest 为 GitHub 专有功能创建客户端，失败时已写入响应
+func (p *backendProvider) githubClientFromRequest(c *gin.Context) (*github.Client, bool) {
+	if p.local != nil {
+		c.JSON(501, gin.H{"error": "This feature requires the GitHub storage backend"})
+		return nil, false
+	}
+	account, err := p.accountFromRequest(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if account.Type != backendGitHub {
+		c.JSON(501, gin.H{"error": "This feature requires the GitHub storage backend"})
+		return nil, false
+	}
+	return p.newGitHubClient(c, account, false)
+}
+
+// githubEndpointsFor 计算账户使用的 GitHub 接口地址
+func (p *backendProvider) githubEndpointsFor(account StorageAccount) github.Endpoints {
+	endpoints := p.githubEndpoints
+	if account.URL != "" {
+		endpoints = github.EnterpriseEndpoints(account.URL)
+	}
+	return endpoints.Override(github.Endpoints{
+		APIURL:    account.APIURL,
+		UploadURL: account.UploadURL,
+		RawURL:    account.RawURL,
+	})
+}
+
+// tokenFromRequest 解析请求使用的 GitHub token，优先使用会话 cookie 中保存的 token，其次是 Authorization 头
+// 本地账户的会话和 API key 使用服务器配置的 token
+func (p *backendProvider) tokenFromRequest(c *gin.Context) string {
+	// API key 中间件已解析出 key 对应的 token
+	if token, ok := c.Get(apiKeyTokenKey); ok {
+		return token.(string)
+	}
+	if id, err := c.Cookie(sessionCookie); err == nil && id != "" {
+		if session, token, err := p.sessions.Get(id); err == nil {
+			if session.Local {
+				if localUserFromContext(c) == nil {
+					return ""
+				}
+				return p.serverToken
+			}
+			return token
+		}
+	}
+	return getTokenFromHeader(c)
+}
+
+// getTokenFromHeader 从 Authorization 头解析 token，支持 "token xxx" 和裸 token 两种格式
+func getTokenFromHeader(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	return strings.TrimSpace(strings.TrimPrefix(authHeader, "token "))
+}
+
+// newGitHubClient 根据请求中的 token、代理配置和账户接口地址创建 GitHub 客户端
+// 没有 token 时只有已登录的本地账户以 GitHub App 安装身份访问，与 serverBackend 相同
+// allowAnonymous 为 true 时其他没有 token 的请求经限流后使用匿名客户端，配置了 App 时借用安装的配额，只能只读访问公开仓库
+// 失败时已写入响应，调用方直接返回即可
+func (p *backendProvider) newGitHubClient(c *gin.Context, account StorageAccount, allowAnonymous bool) (*github.Client, bool) {
+	userToken := p.tokenFromRequest(c)
+	useApp := p.app != nil && account.URL == "" && account.APIURL == ""
+
+	if userToken == "" {
+		if useApp && localUserFromContext(c) != nil {
+			return p.newAppClient(c, account, false)
+		}
+		if !allowAnonymous {
+			c.JSON(401, gin.H{"error": "Missing authentication token"})
+			return nil, false
+		}
+		if !p.admitAnonymous(c) {
+			return nil, false
+		}
+		if useApp {
+			return p.newAppClient(c, account, true)
+		}
+	}
+
+	// 从请求头获取代理配置
+	proxyConfig := getProxyConfigFromHeader(c)
+
+	client, err := github.NewClientWithEndpoints(userToken, proxyConfig, p.githubEndpointsFor(account))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to create GitHub client"})
+		return nil, false
+	}
+	forwardQuotaHeaders(c, client)
+
+	return client, true
+}
+
+// newAppClient 以 GitHub App 安装身份创建客户端，publicOnly 为 true 时只能只读访问公开仓库
+// 失败时已写入响应
+func (p *backendProvider) newAppClient(c *gin.Context, account StorageAccount, publicOnly bool) (*github.Client, bool) {
+	newClient := github.NewAppClient
+	if publicOnly {
+		newClient = github.NewPublicAppClient
+	}
+	client, err := newClient(p.app, getProxyConfigFromHeader(c), p.githubEndpointsFor(account))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to create GitHub client"})
+		return nil, false
+	}
+	forwardQuotaHeaders(c, client)
+	return client, true
+}
+
+// writeTarget 返回写队列的目标分支，实例由后端类型和接口地址区分
+func (p *backendProvider) writeTarget(c *gin.Context, owner, repo, branch string) writequeue.Target {
+	instance := "local"
+	if p.local == nil {
+		if account, err := p.accountFromRequest(c); err == nil {
+			instance = account.Type + ":" + account.URL
+			if account.Type == backendGitHub {
+				instance = account.Type + ":" + p.githubEndpointsFor(account).APIURL
+			}
+		}
+	}
+	return writequeue.Target{Instance: instance, Owner: owner, Repo: repo, Branch: branch}
+}
+
+// writeIdentity 返回写队列中区分调用者的标识，只有同一身份的写入会合并到同一个提交
+// 本地账户共用服务器 token，按账户区分，避免提交说明中混入其他账户的写入
+func (p *backendProvider) writeIdentity(c *gin.Context) string {
+	if user := localUserFromContext(c); user != nil {
+		return "local:" + user.Username
+	}
+	return proxy.TokenFingerprint(p.tokenFromRequest(c))
+}
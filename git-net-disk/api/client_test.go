@@ -0,0 +1,107 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newAppTestServer 以 GitHub App 配置启动服务器，GitHub 由 httptest 模拟：alice/public 公开，alice/secret 私有
+func newAppTestServer(t *testing.T, anonymousRateLimit string) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app/installations/42/access_tokens" {
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"token": "inst-token", "expires_at": time.Now().Add(time.Hour)})
+			return
+		}
+		if r.Header.Get("Authorization") != "token inst-token" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"login":"installation"}`)
+		case "/repos/alice/public":
+			fmt.Fprint(w, `{"name":"public","private":false,"permissions":{"pull":true}}`)
+		case "/repos/alice/secret":
+			fmt.Fprint(w, `{"name":"secret","private":true,"permissions":{"pull":true}}`)
+		case "/repos/alice/public/contents/a.txt", "/repos/alice/secret/contents/a.txt":
+			fmt.Fprint(w, `{"name":"a.txt","path":"a.txt","sha":"abc","type":"file","content":"aGk=","encoding":"base64"}`)
+		default:
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	t.Setenv("STORAGE_BACKEND", "")
+	t.Setenv("USERS_FILE", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("AUDIT_LOG_FILE", "off")
+	t.Setenv("ANONYMOUS_RATE_LIMIT", anonymousRateLimit)
+	t.Setenv("GITHUB_API_URL", upstream.URL)
+	t.Setenv("GITHUB_RAW_URL", upstream.URL+"/raw")
+	t.Setenv("GITHUB_APP_ID", "1234")
+	t.Setenv("GITHUB_APP_INSTALLATION_ID", "42")
+	t.Setenv("GITHUB_APP_PRIVATE_KEY", string(keyPEM))
+	t.Setenv("GITHUB_APP_TOKEN_URL", "")
+
+	server := NewServer()
+	if err := server.RegisterRoutes(); err != nil {
+		t.Fatalf("RegisterRoutes: %v", err)
+	}
+	return server.GetRouter()
+}
+
+func TestAnonymousRequestsUseAppReadOnly(t *testing.T) {
+	h := newAppTestServer(t, "")
+
+	if code, _ := doJSON(t, h, "GET", "/api/file/alice/public/a.txt", nil); code != http.StatusOK {
+		t.Fatalf("anonymous read of a public repository = %d, want 200", code)
+	}
+	if code, _ := doJSON(t, h, "GET", "/api/file/alice/secret/a.txt", nil); code != http.StatusNotFound {
+		t.Fatalf("anonymous read of a private repository = %d, want 404", code)
+	}
+	if code, _ := doJSON(t, h, "PUT", "/api/file/alice/public/a.txt", gin.H{"content": "aGk=", "message": "add"}); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous write = %d, want 401", code)
+	}
+	if code, _ := doJSON(t, h, "GET", "/api/user", nil); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous GET /api/user = %d, want 401", code)
+	}
+}
+
+func TestAnonymousAppAccessIsRateLimited(t *testing.T) {
+	h := newAppTestServer(t, "1")
+
+	if code, _ := doJSON(t, h, "GET", "/api/file/alice/public/a.txt", nil); code != http.StatusOK {
+		t.Fatalf("first anonymous read = %d, want 200", code)
+	}
+	if code, _ := doJSON(t, h, "GET", "/api/file/alice/public/a.txt", nil); code != http.StatusTooManyRequests {
+		t.Fatalf("second anonymous read = %d, want 429", code)
+	}
+}
+
+func TestAnonymousAppAccessDisabled(t *testing.T) {
+	h := newAppTestServer(t, "0")
+
+	if code, _ := doJSON(t, h, "GET", "/api/file/alice/public/a.txt", nil); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous read with ANONYMOUS_RATE_LIMIT=0 = %d, want 401", code)
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"sync"
+
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// driveLookupConcurrency 读取网盘设置文件的并发数
+const driveLookupConcurrency = 8
+
+// loadDriveSettings 读取仓库的网盘设置
+// 没有设置文件但带有网盘主题时返回默认设置，两者都没有时返回 nil
+func loadDriveSettings(ctx context.Context, client storage.Backend, repo *github.Repository) (*github.DriveSettings, error) {
+	owner, name := repo.Owner.Login, repo.Name
+
+	sha, err := client.GetFileSHA(ctx, owner, name, github.DriveSettingsFile, "")
+	if err != nil {
+		// 空仓库没有默认分支，contents 接口会报错，视为普通仓库
+		if repo.Size == 0 && !repo.HasTopic(github.DriveTopic) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if sha == "" {
+		if repo.HasTopic(github.DriveTopic) {
+			return &github.DriveSettings{}, nil
+		}
+		return nil, nil
+	}
+
+	file, err := client.GetFileContent(ctx, owner, name, github.DriveSettingsFile, "")
+	if err != nil {
+		return nil, err
+	}
+	return github.DecodeDriveSettings(file)
+}
+
+// markDrives 并发读取每个仓库的网盘设置并填充 Drive 字段
+func markDrives(ctx context.Context, client storage.Backend, repos []github.Repository) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, driveLookupConcurrency)
+
+	for i := range repos {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(repo *github.Repository) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			settings, err := loadDriveSettings(ctx, client, repo)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			repo.Drive = settings
+		}(&repos[i])
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// writeDriveSettings 写入网盘设置文件，GitHub 后端同时添加网盘主题
+func writeDriveSettings(ctx context.Context, client storage.Backend, owner, repo string, settings *github.DriveSettings, message string) error {
+	content, err := github.EncodeDriveSettings(settings)
+	if err != nil {
+		return err
+	}
+
+	sha, err := client.GetFileSHA(ctx, owner, repo, github.DriveSettingsFile, "")
+	if err != nil {
+		return err
+	}
+	if _, err := client.CreateOrUpdateFile(ctx, owner, repo, github.DriveSettingsFile, content, message, "", sha); err != nil {
+		return err
+	}
+
+	if gh, ok := client.(*github.Client); ok {
+		topics, err := gh.GetTopics(ctx, owner, repo)
+		if err != nil {
+			return err
+		}
+		for _, topic := range topics {
+			if topic == github.DriveTopic {
+				return nil
+			}
+		}
+		if _, err := gh.ReplaceTopics(ctx, owner, repo, append(topics, github.DriveTopic)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDriveSettings 读取仓库的网盘设置
+func (h *ReposHandler) GetDriveSettings(c *gin.Context) {
+	client, ok := h.backends.backendFromRequest(c)
+	if !ok {
+		return
+	}
+
+	repo := github.Repository{
+		Name:  c.Param("repo"),
+		Owner: github.Owner{Login: c.Param("owner")},
+	}
+	if gh, ok := client.(*github.Client); ok {
+		// 需要主题信息判断没有设置文件的网盘
+		full, err := gh.GetRepository(c.Request.Context(), repo.Owner.Login, repo.Name)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		repo = *full
+	}
+
+	settings, err := loadDriveSettings(c.Request.Context(), client, &repo)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if settings == nil {
+		c.JSON(404, gin.H{"error": "Repository is not a drive"})
+		return
+	}
+
+	middleware.Success(c, settings, "Drive settings retrieved successfully")
+}
+
+// UpdateDriveSettings 写入网盘设置，普通仓库写入后即成为网盘
+func (h *ReposHandler) UpdateDriveSettings(c *gin.Context) {
+	client, ok := h.backends.backendFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var settings github.DriveSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+
+	if !requirePermission(c, client, c.Param("owner"), c.Param("repo"), github.PermissionWrite) {
+		return
+	}
+
+	if err := writeDriveSettings(c.Request.Context(), client, c.Param("owner"), c.Param("repo"), &settings, "Update drive settings"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, settings, "Drive settings updated successfully")
+}
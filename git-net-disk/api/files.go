@@ -1,12 +1,21 @@
 package api
 
 import (
-	"net/url"
-	"strconv"
-	"strings"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"git-net-disk/api/middleware"
+	"git-net-disk/internal/auth"
 	"git-net-disk/internal/github"
 	"git-net-disk/internal/proxy"
+	"git-net-disk/internal/storage"
+	"git-net-disk/internal/writequeue"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,45 +23,34 @@ import (
 // FilesHandler 文件相关的 API 处理器
 type FilesHandler struct {
 	proxyConfig proxy.ProxyConfig
+	backends    *backendProvider
+	// largeObjectThreshold 超过该大小的文件存为 Release 附件，0 表示禁用
+	largeObjectThreshold int64
 }
 
 // NewFilesHandler 创建新的文件处理器
-func NewFilesHandler(token string, proxyConfig proxy.ProxyConfig) (*FilesHandler, error) {
+func NewFilesHandler(token string, proxyConfig proxy.ProxyConfig, backends *backendProvider) (*FilesHandler, error) {
+	var threshold int64
+	if v := os.Getenv("LARGE_OBJECT_THRESHOLD"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LARGE_OBJECT_THRESHOLD: %v", err)
+		}
+		threshold = n
+	}
+
 	return &FilesHandler{
-		proxyConfig: proxyConfig,
+		proxyConfig:          proxyConfig,
+		backends:             backends,
+		largeObjectThreshold: threshold,
 	}, nil
 }
 
 // ListFiles 列出仓库中的文件
 func (h *FilesHandler) ListFiles(c *gin.Context) {
-	// 从请求头获取token
-	authHeader := c.GetHeader("Authorization")
-	var userToken string
-	
-	// 使用 strings.HasPrefix 和 TrimPrefix
-	if authHeader != "" {
-		if strings.HasPrefix(authHeader, "token ") {
-			userToken = strings.TrimPrefix(authHeader, "token ")
-		} else {
-			userToken = authHeader
-		}
-	}
-	
-	// 清理空格
-	userToken = strings.TrimSpace(userToken)
-
-	if userToken == "" {
-		c.JSON(401, gin.H{"error": "Missing authentication token"})
-		return
-	}
-
-	// 从请求头获取代理配置
-	proxyConfig := getProxyConfigFromHeader(c)
-
-	// 创建临时GitHub客户端
-	client, err := github.NewClient(userToken, proxyConfig)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create GitHub client"})
+	// 根据配置选择存储后端
+	client, ok := h.backends.readBackendFromRequest(c)
+	if !ok {
 		return
 	}
 
@@ -63,45 +61,87 @@ func (h *FilesHandler) ListFiles(c *gin.Context) {
 	// Gin 的 *path 参数会包含开头的斜杠，需要移除
 	path = strings.TrimPrefix(path, "/")
 
-	files, err := client.ListFiles(owner, repo, path)
+	if !requireKeyScope(c, owner, repo, path, auth.OperationRead) {
+		return
+	}
+
+	// ref 可以是分支、标签、快照或提交 SHA
+	files, err := client.ListFiles(c.Request.Context(), owner, repo, path, c.Query("ref"))
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	middleware.Success(c, files, "Files listed successfully")
-}
-
-// GetFileContent 获取文件内容
-func (h *FilesHandler) GetFileContent(c *gin.Context) {
-	// 从请求头获取token
-	authHeader := c.GetHeader("Authorization")
-	var userToken string
-	
-	// 使用 strings.HasPrefix 和 TrimPrefix
-	if authHeader != "" {
-		if strings.HasPrefix(authHeader, "token ") {
-			userToken = strings.TrimPrefix(authHeader, "token ")
-		} else {
-			userToken = authHeader
+	// q 按名称过滤，type 只保留 file 或 dir
+	keyword := strings.ToLower(c.Query("q"))
+	fileType := c.Query("type")
+	filtered := make([]github.FileEntry, 0, len(files))
+	for _, file := range files {
+		if keyword != "" && !strings.Contains(strings.ToLower(file.Name), keyword) {
+			continue
+		}
+		if fileType != "" && file.Type != fileType {
+			continue
 		}
+		filtered = append(filtered, file)
 	}
-	
-	// 清理空格
-	userToken = strings.TrimSpace(userToken)
 
-	if userToken == "" {
-		c.JSON(401, gin.H{"error": "Missing authentication token"})
+	if err := sortFiles(c, filtered); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 从请求头获取代理配置
-	proxyConfig := getProxyConfigFromHeader(c)
+	page, ok := paginate(c, filtered)
+	if !ok {
+		return
+	}
+
+	middleware.Success(c, page, "Files listed successfully")
+}
+
+// sortFiles 按 sort（name、size、type）和 direction 参数排序，未指定时保持后端返回的顺序
+func sortFiles(c *gin.Context, files []github.FileEntry) error {
+	field := c.Query("sort")
+	if field == "" {
+		return nil
+	}
 
-	// 创建临时GitHub客户端
-	client, err := github.NewClient(userToken, proxyConfig)
+	var less func(a, b *github.FileEntry) bool
+	switch field {
+	case "name":
+		less = func(a, b *github.FileEntry) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case "size":
+		less = func(a, b *github.FileEntry) bool { return a.Size < b.Size }
+	case "type":
+		// 目录排在文件前面，同类按名称排序
+		less = func(a, b *github.FileEntry) bool {
+			if a.Type != b.Type {
+				return a.Type == "dir"
+			}
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+	default:
+		return fmt.Errorf("unsupported sort field: %s", field)
+	}
+
+	desc, err := parseSortDirection(c, field == "size")
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create GitHub client"})
+		return err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if desc {
+			return less(&files[j], &files[i])
+		}
+		return less(&files[i], &files[j])
+	})
+	return nil
+}
+
+// GetFileContent 获取文件内容
+func (h *FilesHandler) GetFileContent(c *gin.Context) {
+	// 根据配置选择存储后端
+	client, ok := h.backends.readBackendFromRequest(c)
+	if !ok {
 		return
 	}
 
@@ -112,45 +152,33 @@ func (h *FilesHandler) GetFileContent(c *gin.Context) {
 	// Gin 的 *path 参数会包含开头的斜杠，需要移除
 	path = strings.TrimPrefix(path, "/")
 
-	file, err := client.GetFileContent(owner, repo, path)
+	if !requireKeyScope(c, owner, repo, path, auth.OperationRead) {
+		return
+	}
+
+	file, err := client.GetFileContent(c.Request.Context(), owner, repo, path, c.Query("ref"))
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
+	// 指针文件对外表现为原始大文件
+	if pointer, ok := decodeLargeObjectPointer(file); ok {
+		file.LargeObject = pointer
+		file.Content = ""
+		file.Encoding = ""
+		file.Size = int(pointer.Size)
+	}
+
+	c.Header("ETag", `"`+file.SHA+`"`)
 	middleware.Success(c, file, "File content retrieved successfully")
 }
 
 // CreateOrUpdateFile 创建或更新文件
 func (h *FilesHandler) CreateOrUpdateFile(c *gin.Context) {
-	// 从请求头获取token
-	authHeader := c.GetHeader("Authorization")
-	var userToken string
-	
-	// 使用 strings.HasPrefix 和 TrimPrefix
-	if authHeader != "" {
-		if strings.HasPrefix(authHeader, "token ") {
-			userToken = strings.TrimPrefix(authHeader, "token ")
-		} else {
-			userToken = authHeader
-		}
-	}
-	
-	// 清理空格
-	userToken = strings.TrimSpace(userToken)
-
-	if userToken == "" {
-		c.JSON(401, gin.H{"error": "Missing authentication token"})
-		return
-	}
-
-	// 从请求头获取代理配置
-	proxyConfig := getProxyConfigFromHeader(c)
-
-	// 创建临时GitHub客户端
-	client, err := github.NewClient(userToken, proxyConfig)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create GitHub client"})
+	// 根据配置选择存储后端
+	client, ok := h.backends.backendFromRequest(c)
+	if !ok {
 		return
 	}
 
@@ -160,63 +188,136 @@ func (h *FilesHandler) CreateOrUpdateFile(c *gin.Context) {
 	
 	// Gin 的 *path 参数会包含开头的斜杠，需要移除
 	path = strings.TrimPrefix(path, "/")
-	
-	// 调试日志
-	println("[DEBUG] CreateOrUpdateFile API - owner:", owner, "repo:", repo, "path:", path)
+
+	if !requireKeyScope(c, owner, repo, path, auth.OperationWrite) {
+		return
+	}
 
 	var req struct {
-		Content string `json:"content" binding:"required"`
-		Message string `json:"message" binding:"required"`
-		Branch  string `json:"branch"`
+		Content   string `json:"content" binding:"required"`
+		Message   string `json:"message" binding:"required"`
+		Branch    string `json:"branch"`
+		SHA       string `json:"sha"`       // 期望的当前文件 SHA，与 If-Match 等价
+		Overwrite bool   `json:"overwrite"` // 无前置条件时显式覆盖已有文件
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		println("[DEBUG] Failed to parse request body:", err.Error())
-		c.Error(err)
+		c.Error(middleware.BindError(err))
 		return
 	}
-	
-	println("[DEBUG] Request - Message:", req.Message, "Content length:", len(req.Content), "Branch:", req.Branch)
 
-	file, err := client.CreateOrUpdateFile(owner, repo, path, req.Content, req.Message, req.Branch)
-	if err != nil {
-		c.Error(err)
+	if !requirePermission(c, client, owner, repo, github.PermissionWrite) {
 		return
 	}
 
-	middleware.Success(c, file, "File created or updated successfully")
-}
-
-// DeleteFile 删除文件
-func (h *FilesHandler) DeleteFile(c *gin.Context) {
-	// 从请求头获取token
-	authHeader := c.GetHeader("Authorization")
-	var userToken string
-	
-	// 使用 strings.HasPrefix 和 TrimPrefix
-	if authHeader != "" {
-		if strings.HasPrefix(authHeader, "token ") {
-			userToken = strings.TrimPrefix(authHeader, "token ")
-		} else {
-			userToken = authHeader
+	// 树中只保存指针。仅 GitHub 后端支持，超过阈值的文件转存为 Release 附件
+	gh, isGitHub := client.(*github.Client)
+	if isGitHub && h.largeObjectThreshold > 0 && int64(base64.StdEncoding.DecodedLen(len(req.Content))) > h.largeObjectThreshold {
+		if !checkWritePrecondition(c, client, owner, repo, req.Branch, newFileWrite(c, path, "", req.Message, req.SHA, req.Overwrite)) {
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(req.Content)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		pointer, err := storeLargeObject(c.Request.Context(), gh, owner, repo, bytes.NewReader(data), "")
+		if err != nil {
+			c.Error(err)
+			return
 		}
+		req.Content = base64.StdEncoding.EncodeToString(pointer.Encode())
 	}
-	
-	// 清理空格
-	userToken = strings.TrimSpace(userToken)
 
-	if userToken == "" {
-		c.JSON(401, gin.H{"error": "Missing authentication token"})
+	h.commitFile(c, client, owner, repo, path, req.Content, req.Message, req.Branch, req.SHA, req.Overwrite)
+}
+
+// commitFile 按 If-Match/sha/overwrite 前置条件写入文件并写出响应
+// 写入经写队列与同一分支上的其他写入串行执行
+func (h *FilesHandler) commitFile(c *gin.Context, client storage.Backend, owner, repo, path, content, message, branch, bodySHA string, overwrite bool) {
+	write := newFileWrite(c, path, content, message, bodySHA, overwrite)
+	result, err := h.backends.writes.Submit(c.Request.Context(), client, h.backends.writeTarget(c, owner, repo, branch), h.backends.writeIdentity(c), write)
+	if err != nil {
+		respondWriteError(c, err)
 		return
 	}
 
-	// 从请求头获取代理配置
-	proxyConfig := getProxyConfigFromHeader(c)
+	c.Header("ETag", `"`+result.File.SHA+`"`)
+	middleware.Success(c, result.File, "File created or updated successfully")
+}
+
+// newFileWrite 按 If-Match/sha/overwrite 构造写入
+func newFileWrite(c *gin.Context, path, content, message, bodySHA string, overwrite bool) writequeue.Write {
+	// If-Match 优先于请求体中的 sha
+	expectedSHA := parseIfMatch(c.GetHeader("If-Match"))
+	if expectedSHA == "" {
+		expectedSHA = bodySHA
+	}
+	// If-Match: * 表示覆盖任意已有版本
+	overwrite = overwrite || c.Query("overwrite") == "true" || strings.TrimSpace(c.GetHeader("If-Match")) == "*"
+
+	return writequeue.Write{
+		Path:        path,
+		Content:     content,
+		Message:     message,
+		ExpectedSHA: expectedSHA,
+		Overwrite:   overwrite,
+	}
+}
 
-	// 创建临时GitHub客户端
-	client, err := github.NewClient(userToken, proxyConfig)
+// checkWritePrecondition 在上传大文件附件之前检查前置条件，避免写入被拒绝时留下无人引用的附件
+// 提交时写队列仍会再次检查
+func checkWritePrecondition(c *gin.Context, client storage.Backend, owner, repo, branch string, write writequeue.Write) bool {
+	current, err := client.GetFileSHA(c.Request.Context(), owner, repo, write.Path, branch)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create GitHub client"})
+		c.Error(err)
+		return false
+	}
+	if err := writequeue.CheckPrecondition(write, current); err != nil {
+		respondWriteError(c, err)
+		return false
+	}
+	return true
+}
+
+// respondWriteError 写出写队列返回的错误，前置条件不符时返回 409 和文件的当前 SHA
+func respondWriteError(c *gin.Context, err error) {
+	var conflict *writequeue.ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		// 文件在此期间被修改，或未带前置条件就试图覆盖已有文件
+		message := "File has been modified"
+		if conflict.ExpectedSHA == "" {
+			message = "File already exists, send If-Match or set overwrite to replace it"
+		}
+		if conflict.CurrentSHA != "" {
+			c.Header("ETag", `"`+conflict.CurrentSHA+`"`)
+		}
+		middleware.Error(c, 409, message, gin.H{"currentSha": conflict.CurrentSHA})
+	case errors.Is(err, writequeue.ErrRefConflict):
+		middleware.Error(c, 409, "Branch is being updated by another client, please retry", gin.H{"error": err.Error()})
+	case errors.Is(err, writequeue.ErrFileNotFound):
+		c.JSON(404, gin.H{"error": "File not found"})
+	default:
+		c.Error(err)
+	}
+}
+
+// parseIfMatch 从 If-Match 头中取出 SHA
+func parseIfMatch(header string) string {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return ""
+	}
+	header = strings.TrimPrefix(header, "W/")
+	return strings.Trim(header, `"`)
+}
+
+// DeleteFile 删除文件
+func (h *FilesHandler) DeleteFile(c *gin.Context) {
+	// 根据配置选择存储后端
+	client, ok := h.backends.backendFromRequest(c)
+	if !ok {
 		return
 	}
 
@@ -227,6 +328,10 @@ func (h *FilesHandler) DeleteFile(c *gin.Context) {
 	// Gin 的 *path 参数会包含开头的斜杠，需要移除
 	path = strings.TrimPrefix(path, "/")
 
+	if !requireKeyScope(c, owner, repo, path, auth.OperationDelete) {
+		return
+	}
+
 	var req struct {
 		SHA     string `json:"sha" binding:"required"`
 		Message string `json:"message" binding:"required"`
@@ -234,22 +339,84 @@ func (h *FilesHandler) DeleteFile(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.Error(err)
+		c.Error(middleware.BindError(err))
 		return
 	}
 
-	err = client.DeleteFile(owner, repo, path, req.SHA, req.Message, req.Branch)
+	if !requirePermission(c, client, owner, repo, github.PermissionWrite) {
+		return
+	}
+
+	_, err := h.backends.writes.Submit(c.Request.Context(), client, h.backends.writeTarget(c, owner, repo, req.Branch), h.backends.writeIdentity(c), writequeue.Write{
+		Path:        path,
+		Delete:      true,
+		Message:     req.Message,
+		ExpectedSHA: req.SHA,
+	})
 	if err != nil {
-		c.Error(err)
+		respondWriteError(c, err)
 		return
 	}
 
 	middleware.Success(c, gin.H{"message": "File deleted successfully"}, "File deleted successfully")
 }
 
+// maxListedCommits 不分页时最多返回的提交数，超过时响应带 X-Truncated: true
+const maxListedCommits = 1000
+
+// ListCommits 列出文件或目录的提交历史
+func (h *FilesHandler) ListCommits(c *gin.Context) {
+	client, ok := h.backends.readBackendFromRequest(c)
+	if !ok {
+		return
+	}
+
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	path := strings.TrimPrefix(c.Param("path"), "/")
+
+	if !requireKeyScope(c, owner, repo, path, auth.OperationRead) {
+		return
+	}
+
+	// 能按段读取历史的后端只请求覆盖当前页的上游分页
+	if pager, ok := client.(storage.CommitPager); ok {
+		req, err := parsePageRequest(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		offset, limit := req.Offset, req.PerPage
+		if req.All {
+			offset, limit = 0, maxListedCommits
+		}
+		commits, more, err := pager.ListCommitsPage(c.Request.Context(), owner, repo, path, c.Query("ref"), offset, limit)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		paginateWindow(c, req, offset, len(commits), more)
+		middleware.Success(c, commits, "Commits listed successfully")
+		return
+	}
+
+	commits, err := client.ListCommits(c.Request.Context(), owner, repo, path, c.Query("ref"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	page, ok := paginate(c, commits)
+	if !ok {
+		return
+	}
+
+	middleware.Success(c, page, "Commits listed successfully")
+}
+
 // RegisterFilesRoutes 注册文件相关的路由
-func RegisterFilesRoutes(router *gin.RouterGroup, token string, proxyConfig proxy.ProxyConfig) error {
-	handler, err := NewFilesHandler(token, proxyConfig)
+func RegisterFilesRoutes(router *gin.RouterGroup, token string, proxyConfig proxy.ProxyConfig, backends *backendProvider) error {
+	handler, err := NewFilesHandler(token, proxyConfig, backends)
 	if err != nil {
 		return err
 	}
@@ -258,6 +425,9 @@ func RegisterFilesRoutes(router *gin.RouterGroup, token string, proxyConfig prox
 	router.GET("/file/:owner/:repo/*path", handler.GetFileContent)
 	router.PUT("/file/:owner/:repo/*path", handler.CreateOrUpdateFile)
 	router.DELETE("/file/:owner/:repo/*path", handler.DeleteFile)
+	router.GET("/raw/:owner/:repo/*path", handler.DownloadFile)
+	router.PUT("/raw/:owner/:repo/*path", handler.UploadFile)
+	router.GET("/history/:owner/:repo/*path", handler.ListCommits)
 
 	return nil
 }
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLargeObjectPreconditionCheckedBeforeUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var mu sync.Mutex
+	var releaseRequests []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/releases") {
+			mu.Lock()
+			releaseRequests = append(releaseRequests, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/alice/drive":
+			fmt.Fprint(w, `{"name":"drive","default_branch":"main","permissions":{"pull":true,"push":true}}`)
+		case "/repos/alice/drive/contents/big.bin":
+			fmt.Fprint(w, `{"name":"big.bin","path":"big.bin","sha":"current","type":"file"}`)
+		default:
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	t.Setenv("STORAGE_BACKEND", "")
+	t.Setenv("USERS_FILE", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("AUDIT_LOG_FILE", "off")
+	t.Setenv("GITHUB_API_URL", upstream.URL)
+	t.Setenv("GITHUB_UPLOAD_URL", upstream.URL)
+	t.Setenv("LARGE_OBJECT_THRESHOLD", "16")
+
+	server := NewServer()
+	if err := server.RegisterRoutes(); err != nil {
+		t.Fatalf("RegisterRoutes: %v", err)
+	}
+	h := server.GetRouter()
+
+	content := bytes.Repeat([]byte("x"), 64)
+	for _, tt := range []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{"JSON without sha", "PUT", "/api/file/alice/drive/big.bin",
+			fmt.Sprintf(`{"content":%q,"message":"add"}`, base64.StdEncoding.EncodeToString(content))},
+		{"JSON with stale sha", "PUT", "/api/file/alice/drive/big.bin",
+			fmt.Sprintf(`{"content":%q,"message":"add","sha":"stale"}`, base64.StdEncoding.EncodeToString(content))},
+		{"raw without sha", "PUT", "/api/raw/alice/drive/big.bin", string(content)},
+	} {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "token user-token")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%s = %d %s, want 409", tt.name, rec.Code, rec.Body.String())
+		}
+	}
+
+	if len(releaseRequests) != 0 {
+		t.Fatalf("rejected writes reached the release API: %v", releaseRequests)
+	}
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newLocalTestServer 以本地存储后端启动服务器，不访问网络
+func newLocalTestServer(t *testing.T, env map[string]string) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	t.Setenv("STORAGE_BACKEND", "local")
+	t.Setenv("LOCAL_STORAGE_DIR", t.TempDir())
+	t.Setenv("AUDIT_LOG_FILE", "off")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	server := NewServer()
+	if err := server.RegisterRoutes(); err != nil {
+		t.Fatalf("RegisterRoutes: %v", err)
+	}
+	return server.GetRouter()
+}
+
+// doJSON 发送 JSON 请求并解析统一响应中的 data
+func doJSON(t *testing.T, h http.Handler, method, target string, body interface{}) (int, json.RawMessage) {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp.Data
+}
+
+func TestLocalStorageRequiresAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("STORAGE_BACKEND", "local")
+	t.Setenv("LOCAL_STORAGE_DIR", t.TempDir())
+	t.Setenv("AUDIT_LOG_FILE", "off")
+	t.Setenv("USERS_FILE", "")
+	t.Setenv("LOCAL_STORAGE_NO_AUTH", "")
+
+	if err := NewServer().RegisterRoutes(); err == nil {
+		t.Fatal("local storage without USERS_FILE or LOCAL_STORAGE_NO_AUTH should fail to start")
+	}
+}
+
+func TestLocalStorageRejectsAnonymousRequestsWithAccounts(t *testing.T) {
+	h := newLocalTestServer(t, map[string]string{
+		"USERS_FILE":     filepath.Join(t.TempDir(), "users.json"),
+		"ADMIN_USERNAME": "admin",
+		"ADMIN_PASSWORD": "correct horse battery",
+	})
+
+	if code, _ := doJSON(t, h, "GET", "/api/repos", nil); code != http.StatusUnauthorized {
+		t.Fatalf("GET /api/repos without session = %d, want 401", code)
+	}
+	if code, _ := doJSON(t, h, "POST", "/api/repos", gin.H{"name": "drive", "auto_init": true}); code != http.StatusUnauthorized {
+		t.Fatalf("POST /api/repos without session = %d, want 401", code)
+	}
+}
+
+func TestLocalStorageEndToEnd(t *testing.T) {
+	h := newLocalTestServer(t, map[string]string{
+		"USERS_FILE":            "",
+		"LOCAL_STORAGE_NO_AUTH": "true",
+	})
+
+	if code, _ := doJSON(t, h, "POST", "/api/repos", gin.H{"name": "drive", "auto_init": true}); code != http.StatusOK {
+		t.Fatalf("create repository = %d", code)
+	}
+	if code, _ := doJSON(t, h, "POST", "/api/repos", gin.H{"name": "drive"}); code != http.StatusConflict {
+		t.Fatalf("create existing repository = %d, want 409", code)
+	}
+
+	// 新建文件
+	content := base64.StdEncoding.EncodeToString([]byte("hello"))
+	code, data := doJSON(t, h, "PUT", "/api/file/local/drive/docs/a.txt", gin.H{"content": content, "message": "add a.txt"})
+	if code != http.StatusOK {
+		t.Fatalf("create file = %d", code)
+	}
+	var created struct {
+		SHA string `json:"sha"`
+	}
+	json.Unmarshal(data, &created)
+	if created.SHA == "" {
+		t.Fatalf("create file returned no sha: %s", data)
+	}
+
+	// 未带 sha 再次写入同一路径应冲突
+	if code, _ := doJSON(t, h, "PUT", "/api/file/local/drive/docs/a.txt", gin.H{"content": content, "message": "again"}); code != http.StatusConflict {
+		t.Fatalf("overwrite without sha = %d, want 409", code)
+	}
+
+	// 带 sha 更新
+	updated := base64.StdEncoding.EncodeToString([]byte("hello, world"))
+	code, data = doJSON(t, h, "PUT", "/api/file/local/drive/docs/a.txt", gin.H{"content": updated, "message": "update a.txt", "sha": created.SHA})
+	if code != http.StatusOK {
+		t.Fatalf("update file = %d", code)
+	}
+	json.Unmarshal(data, &created)
+
+	// 列目录
+	code, data = doJSON(t, h, "GET", "/api/files/local/drive/docs", nil)
+	if code != http.StatusOK {
+		t.Fatalf("list files = %d", code)
+	}
+	var entries []struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(data, &entries)
+	if len(entries) != 1 || entries[0].Name != "a.txt" {
+		t.Fatalf("list files = %s", data)
+	}
+
+	// 读取内容
+	req := httptest.NewRequest("GET", "/api/raw/local/drive/docs/a.txt", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello, world" {
+		t.Fatalf("download = %d %q", rec.Code, rec.Body.String())
+	}
+
+	// 历史
+	code, data = doJSON(t, h, "GET", "/api/history/local/drive/docs/a.txt", nil)
+	if code != http.StatusOK {
+		t.Fatalf("history = %d", code)
+	}
+	var commits []json.RawMessage
+	json.Unmarshal(data, &commits)
+	if len(commits) != 2 {
+		t.Fatalf("history has %d commits, want 2: %s", len(commits), data)
+	}
+
+	// 删除后读取返回 404
+	if code, _ := doJSON(t, h, "DELETE", "/api/file/local/drive/docs/a.txt", gin.H{"sha": created.SHA, "message": "remove a.txt"}); code != http.StatusOK {
+		t.Fatalf("delete file = %d", code)
+	}
+	if code, _ := doJSON(t, h, "GET", "/api/file/local/drive/docs/a.txt", nil); code != http.StatusNotFound {
+		t.Fatalf("get deleted file = %d, want 404", code)
+	}
+	if code, _ := doJSON(t, h, "GET", "/api/files/local/missing/", nil); code != http.StatusNotFound {
+		t.Fatalf("list missing repository = %d, want 404", code)
+	}
+}
+
+func TestLocalStorageRejectsOptionLikeRefs(t *testing.T) {
+	h := newLocalTestServer(t, map[string]string{
+		"USERS_FILE":            "",
+		"LOCAL_STORAGE_NO_AUTH": "true",
+	})
+
+	if code, _ := doJSON(t, h, "POST", "/api/repos", gin.H{"name": "drive", "auto_init": true}); code != http.StatusOK {
+		t.Fatalf("create repository = %d", code)
+	}
+
+	target := filepath.Join(t.TempDir(), "pwned")
+	for _, path := range []string{
+		"/api/history/local/drive/README.md?ref=--output=" + target,
+		"/api/files/local/drive/?ref=--output=" + target,
+		"/api/file/local/drive/README.md?ref=--output=" + target,
+	} {
+		if code, _ := doJSON(t, h, "GET", path, nil); code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", path, code)
+		}
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("option-like ref wrote %s", target)
+	}
+}
+
+func TestInvalidRequestBodyIsBadRequest(t *testing.T) {
+	h := newLocalTestServer(t, map[string]string{
+		"USERS_FILE":            "",
+		"LOCAL_STORAGE_NO_AUTH": "true",
+	})
+
+	// 缺少必填字段
+	if code, _ := doJSON(t, h, "POST", "/api/repos", gin.H{"description": "no name"}); code != http.StatusBadRequest {
+		t.Fatalf("missing name = %d, want 400", code)
+	}
+
+	// 请求体不是 JSON
+	req := httptest.NewRequest("PUT", "/api/file/local/drive/a.txt", bytes.NewReader([]byte("{not json")))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed body = %d, want 400", rec.Code)
+	}
+}
+
+func TestLocalHistoryPagination(t *testing.T) {
+	h := newLocalTestServer(t, map[string]string{
+		"USERS_FILE":            "",
+		"LOCAL_STORAGE_NO_AUTH": "true",
+	})
+
+	if code, _ := doJSON(t, h, "POST", "/api/repos", gin.H{"name": "drive", "auto_init": true}); code != http.StatusOK {
+		t.Fatalf("create repository = %d", code)
+	}
+	code, data := doJSON(t, h, "GET", "/api/file/local/drive/README.md", nil)
+	if code != http.StatusOK {
+		t.Fatalf("get README.md = %d", code)
+	}
+	var file struct {
+		SHA string `json:"sha"`
+	}
+	json.Unmarshal(data, &file)
+	for i := 0; i < 4; i++ {
+		content := base64.StdEncoding.EncodeToString([]byte{byte('a' + i)})
+		code, data := doJSON(t, h, "PUT", "/api/file/local/drive/README.md", gin.H{"content": content, "message": "edit", "sha": file.SHA})
+		if code != http.StatusOK {
+			t.Fatalf("update %d = %d", i, code)
+		}
+		json.Unmarshal(data, &file)
+	}
+
+	// 逐页读取 5 个提交，总数在最后一页才确定
+	var seen int
+	target := "/api/history/local/drive/README.md?per_page=2"
+	for target != "" {
+		req := httptest.NewRequest("GET", target, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d", target, rec.Code)
+		}
+		var resp struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		seen += len(resp.Data)
+
+		next := rec.Header().Get("X-Next-Cursor")
+		total := rec.Header().Get("X-Total-Count")
+		if next != "" && total != "" {
+			t.Fatalf("X-Total-Count %s sent before the last page", total)
+		}
+		if next == "" && total != "5" {
+			t.Fatalf("last page X-Total-Count = %q, want 5", total)
+		}
+		target = ""
+		if next != "" {
+			target = "/api/history/local/drive/README.md?per_page=2&cursor=" + next
+		}
+	}
+	if seen != 5 {
+		t.Fatalf("read %d commits, want 5", seen)
+	}
+}
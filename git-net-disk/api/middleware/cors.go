@@ -5,11 +5,21 @@ import (
 )
 
 // CORSMiddleware 处理跨域请求
+// CORS_ALLOWED_ORIGINS 列出的来源（逗号分隔）允许携带会话 cookie，其他来源只能使用 Authorization 头
 func CORSMiddleware() gin.HandlerFunc {
+	allowedOrigins := allowedOriginsFromEnv()
+
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, x-proxy-url")
+		if origin := c.GetHeader("Origin"); allowedOrigins[origin] {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+			c.Header("Vary", "Origin")
+		} else {
+			c.Header("Access-Control-Allow-Origin", "*")
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, x-proxy-url, If-Match, X-Storage-Account, X-Storage-Backend, X-Storage-URL")
+		c.Header("Access-Control-Expose-Headers", "ETag, X-Request-Id, X-Total-Count, X-Next-Cursor, Link, Retry-After, X-GitHub-RateLimit-Limit, X-GitHub-RateLimit-Remaining, X-GitHub-RateLimit-Reset")
 		c.Header("Access-Control-Max-Age", "86400")
 
 		if c.Request.Method == "OPTIONS" {
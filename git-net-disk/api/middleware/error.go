@@ -1,12 +1,50 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 响应中的机器可读错误码，客户端应按错误码而不是 message 判断错误类型
+const (
+	CodeBadRequest             = "bad_request"
+	CodeUnauthorized           = "unauthorized"
+	CodeForbidden              = "forbidden"
+	CodeInsufficientPermission = "insufficient_permission"
+	CodeNotFound               = "not_found"
+	CodeConflict               = "conflict"
+	CodePreconditionFailed     = "precondition_failed"
+	CodeValidationFailed       = "validation_failed"
+	CodePayloadTooLarge        = "payload_too_large"
+	CodeRateLimited            = "rate_limited"
+	CodeUpstreamError          = "upstream_error"
+	CodeUpstreamUnavailable    = "upstream_unavailable"
+	CodeTimeout                = "timeout"
+	CodeRequestCanceled        = "request_canceled"
+	CodeInternal               = "internal_error"
+)
+
+// statusClientClosedRequest 客户端在响应前断开连接，沿用 nginx 的 499，只出现在日志和审计记录中
+const statusClientClosedRequest = 499
+
+// upstreamDetails GitHub 或其他存储后端返回错误时响应中的详情
+type upstreamDetails struct {
+	Error string `json:"error"`
+	// UpstreamStatus 上游返回的状态码
+	UpstreamStatus   int                 `json:"upstream_status"`
+	Errors           []github.FieldError `json:"errors,omitempty"`
+	DocumentationURL string              `json:"documentation_url,omitempty"`
+	GitHubRequestID  string              `json:"github_request_id,omitempty"`
+}
+
 // ErrorMiddleware 错误处理中间件
 func ErrorMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -21,9 +59,159 @@ func ErrorMiddleware() gin.HandlerFunc {
 			err := c.Errors.Last()
 			fmt.Printf("[%s] 全局错误: %v\n", requestID, err)
 
-			Error(c, http.StatusInternalServerError, "服务器内部错误", gin.H{
-				"error": err.Error(),
-			})
+			// 流式响应中途失败时已无法改写状态码，例如客户端取消下载
+			if c.Writer.Written() {
+				return
+			}
+
+			// GitHub 配额耗尽时告知客户端何时重试
+			var rateLimit *github.RateLimitError
+			if errors.As(err.Err, &rateLimit) {
+				c.Header("Retry-After", strconv.Itoa(int(rateLimit.RetryAfter().Seconds())+1))
+			}
+
+			status, code, message, details := classifyError(err.Err)
+			ErrorWithCode(c, status, code, message, details)
 		}
 	}
 }
+
+// BindError 把请求体解析或校验失败的错误归类为 ErrInvalid，由 ErrorMiddleware 返回 400
+func BindError(err error) error {
+	return fmt.Errorf("%w: %w", github.ErrInvalid, err)
+}
+
+// StatusForError 返回 ErrorMiddleware 为错误写出的状态码，供需要在响应写出前得知结果的中间件使用
+func StatusForError(err error) int {
+	status, _, _, _ := classifyError(err)
+	return status
+}
+
+// classifyError 把处理器返回的错误映射为 HTTP 状态码、错误码、消息和详情
+func classifyError(err error) (status int, code string, message string, details interface{}) {
+	// 操作超过时限或客户端已断开时，上游请求随上下文取消
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, CodeTimeout, "操作超时", gin.H{"error": err.Error()}
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest, CodeRequestCanceled, "请求已取消", gin.H{"error": err.Error()}
+	}
+
+	// GitHub 配额耗尽时返回 429
+	var rateLimit *github.RateLimitError
+	if errors.As(err, &rateLimit) {
+		return http.StatusTooManyRequests, CodeRateLimited, rateLimit.Error(), gin.H{
+			"error":    rateLimit.Error(),
+			"reset_at": rateLimit.Reset,
+		}
+	}
+
+	// token 缺少作用域或仓库权限时返回 403，并说明如何补齐
+	var permission *github.PermissionError
+	if errors.As(err, &permission) {
+		return http.StatusForbidden, CodeInsufficientPermission, permission.Error(), permission
+	}
+
+	details = gin.H{"error": err.Error()}
+	var apiErr *github.APIError
+	if errors.As(err, &apiErr) {
+		details = upstreamDetails{
+			Error:            err.Error(),
+			UpstreamStatus:   apiErr.StatusCode,
+			Errors:           apiErr.Errors,
+			DocumentationURL: apiErr.DocumentationURL,
+			GitHubRequestID:  apiErr.RequestID,
+		}
+	}
+	var backendErr *storage.APIError
+	if errors.As(err, &backendErr) {
+		details = upstreamDetails{Error: err.Error(), UpstreamStatus: backendErr.StatusCode}
+	}
+
+	// 仓库接口已把常见状态码归类为哨兵错误
+	switch {
+	case errors.Is(err, github.ErrTokenInvalid):
+		return http.StatusUnauthorized, CodeUnauthorized, err.Error(), details
+	case errors.Is(err, github.ErrRepositoryNotFound):
+		return http.StatusNotFound, CodeNotFound, err.Error(), details
+	case errors.Is(err, github.ErrRepositoryForbidden):
+		return http.StatusForbidden, CodeForbidden, err.Error(), details
+	case errors.Is(err, github.ErrRepositoryInvalid):
+		return http.StatusUnprocessableEntity, CodeValidationFailed, err.Error(), details
+	}
+
+	// 各存储后端共用的哨兵错误
+	switch {
+	case errors.Is(err, github.ErrLargeObjectTooLarge):
+		return http.StatusRequestEntityTooLarge, CodePayloadTooLarge, err.Error(), details
+	case errors.Is(err, github.ErrNotFound):
+		return http.StatusNotFound, CodeNotFound, err.Error(), details
+	case errors.Is(err, github.ErrConflict):
+		return http.StatusConflict, CodeConflict, err.Error(), details
+	case errors.Is(err, github.ErrForbidden):
+		return http.StatusForbidden, CodeForbidden, err.Error(), details
+	case errors.Is(err, github.ErrInvalid):
+		return http.StatusBadRequest, CodeBadRequest, err.Error(), details
+	}
+
+	if apiErr != nil {
+		status = statusForUpstream(apiErr.StatusCode)
+		return status, codeForStatus(status), err.Error(), details
+	}
+	if backendErr != nil {
+		status = statusForUpstream(backendErr.StatusCode)
+		return status, codeForStatus(status), err.Error(), details
+	}
+
+	return http.StatusInternalServerError, CodeInternal, "服务器内部错误", details
+}
+
+// statusForUpstream 把 GitHub 的状态码转换为返回给客户端的状态码
+// 客户端错误原样返回，GitHub 自身的故障返回 502 或 503，避免与本服务的 500 混淆
+func statusForUpstream(status int) int {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+		http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity, http.StatusTooManyRequests:
+		return status
+	case http.StatusGone:
+		return http.StatusNotFound
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// codeForStatus 返回状态码对应的默认错误码
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUpstreamUnavailable
+	case http.StatusBadGateway:
+		return CodeUpstreamError
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
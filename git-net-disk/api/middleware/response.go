@@ -11,6 +11,7 @@ type Response struct {
 	Code      int         `json:"code"`
 	Success   bool        `json:"success"`
 	Message   string      `json:"message"`
+	ErrorCode string      `json:"errorCode,omitempty"` // 失败时的机器可读错误码，见 error.go 中的 Code* 常量
 	Data      interface{} `json:"data,omitempty"`
 	Details   interface{} `json:"details,omitempty"`
 	Timestamp int64       `json:"timestamp"`
@@ -46,8 +47,13 @@ func Success(c *gin.Context, data interface{}, message string) {
 	})
 }
 
-// Error 错误响应
+// Error 错误响应，错误码按状态码推断
 func Error(c *gin.Context, code int, message string, details interface{}) {
+	ErrorWithCode(c, code, codeForStatus(code), message, details)
+}
+
+// ErrorWithCode 带机器可读错误码的错误响应
+func ErrorWithCode(c *gin.Context, code int, errorCode string, message string, details interface{}) {
 	requestID := c.GetString("requestId")
 	if requestID == "" {
 		requestID = "unknown"
@@ -62,6 +68,7 @@ func Error(c *gin.Context, code int, message string, details interface{}) {
 		Code:      code,
 		Success:   false,
 		Message:   message,
+		ErrorCode: errorCode,
 		Details:   details,
 		Timestamp: time.Now().Unix(),
 		RequestID: requestID,
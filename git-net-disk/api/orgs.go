@@ -0,0 +1,182 @@
+package api
+
+import (
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/github"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrgsHandler 组织（团队网盘）相关的 API 处理器
+type OrgsHandler struct {
+	backends *backendProvider
+}
+
+// NewOrgsHandler 创建新的组织处理器
+func NewOrgsHandler(backends *backendProvider) *OrgsHandler {
+	return &OrgsHandler{
+		backends: backends,
+	}
+}
+
+// ListOrganizations 列出当前用户所属的组织
+func (h *OrgsHandler) ListOrganizations(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	orgs, err := client.ListOrganizations(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, orgs, "Organizations listed successfully")
+}
+
+// ListOrgRepositories 列出组织的仓库，过滤、排序和分页参数与 GET /repos 相同
+func (h *OrgsHandler) ListOrgRepositories(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	repos, err := client.ListOrgRepositories(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondRepositories(c, client, repos)
+}
+
+// CreateOrgRepository 在组织下创建团队网盘
+func (h *OrgsHandler) CreateOrgRepository(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Private     bool   `json:"private"`
+		AutoInit    bool   `json:"auto_init"`
+		// Team 和 Permission 可选，创建后授予该团队指定权限
+		Team       string `json:"team"`
+		Permission string `json:"permission"`
+		// Drive 非空时写入网盘设置文件，将仓库标记为网盘
+		Drive *github.DriveSettings `json:"drive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+	if req.Team != "" && req.Permission == "" {
+		req.Permission = "write"
+	}
+	if req.Team != "" && !github.ValidCollaboratorPermission(req.Permission) {
+		c.JSON(400, gin.H{"error": "permission must be read, write or admin"})
+		return
+	}
+
+	org := c.Param("org")
+	setAuditTarget(c, org, req.Name)
+	repo, err := client.CreateOrgRepository(c.Request.Context(), org, req.Name, req.Description, req.Private, req.AutoInit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if req.Drive != nil {
+		if err := writeDriveSettings(c.Request.Context(), client, org, repo.Name, req.Drive, "Initialize drive settings"); err != nil {
+			c.Error(err)
+			return
+		}
+		repo.Drive = req.Drive
+	}
+
+	if req.Team != "" {
+		if err := client.SetTeamRepoPermission(c.Request.Context(), org, req.Team, repo.Name, req.Permission); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	middleware.Success(c, repo, "Repository created successfully")
+}
+
+// ListTeams 列出组织中的团队
+func (h *OrgsHandler) ListTeams(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	teams, err := client.ListTeams(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, teams, "Teams listed successfully")
+}
+
+// SetTeamRepoPermission 设置团队对组织仓库的权限
+func (h *OrgsHandler) SetTeamRepoPermission(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Permission string `json:"permission" binding:"required"` // read、write 或 admin
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+	if !github.ValidCollaboratorPermission(req.Permission) {
+		c.JSON(400, gin.H{"error": "permission must be read, write or admin"})
+		return
+	}
+
+	if err := client.SetTeamRepoPermission(c.Request.Context(), c.Param("org"), c.Param("team"), c.Param("repo"), req.Permission); err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"permission": req.Permission}, "Team permission updated successfully")
+}
+
+// RemoveTeamRepo 取消团队对组织仓库的访问
+func (h *OrgsHandler) RemoveTeamRepo(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := client.RemoveTeamRepo(c.Request.Context(), c.Param("org"), c.Param("team"), c.Param("repo")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"message": "Team access removed successfully"}, "Team access removed successfully")
+}
+
+// RegisterOrgsRoutes 注册组织相关的路由
+func RegisterOrgsRoutes(router *gin.RouterGroup, backends *backendProvider) error {
+	handler := NewOrgsHandler(backends)
+
+	router.GET("/orgs", handler.ListOrganizations)
+	router.GET("/orgs/:org/repos", handler.ListOrgRepositories)
+	router.POST("/orgs/:org/repos", handler.CreateOrgRepository)
+	router.GET("/orgs/:org/teams", handler.ListTeams)
+	router.PUT("/orgs/:org/teams/:team/repos/:repo", handler.SetTeamRepoPermission)
+	router.DELETE("/orgs/:org/teams/:team/repos/:repo", handler.RemoveTeamRepo)
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 分页参数的默认值和上限
+// maxPage 保证 (page-1)*per_page 不会溢出，也远大于任何上游列表的长度
+const (
+	defaultPerPage = 30
+	maxPerPage     = 1000
+	maxPage        = 1000000
+	maxOffset      = maxPage * maxPerPage
+)
+
+// pageRequest 列表接口的分页参数
+// 请求不带 page、per_page、cursor 时返回全部结果（all 模式），与旧版接口保持一致
+// 仓库和目录列表的分页在本服务内完成，处理器先从上游读取完整列表再截取；
+// 提交历史由 paginateWindow 处理，只读取覆盖当前页的上游分页
+type pageRequest struct {
+	All     bool
+	Offset  int
+	PerPage int
+}
+
+// parsePageRequest 解析 page、per_page、cursor 和 all 查询参数，cursor 优先于 page
+func parsePageRequest(c *gin.Context) (pageRequest, error) {
+	req := pageRequest{PerPage: defaultPerPage}
+
+	pageParam, perPageParam, cursor := c.Query("page"), c.Query("per_page"), c.Query("cursor")
+	if c.Query("all") == "true" || (pageParam == "" && perPageParam == "" && cursor == "") {
+		req.All = true
+		return req, nil
+	}
+
+	if perPageParam != "" {
+		perPage, err := strconv.Atoi(perPageParam)
+		if err != nil || perPage < 1 || perPage > maxPerPage {
+			return req, fmt.Errorf("per_page must be between 1 and %d", maxPerPage)
+		}
+		req.PerPage = perPage
+	}
+
+	switch {
+	case cursor != "":
+		offset, err := decodeCursor(cursor)
+		if err != nil {
+			return req, err
+		}
+		req.Offset = offset
+	case pageParam != "":
+		page, err := strconv.Atoi(pageParam)
+		if err != nil || page < 1 || page > maxPage {
+			return req, fmt.Errorf("page must be between 1 and %d", maxPage)
+		}
+		req.Offset = (page - 1) * req.PerPage
+	}
+	return req, nil
+}
+
+// paginate 按请求参数截取当前页，并写入 X-Total-Count、X-Next-Cursor 和 Link 响应头
+// items 必须是完整列表，X-Total-Count 才准确；参数错误时已写入 400 响应
+func paginate[T any](c *gin.Context, items []T) ([]T, bool) {
+	req, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(len(items)))
+	if req.All {
+		return items, true
+	}
+
+	start := req.Offset
+	if start < 0 {
+		start = 0
+	}
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + req.PerPage
+	if end > len(items) {
+		end = len(items)
+	}
+
+	writePageLinks(c, req, start, end, end < len(items))
+	return items[start:end], true
+}
+
+// paginateWindow 为只读取了当前页的列表写入分页响应头，start 为当前页的偏移，n 为当前页的条数
+// 只有确定没有更多条目时才写入 X-Total-Count；all 模式下结果被截断时写入 X-Truncated: true
+func paginateWindow(c *gin.Context, req pageRequest, start, n int, more bool) {
+	if !more {
+		c.Header("X-Total-Count", strconv.Itoa(start+n))
+	}
+	if req.All {
+		if more {
+			c.Header("X-Truncated", "true")
+		}
+		return
+	}
+	writePageLinks(c, req, start, start+n, more)
+}
+
+// writePageLinks 写入 X-Next-Cursor 和 Link 响应头，当前页为 [start, end)
+func writePageLinks(c *gin.Context, req pageRequest, start, end int, hasNext bool) {
+	var links []string
+	if hasNext {
+		next := encodeCursor(end)
+		c.Header("X-Next-Cursor", next)
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageLink(c, next, req.PerPage)))
+	}
+	if start > 0 {
+		prev := start - req.PerPage
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageLink(c, encodeCursor(prev), req.PerPage)))
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLink 以当前请求为基础构造指向另一页的地址
+func pageLink(c *gin.Context, cursor string, perPage int) string {
+	query := c.Request.URL.Query()
+	query.Del("page")
+	query.Set("cursor", cursor)
+	query.Set("per_page", strconv.Itoa(perPage))
+	return c.Request.URL.Path + "?" + query.Encode()
+}
+
+// encodeCursor 将偏移量编码为不透明的游标
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodeCursor 解析 encodeCursor 生成的游标
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(data), "offset:"))
+	if err != nil || offset < 0 || offset > maxOffset || !strings.HasPrefix(string(data), "offset:") {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return offset, nil
+}
+
+// parseSortDirection 解析 direction 参数，为空时使用 defaultDesc 指定的方向
+func parseSortDirection(c *gin.Context, defaultDesc bool) (bool, error) {
+	switch c.Query("direction") {
+	case "":
+		return defaultDesc, nil
+	case "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("direction must be asc or desc")
+	}
+}
+
+// parseTimeParam 解析 RFC3339 或 YYYY-MM-DD 格式的时间参数，为空时返回零值
+func parseTimeParam(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be RFC3339 or YYYY-MM-DD", name)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runPaginate 以给定查询参数对 0..n-1 分页
+func runPaginate(t *testing.T, query string, n int) (*httptest.ResponseRecorder, []int) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	items := make([]int, n)
+	for i := range items {
+		items[i] = i
+	}
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest("GET", "/api/repos?"+query, nil)
+	page, ok := paginate(c, items)
+	if !ok {
+		return rec, nil
+	}
+	return rec, page
+}
+
+func TestPaginatePage(t *testing.T) {
+	rec, page := runPaginate(t, "page=2&per_page=3", 10)
+	if len(page) != 3 || page[0] != 3 {
+		t.Fatalf("page = %v", page)
+	}
+	if rec.Header().Get("X-Total-Count") != "10" || rec.Header().Get("X-Next-Cursor") != encodeCursor(6) {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+
+	_, page = runPaginate(t, "page=5&per_page=3", 10)
+	if len(page) != 0 {
+		t.Fatalf("page past the end = %v", page)
+	}
+}
+
+func TestPaginateRejectsOverflowingPage(t *testing.T) {
+	for _, query := range []string{
+		"page=" + strconv.Itoa(math.MaxInt) + "&per_page=1000",
+		"page=" + strconv.Itoa(maxPage+1),
+		"page=0",
+		"cursor=" + encodeCursor(math.MaxInt),
+	} {
+		rec, _ := runPaginate(t, query, 10)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", query, rec.Code)
+		}
+	}
+
+	// 上限内的最后一页不会溢出，只返回空页
+	rec, page := runPaginate(t, "page="+strconv.Itoa(maxPage)+"&per_page="+strconv.Itoa(maxPerPage), 10)
+	if rec.Code != http.StatusOK || len(page) != 0 {
+		t.Fatalf("last allowed page = %d %v", rec.Code, page)
+	}
+}
+
+func TestPaginateWindow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query     string
+		start, n  int
+		more      bool
+		total     string
+		truncated string
+		next      bool
+	}{
+		{"per_page=10", 0, 10, true, "", "", true},
+		{"per_page=10&page=3", 20, 4, false, "24", "", false},
+		{"", 0, 1000, true, "", "true", false},
+		{"", 0, 42, false, "42", "", false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("GET", "/api/history/o/r/a.txt?"+tt.query, nil)
+		req, err := parsePageRequest(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paginateWindow(c, req, tt.start, tt.n, tt.more)
+
+		header := rec.Header()
+		if header.Get("X-Total-Count") != tt.total || header.Get("X-Truncated") != tt.truncated || (header.Get("X-Next-Cursor") != "") != tt.next {
+			t.Errorf("%q: headers = %v", tt.query, header)
+		}
+		if tt.next && header.Get("X-Next-Cursor") != encodeCursor(tt.start+tt.n) {
+			t.Errorf("%q: next cursor = %q", tt.query, header.Get("X-Next-Cursor"))
+		}
+	}
+}
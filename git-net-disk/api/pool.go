@@ -0,0 +1,43 @@
+package api
+
+import (
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PoolHandler HTTP 客户端池状态的处理器
+type PoolHandler struct {
+	backends *backendProvider
+}
+
+// NewPoolHandler 创建客户端池状态处理器
+func NewPoolHandler(backends *backendProvider) *PoolHandler {
+	return &PoolHandler{
+		backends: backends,
+	}
+}
+
+// GetPoolStats 返回客户端池中各传输层的连接复用情况，仅管理员可以查看
+func (h *PoolHandler) GetPoolStats(c *gin.Context) {
+	_, admin, ok := h.backends.viewer(c)
+	if !ok {
+		return
+	}
+	if !admin {
+		c.JSON(403, gin.H{"error": "Only admins can view connection pool statistics"})
+		return
+	}
+
+	middleware.Success(c, proxy.DefaultPool().Stats(), "Pool statistics retrieved successfully")
+}
+
+// RegisterPoolRoutes 注册客户端池状态的路由
+func RegisterPoolRoutes(router *gin.RouterGroup, backends *backendProvider) error {
+	handler := NewPoolHandler(backends)
+
+	router.GET("/admin/pool", handler.GetPoolStats)
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"strconv"
+	"sync"
+
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/github"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forwardQuotaHeaders 把 GitHub 响应中的配额写入本次响应的 X-GitHub-RateLimit-* 头，前端据此在配额耗尽前提醒用户
+func forwardQuotaHeaders(c *gin.Context, client *github.Client) {
+	// 处理器可能并发请求 GitHub
+	var mu sync.Mutex
+	client.OnRateLimit(func(status github.RateLimitStatus) {
+		if status.Resource != "core" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		c.Header("X-GitHub-RateLimit-Limit", strconv.Itoa(status.Limit))
+		c.Header("X-GitHub-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+		c.Header("X-GitHub-RateLimit-Reset", strconv.FormatInt(status.Reset.Unix(), 10))
+	})
+}
+
+// QuotaHandler GitHub 配额查询的处理器
+type QuotaHandler struct {
+	backends *backendProvider
+}
+
+// NewQuotaHandler 创建配额处理器
+func NewQuotaHandler(backends *backendProvider) *QuotaHandler {
+	return &QuotaHandler{
+		backends: backends,
+	}
+}
+
+// GetQuota 返回当前请求身份在 GitHub 上的配额，以及次级限流的暂停时间
+// cached=true 时只返回服务器最近观察到的配额，不请求 GitHub
+func (h *QuotaHandler) GetQuota(c *gin.Context) {
+	account, err := h.backends.accountFromRequest(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if h.backends.local != nil || account.Type != backendGitHub {
+		c.JSON(501, gin.H{"error": "This feature requires the GitHub storage backend"})
+		return
+	}
+	client, ok := h.backends.newGitHubClient(c, account, true)
+	if !ok {
+		return
+	}
+
+	if c.Query("cached") == "true" {
+		middleware.Success(c, client.QuotaStatus(), "Quota retrieved successfully")
+		return
+	}
+
+	status, err := client.RateLimits(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	middleware.Success(c, status, "Quota retrieved successfully")
+}
+
+// RegisterQuotaRoutes 注册配额查询的路由
+func RegisterQuotaRoutes(router *gin.RouterGroup, backends *backendProvider) error {
+	handler := NewQuotaHandler(backends)
+
+	router.GET("/quota", handler.GetQuota)
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"git-net-disk/internal/auth"
+	"git-net-disk/internal/github"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadFile 以原始字节流下载文件，大文件指针会透明地转为附件内容
+func (h *FilesHandler) DownloadFile(c *gin.Context) {
+	client, ok := h.backends.readBackendFromRequest(c)
+	if !ok {
+		return
+	}
+
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	filePath := strings.TrimPrefix(c.Param("path"), "/")
+
+	if !requireKeyScope(c, owner, repo, filePath, auth.OperationRead) {
+		return
+	}
+
+	file, err := client.GetFileContent(c.Request.Context(), owner, repo, filePath, c.Query("ref"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if file.Type != "file" {
+		c.JSON(400, gin.H{"error": "Path is not a file"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(filePath)))
+
+	// GitHub 对超过 1MB 的文件不内联内容，直接从 raw 地址流式读取
+	gh, isGitHub := client.(*github.Client)
+	if isGitHub && file.Content == "" && file.Size > 0 {
+		resp, err := gh.OpenRaw(c.Request.Context(), owner, repo, c.Query("ref"), filePath)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		defer resp.Body.Close()
+
+		c.Header("ETag", `"`+file.SHA+`"`)
+		c.DataFromReader(http.StatusOK, int64(file.Size), contentTypeFor(filePath, ""), resp.Body, nil)
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pointer, isPointer := github.ParseLargeObjectPointer(data)
+	if !isPointer || !isGitHub {
+		c.Header("ETag", `"`+file.SHA+`"`)
+		c.Data(http.StatusOK, contentTypeFor(filePath, ""), data)
+		return
+	}
+
+	resp, err := gh.OpenLargeObject(c.Request.Context(), owner, repo, pointer)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	c.Header("ETag", `"`+pointer.SHA256+`"`)
+	c.DataFromReader(http.StatusOK, pointer.Size, contentTypeFor(filePath, pointer.ContentType), resp.Body, nil)
+}
+
+// UploadFile 以原始字节流上传文件，超过阈值时存为 Release 附件
+// 查询参数: message, branch, sha, overwrite
+func (h *FilesHandler) UploadFile(c *gin.Context) {
+	client, ok := h.backends.backendFromRequest(c)
+	if !ok {
+		return
+	}
+
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	filePath := strings.TrimPrefix(c.Param("path"), "/")
+
+	message := c.Query("message")
+	if message == "" {
+		message = "Upload " + filePath
+	}
+
+	// 在读取上传内容之前检查权限，避免无权限时白白接收大文件
+	if !requireKeyScope(c, owner, repo, filePath, auth.OperationWrite) {
+		return
+	}
+	if !requirePermission(c, client, owner, repo, github.PermissionWrite) {
+		return
+	}
+
+	// 大文件存为 Release 附件，超过附件上限的内容不必接收完再拒绝
+	gh, isGitHub := client.(*github.Client)
+	if isGitHub && h.largeObjectThreshold > 0 {
+		if c.Request.ContentLength > github.MaxLargeObjectSize {
+			c.Error(github.ErrLargeObjectTooLarge)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, github.MaxLargeObjectSize)
+	}
+
+	spooled, size, sum, err := spoolToTemp(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = github.ErrLargeObjectTooLarge
+		}
+		c.Error(err)
+		return
+	}
+	defer os.Remove(spooled.Name())
+	defer spooled.Close()
+
+	var content []byte
+	if isGitHub && h.largeObjectThreshold > 0 && size > h.largeObjectThreshold {
+		if !checkWritePrecondition(c, client, owner, repo, c.Query("branch"), newFileWrite(c, filePath, "", message, c.Query("sha"), false)) {
+			return
+		}
+		pointer, err := gh.UploadLargeObject(c.Request.Context(), owner, repo, spooled, size, sum, c.ContentType())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		content = pointer.Encode()
+	} else {
+		if _, err := spooled.Seek(0, io.SeekStart); err != nil {
+			c.Error(err)
+			return
+		}
+		content, err = io.ReadAll(spooled)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	h.commitFile(c, client, owner, repo, filePath, base64.StdEncoding.EncodeToString(content), message,
+		c.Query("branch"), c.Query("sha"), false)
+}
+
+// storeLargeObject 将内容上传为 Release 附件并返回指针
+func storeLargeObject(ctx context.Context, client *github.Client, owner, repo string, r io.Reader, contentType string) (*github.LargeObjectPointer, error) {
+	spooled, size, sum, err := spoolToTemp(r)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spooled.Name())
+	defer spooled.Close()
+
+	return client.UploadLargeObject(ctx, owner, repo, spooled, size, sum, contentType)
+}
+
+// spoolToTemp 将内容写入临时文件，同时计算大小和 SHA256
+func spoolToTemp(r io.Reader) (*os.File, int64, string, error) {
+	f, err := os.CreateTemp("", "gitnetdisk-upload-*")
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, 0, "", err
+	}
+
+	return f, size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// decodeLargeObjectPointer 判断 contents 接口返回的文件是否为大文件指针
+func decodeLargeObjectPointer(file *github.FileEntry) (*github.LargeObjectPointer, bool) {
+	if file.Type != "file" || file.Encoding != "base64" || file.Size > 1024 {
+		return nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		return nil, false
+	}
+	return github.ParseLargeObjectPointer(data)
+}
+
+// contentTypeFor 根据扩展名推断 Content-Type
+func contentTypeFor(filePath, fallback string) string {
+	if fallback != "" {
+		return fallback
+	}
+	if t := mime.TypeByExtension(path.Ext(filePath)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
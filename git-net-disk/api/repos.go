@@ -2,12 +2,14 @@ package api
 
 import (
 	"fmt"
-	"net/url"
-	"strconv"
-	"strings"
 	"git-net-disk/api/middleware"
 	"git-net-disk/internal/github"
 	"git-net-disk/internal/proxy"
+	"git-net-disk/internal/storage"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,99 +17,179 @@ import (
 // ReposHandler 仓库相关的 API 处理器
 type ReposHandler struct {
 	proxyConfig proxy.ProxyConfig
+	backends    *backendProvider
 }
 
 // NewReposHandler 创建新的仓库处理器
-func NewReposHandler(token string, proxyConfig proxy.ProxyConfig) (*ReposHandler, error) {
+func NewReposHandler(token string, proxyConfig proxy.ProxyConfig, backends *backendProvider) (*ReposHandler, error) {
 	return &ReposHandler{
 		proxyConfig: proxyConfig,
+		backends:    backends,
 	}, nil
 }
 
 // ListRepositories 列出用户的仓库
+// 支持 visibility、affiliation 上游过滤，其余过滤、排序和分页参数见 respondRepositories
 func (h *ReposHandler) ListRepositories(c *gin.Context) {
-	// 从请求头获取token
-	authHeader := c.GetHeader("Authorization")
-	var userToken string
-	
-	// 调试日志：查看接收到的Authorization头
-	fmt.Printf("[DEBUG] Received Authorization header: %s\n", authHeader)
-	fmt.Printf("[DEBUG] Authorization header length: %d\n", len(authHeader))
-	
-	// 处理两种格式的 Authorization 头
-	if authHeader != "" {
-		// 使用 strings.HasPrefix 更可靠
-		if strings.HasPrefix(authHeader, "token ") {
-			userToken = strings.TrimPrefix(authHeader, "token ")
-			fmt.Printf("[DEBUG] Extracted token using TrimPrefix: %s\n", userToken)
-		} else {
-			// 格式2: ghp_xxxxxxxx (直接是token值)
-			userToken = authHeader
-			fmt.Printf("[DEBUG] Using authHeader directly: %s\n", userToken)
-		}
+	// 根据配置选择存储后端
+	client, ok := h.backends.backendFromRequest(c)
+	if !ok {
+		return
 	}
-	
-	// 清理可能的空格
-	userToken = strings.TrimSpace(userToken)
-	
-	// 调试日志：查看最终的token
-	fmt.Printf("[DEBUG] Final token: %s\n", userToken)
-	fmt.Printf("[DEBUG] Final token length: %d\n", len(userToken))
 
-	if userToken == "" {
-		c.JSON(401, gin.H{"error": "Missing authentication token"})
+	visibility := c.DefaultQuery("visibility", "all")
+	if visibility != "all" && visibility != "public" && visibility != "private" {
+		c.JSON(400, gin.H{"error": "visibility must be all, public or private"})
 		return
 	}
 
-	// 从请求头获取代理配置
-	proxyConfig := getProxyConfigFromReposHeader(c)
-
-	// 创建临时GitHub客户端
-	client, err := github.NewClient(userToken, proxyConfig)
+	repos, err := client.ListRepositories(c.Request.Context(), github.RepoListOptions{
+		Visibility:  visibility,
+		Affiliation: c.Query("affiliation"),
+	})
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create GitHub client"})
+		c.Error(err)
 		return
 	}
 
-	repos, err := client.ListRepositories()
+	// 本地成员只能看到有授权的仓库
+	if user := localUserFromContext(c); user != nil {
+		repos = filterRepositoriesForUser(user, repos)
+	}
+
+	// 标记他人共享给当前用户的仓库
+	if lister, ok := client.(storage.SharedRepositoryLister); ok {
+		shared, err := lister.ListSharedRepositories(c.Request.Context())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		sharedNames := make(map[string]bool, len(shared))
+		for _, repo := range shared {
+			sharedNames[repo.FullName] = true
+		}
+		for i := range repos {
+			repos[i].SharedWithMe = sharedNames[repos[i].FullName]
+		}
+	}
+
+	respondRepositories(c, client, repos)
+}
+
+// respondRepositories 对仓库列表做服务端过滤、排序和分页后写入响应
+// 过滤参数：visibility、owner、q（名称包含）、shared、updated_after、updated_before，
+// drives=true 只保留网盘并附带设置，drives=false 只保留普通仓库
+// 排序参数：sort（name、full_name、created、updated、size）和 direction
+// 分页参数：page、per_page、cursor，均未提供时返回全部
+func respondRepositories(c *gin.Context, client storage.Backend, repos []github.Repository) {
+	visibility := c.DefaultQuery("visibility", "all")
+	if visibility != "all" && visibility != "public" && visibility != "private" {
+		c.JSON(400, gin.H{"error": "visibility must be all, public or private"})
+		return
+	}
+	updatedAfter, err := parseTimeParam(c, "updated_after")
 	if err != nil {
-		c.Error(err)
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	updatedBefore, err := parseTimeParam(c, "updated_before")
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	middleware.Success(c, repos, "Repositories listed successfully")
-}
+	drivesFilter := c.Query("drives")
+	if drivesFilter != "" {
+		if err := markDrives(c.Request.Context(), client, repos); err != nil {
+			c.Error(err)
+			return
+		}
+	}
 
-// CreateRepository 创建新仓库
-func (h *ReposHandler) CreateRepository(c *gin.Context) {
-	// 从请求头获取token
-	authHeader := c.GetHeader("Authorization")
-	var userToken string
-	
-	// 使用 strings.HasPrefix 更可靠
-	if authHeader != "" {
-		if strings.HasPrefix(authHeader, "token ") {
-			userToken = strings.TrimPrefix(authHeader, "token ")
-		} else {
-			userToken = authHeader
+	// 不是所有后端都支持上游过滤，这里统一再过滤一次
+	keyword := strings.ToLower(c.Query("q"))
+	owner := c.Query("owner")
+	sharedFilter := c.Query("shared")
+	filtered := make([]github.Repository, 0, len(repos))
+	for _, repo := range repos {
+		if visibility != "all" && repo.Private != (visibility == "private") {
+			continue
+		}
+		if owner != "" && !strings.EqualFold(repo.Owner.Login, owner) {
+			continue
+		}
+		if sharedFilter != "" && repo.SharedWithMe != (sharedFilter == "true") {
+			continue
+		}
+		if drivesFilter != "" && (repo.Drive != nil) != (drivesFilter == "true") {
+			continue
+		}
+		if keyword != "" && !strings.Contains(strings.ToLower(repo.FullName), keyword) {
+			continue
+		}
+		if !updatedAfter.IsZero() && repo.UpdatedAt.Before(updatedAfter) {
+			continue
+		}
+		if !updatedBefore.IsZero() && repo.UpdatedAt.After(updatedBefore) {
+			continue
 		}
+		filtered = append(filtered, repo)
+	}
+
+	if err := sortRepositories(c, filtered); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
-	
-	// 清理可能的空格
-	userToken = strings.TrimSpace(userToken)
 
-	if userToken == "" {
-		c.JSON(401, gin.H{"error": "Missing authentication token"})
+	page, ok := paginate(c, filtered)
+	if !ok {
 		return
 	}
 
-	// 从请求头获取代理配置
-	proxyConfig := getProxyConfigFromReposHeader(c)
+	middleware.Success(c, page, "Repositories listed successfully")
+}
+
+// sortRepositories 按 sort 和 direction 参数排序，名称默认升序，时间和大小默认降序
+func sortRepositories(c *gin.Context, repos []github.Repository) error {
+	field := c.Query("sort")
+	if field == "" {
+		return nil
+	}
 
-	// 创建临时GitHub客户端
-	client, err := github.NewClient(userToken, proxyConfig)
+	var less func(a, b *github.Repository) bool
+	switch field {
+	case "name":
+		less = func(a, b *github.Repository) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case "full_name":
+		less = func(a, b *github.Repository) bool { return strings.ToLower(a.FullName) < strings.ToLower(b.FullName) }
+	case "created":
+		less = func(a, b *github.Repository) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	case "updated":
+		less = func(a, b *github.Repository) bool { return a.UpdatedAt.Before(b.UpdatedAt) }
+	case "size":
+		less = func(a, b *github.Repository) bool { return a.Size < b.Size }
+	default:
+		return fmt.Errorf("unsupported sort field: %s", field)
+	}
+
+	desc, err := parseSortDirection(c, field != "name" && field != "full_name")
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create GitHub client"})
+		return err
+	}
+	sort.SliceStable(repos, func(i, j int) bool {
+		if desc {
+			return less(&repos[j], &repos[i])
+		}
+		return less(&repos[i], &repos[j])
+	})
+	return nil
+}
+
+// CreateRepository 创建新仓库
+func (h *ReposHandler) CreateRepository(c *gin.Context) {
+	// 根据配置选择存储后端
+	client, ok := h.backends.backendFromRequest(c)
+	if !ok {
 		return
 	}
 
@@ -116,31 +198,179 @@ func (h *ReposHandler) CreateRepository(c *gin.Context) {
 		Description string `json:"description"`
 		Private     bool   `json:"private"`
 		AutoInit    bool   `json:"auto_init"`
+		// Drive 非空时写入网盘设置文件，将仓库标记为网盘
+		Drive *github.DriveSettings `json:"drive"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.Error(err)
+		c.Error(middleware.BindError(err))
 		return
 	}
+	// 所有者在创建成功后才能确定
+	setAuditTarget(c, "", req.Name)
 
-	repo, err := client.CreateRepository(req.Name, req.Description, req.Private, req.AutoInit)
+	repo, err := client.CreateRepository(c.Request.Context(), req.Name, req.Description, req.Private, req.AutoInit)
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
+	if req.Drive != nil {
+		if err := writeDriveSettings(c.Request.Context(), client, repo.Owner.Login, repo.Name, req.Drive, "Initialize drive settings"); err != nil {
+			c.Error(err)
+			return
+		}
+		repo.Drive = req.Drive
+	}
+
+	setAuditTarget(c, repo.Owner.Login, repo.Name)
 	middleware.Success(c, repo, "Repository created successfully")
 }
 
+// UpdateRepository 修改仓库名称、描述、可见性或归档状态
+func (h *ReposHandler) UpdateRepository(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var req github.RepositoryUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+	if req.Name == nil && req.Description == nil && req.Private == nil && req.Archived == nil {
+		c.JSON(400, gin.H{"error": "Nothing to update"})
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		c.JSON(400, gin.H{"error": "Repository name cannot be empty"})
+		return
+	}
+	if !requirePermission(c, client, c.Param("owner"), c.Param("repo"), github.PermissionAdmin) {
+		return
+	}
+
+	repo, err := client.UpdateRepository(c.Request.Context(), c.Param("owner"), c.Param("repo"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, repo, "Repository updated successfully")
+}
+
+// UpdateTopics 设置仓库主题，PUT 替换全部主题，POST 在现有主题上追加
+func (h *ReposHandler) UpdateTopics(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Names []string `json:"names"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+
+	if !requirePermission(c, client, owner, repo, github.PermissionAdmin) {
+		return
+	}
+
+	var names []string
+	if c.Request.Method == "POST" {
+		existing, err := client.GetTopics(c.Request.Context(), owner, repo)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		names = existing
+	}
+
+	// GitHub 的主题只允许小写，重复的主题只保留一个
+	seen := make(map[string]bool)
+	for _, name := range append(names, req.Names...) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+	}
+	topics := make([]string, 0, len(seen))
+	for name := range seen {
+		topics = append(topics, name)
+	}
+	sort.Strings(topics)
+
+	topics, err := client.ReplaceTopics(c.Request.Context(), owner, repo, topics)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"names": topics}, "Topics updated successfully")
+}
+
+// DeleteRepository 删除仓库，confirm 参数必须与仓库名一致，防止误删
+func (h *ReposHandler) DeleteRepository(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+
+	if c.Query("confirm") != repo {
+		c.JSON(400, gin.H{"error": "Deleting a repository requires the confirm parameter to repeat the repository name"})
+		return
+	}
+	if !requirePermission(c, client, owner, repo, github.PermissionDelete) {
+		return
+	}
+
+	if err := client.DeleteRepository(c.Request.Context(), owner, repo); err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"message": "Repository deleted successfully"}, "Repository deleted successfully")
+}
+
+// requirePermission 在执行操作前检查 token 对仓库的权限，不足时已写入响应
+// 不支持预检查的后端直接放行，由实际操作返回错误
+func requirePermission(c *gin.Context, client storage.Backend, owner, repo, permission string) bool {
+	checker, ok := client.(storage.PermissionChecker)
+	if !ok {
+		return true
+	}
+	if err := checker.CheckRepoPermission(c.Request.Context(), owner, repo, permission); err != nil {
+		c.Error(err)
+		return false
+	}
+	return true
+}
+
 // RegisterReposRoutes 注册仓库相关的路由
-func RegisterReposRoutes(router *gin.RouterGroup, token string, proxyConfig proxy.ProxyConfig) error {
-	handler, err := NewReposHandler(token, proxyConfig)
+func RegisterReposRoutes(router *gin.RouterGroup, token string, proxyConfig proxy.ProxyConfig, backends *backendProvider) error {
+	handler, err := NewReposHandler(token, proxyConfig, backends)
 	if err != nil {
 		return err
 	}
 
 	router.GET("/repos", handler.ListRepositories)
 	router.POST("/repos", handler.CreateRepository)
+	router.PATCH("/repos/:owner/:repo", handler.UpdateRepository)
+	router.DELETE("/repos/:owner/:repo", handler.DeleteRepository)
+	router.PUT("/repos/:owner/:repo/topics", handler.UpdateTopics)
+	router.POST("/repos/:owner/:repo/topics", handler.UpdateTopics)
+	router.GET("/repos/:owner/:repo/drive", handler.GetDriveSettings)
+	router.PUT("/repos/:owner/:repo/drive", handler.UpdateDriveSettings)
 
 	return nil
 }
@@ -1,15 +1,15 @@
 package api
 
 import (
-	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"git-net-disk/api/middleware"
-	"git-net-disk/internal/github"
 	"git-net-disk/internal/proxy"
 
 	"github.com/gin-gonic/gin"
@@ -46,81 +46,115 @@ func (s *Server) RegisterRoutes() error {
 		// 默认禁用代理，可通过环境变量配置
 	}
 
+	// 客户端 IP 用于匿名限流、API key 的 IP 限制和审计日志，只信任配置的反向代理
+	if err := s.router.SetTrustedProxies(trustedProxiesFromEnv()); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	// 存储后端配置
+	backends, err := newBackendProviderFromEnv()
+	if err != nil {
+		return err
+	}
+
+	// 存储后端操作的时限
+	timeouts, err := operationTimeoutsFromEnv()
+	if err != nil {
+		return err
+	}
+
 	// API 路由组
 	apiGroup := s.router.Group("/api")
 
+	// 审计日志、跨站请求检查、路径规范化、API key 和本地账户的访问控制以及操作时限，需在注册路由之前添加
+	// 审计中间件在最外层，被访问控制拒绝的写操作也会记录
+	apiGroup.Use(backends.auditMiddleware())
+	apiGroup.Use(middleware.CSRFMiddleware(sessionCookie))
+	apiGroup.Use(pathParamMiddleware())
+	apiGroup.Use(backends.apiKeyMiddleware())
+	apiGroup.Use(backends.localAccountMiddleware())
+	apiGroup.Use(timeoutMiddleware(timeouts))
+
 	// 注册仓库路由
-	if err := RegisterReposRoutes(apiGroup, token, proxyConfig); err != nil {
+	if err := RegisterReposRoutes(apiGroup, token, proxyConfig, backends); err != nil {
 		return err
 	}
 
 	// 注册文件路由
-	if err := RegisterFilesRoutes(apiGroup, token, proxyConfig); err != nil {
+	if err := RegisterFilesRoutes(apiGroup, token, proxyConfig, backends); err != nil {
 		return err
 	}
 
-	// 注册用户信息路由
-	apiGroup.GET("/user", func(c *gin.Context) {
-		// 从请求头获取token
-		authHeader := c.GetHeader("Authorization")
-		var userToken string
-		if authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "token " {
-			userToken = authHeader[7:]
-		}
+	// 注册快照路由
+	if err := RegisterSnapshotsRoutes(apiGroup, backends); err != nil {
+		return err
+	}
 
-		if userToken == "" {
-			c.JSON(401, gin.H{"error": "Missing authentication token"})
-			return
-		}
+	// 注册协作者和邀请路由
+	if err := RegisterSharingRoutes(apiGroup, backends); err != nil {
+		return err
+	}
 
-		// 创建临时GitHub客户端
-		proxyConfig := proxy.ProxyConfig{
-			Enabled: false,
-		}
-		client, err := github.NewClient(userToken, proxyConfig)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create GitHub client"})
-			return
-		}
+	// 注册组织（团队网盘）路由
+	if err := RegisterOrgsRoutes(apiGroup, backends); err != nil {
+		return err
+	}
 
-		// 获取用户信息
-		url := "https://api.github.com/user"
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create request"})
-			return
-		}
+	// 注册登录和会话路由
+	if err := RegisterAuthRoutes(apiGroup, backends); err != nil {
+		return err
+	}
+
+	// 注册本地账户管理路由
+	if err := RegisterAccountsRoutes(apiGroup, backends); err != nil {
+		return err
+	}
 
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
-		req.Header.Set("Authorization", "token "+userToken)
+	// 注册 API key 管理路由
+	if err := RegisterAPIKeysRoutes(apiGroup, backends); err != nil {
+		return err
+	}
 
-		resp, err := client.Client.Do(req)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to fetch user info"})
-			return
-		}
-		defer resp.Body.Close()
+	// 注册审计日志路由
+	if err := RegisterAuditRoutes(apiGroup, backends); err != nil {
+		return err
+	}
+
+	// 注册 GitHub 配额查询路由
+	if err := RegisterQuotaRoutes(apiGroup, backends); err != nil {
+		return err
+	}
+
+	// 注册 HTTP 客户端池状态路由
+	if err := RegisterPoolRoutes(apiGroup, backends); err != nil {
+		return err
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to fetch user info"})
+	// 注册用户信息路由，使用与其他路由相同的 GitHub 接口配置
+	apiGroup.GET("/user", func(c *gin.Context) {
+		// 本地账户没有 GitHub 身份，返回账户信息
+		if user := localUserFromContext(c); user != nil {
+			c.JSON(200, gin.H{
+				"login":   user.Username,
+				"name":    user.Username,
+				"local":   true,
+				"account": newAccountView(user),
+			})
 			return
 		}
 
-		// 解析响应
-		var userInfo struct {
-			ID        int64  `json:"id"`
-			Login     string `json:"login"`
-			AvatarURL string `json:"avatar_url"`
-			Name      string `json:"name"`
-			Email     string `json:"email"`
+		client, ok := backends.githubClientFromRequest(c)
+		if !ok {
+			return
 		}
 
-		if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-			c.JSON(500, gin.H{"error": "Failed to parse user info"})
+		user, err := client.GetUser(c.Request.Context())
+		if err != nil {
+			c.Error(err)
 			return
 		}
 
-		c.JSON(200, userInfo)
+		c.JSON(200, user)
 	})
 
 	// 注册代理路由
@@ -163,35 +197,38 @@ func (s *Server) RegisterRoutes() error {
 			}
 		}
 
-		client, err := proxy.NewHTTPClient(proxyConfig)
+		client, err := proxy.DefaultPool().Client(proxy.TokenFingerprint(getTokenFromHeader(c)), proxyConfig)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to create proxy client"})
 			return
 		}
 
-		req, err := http.NewRequest("GET", target, nil)
+		// 使用请求上下文，上游请求受 API_REQUEST_TIMEOUT 限制，客户端断开时随之取消
+		req, err := http.NewRequestWithContext(c.Request.Context(), "GET", target, nil)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to create request"})
 			return
 		}
 
-		// 复制请求头
+		// 复制请求头，会话 cookie、凭据和逐跳头不转发给目标地址
 		for k, v := range c.Request.Header {
-			if k != "Host" && k != "Content-Length" {
+			if !proxyDropsHeader(c.Request.Header, k) {
 				req.Header[k] = v
 			}
 		}
 
 		resp, err := client.Do(req)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Proxy request failed"})
+			c.Error(fmt.Errorf("proxy request failed: %w", err))
 			return
 		}
 		defer resp.Body.Close()
 
-		// 复制响应头
+		// 复制响应头，目标地址不能在本站设置 cookie
 		for k, v := range resp.Header {
-			c.Header(k, v[0])
+			if !proxyDropsHeader(resp.Header, k) && k != "Set-Cookie" {
+				c.Header(k, v[0])
+			}
 		}
 		c.Status(resp.StatusCode)
 
@@ -209,6 +246,51 @@ func (s *Server) RegisterRoutes() error {
 	return nil
 }
 
+// hopByHopHeaders 只对单个连接有效、代理不应转发的头
+var hopByHopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// proxyDropsHeader 判断代理是否丢弃该头：逐跳头、Connection 中列出的头、凭据以及本服务自己使用的头
+func proxyDropsHeader(header http.Header, key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	switch {
+	case hopByHopHeaders[key],
+		key == "Host", key == "Content-Length",
+		key == "Cookie", key == "Authorization",
+		key == "X-Proxy-Url", strings.HasPrefix(key, "X-Storage-"):
+		return true
+	}
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if http.CanonicalHeaderKey(strings.TrimSpace(name)) == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// trustedProxiesFromEnv 读取 TRUSTED_PROXIES，以逗号分隔的 IP 或 CIDR
+// 为空时不信任任何代理，X-Forwarded-For 和 X-Real-IP 被忽略，ClientIP 取连接的对端地址
+func trustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			proxies = append(proxies, entry)
+		}
+	}
+	return proxies
+}
+
 // GetRouter 获取 Gin 路由器
 func (s *Server) GetRouter() *gin.Engine {
 	return s.router
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIPFor 以给定的 TRUSTED_PROXIES 启动服务器，返回带 X-Forwarded-For 的请求得到的客户端 IP
+func clientIPFor(t *testing.T, trustedProxies string) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("STORAGE_BACKEND", "local")
+	t.Setenv("LOCAL_STORAGE_DIR", t.TempDir())
+	t.Setenv("LOCAL_STORAGE_NO_AUTH", "true")
+	t.Setenv("USERS_FILE", "")
+	t.Setenv("AUDIT_LOG_FILE", "off")
+	t.Setenv("TRUSTED_PROXIES", trustedProxies)
+
+	server := NewServer()
+	if err := server.RegisterRoutes(); err != nil {
+		t.Fatalf("RegisterRoutes: %v", err)
+	}
+	server.GetRouter().GET("/client-ip", func(c *gin.Context) {
+		c.String(200, c.ClientIP())
+	})
+
+	// httptest 请求的对端地址为 192.0.2.1
+	req := httptest.NewRequest("GET", "/client-ip", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rec := httptest.NewRecorder()
+	server.GetRouter().ServeHTTP(rec, req)
+	return rec.Body.String()
+}
+
+func TestClientIPIgnoresForwardedForByDefault(t *testing.T) {
+	if ip := clientIPFor(t, ""); ip != "192.0.2.1" {
+		t.Fatalf("ClientIP = %s, want the peer address", ip)
+	}
+}
+
+func TestClientIPTrustsConfiguredProxies(t *testing.T) {
+	if ip := clientIPFor(t, "192.0.2.0/24, 10.0.0.1"); ip != "203.0.113.7" {
+		t.Fatalf("ClientIP = %s, want the forwarded address", ip)
+	}
+}
+
+func TestInvalidTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("TRUSTED_PROXIES", "not-an-ip")
+	if err := NewServer().RegisterRoutes(); err == nil {
+		t.Fatal("invalid TRUSTED_PROXIES should fail to start")
+	}
+}
+
+// localLogin 以本地账户登录并返回会话 cookie
+func localLogin(t *testing.T, h http.Handler, username, password string) *http.Cookie {
+	t.Helper()
+	body, _ := json.Marshal(gin.H{"username": username, "password": password})
+	req := httptest.NewRequest("POST", "/api/auth/local/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == sessionCookie && cookie.Value != "" {
+			return cookie
+		}
+	}
+	t.Fatalf("login as %s = %d %s", username, rec.Code, rec.Body.String())
+	return nil
+}
+
+// newAccountsTestServer 以本地存储和本地账户启动服务器，管理员为 admin
+func newAccountsTestServer(t *testing.T, env map[string]string) http.Handler {
+	t.Helper()
+	settings := map[string]string{
+		"USERS_FILE":     filepath.Join(t.TempDir(), "users.json"),
+		"ADMIN_USERNAME": "admin",
+		"ADMIN_PASSWORD": "correct horse battery",
+	}
+	for key, value := range env {
+		settings[key] = value
+	}
+	return newLocalTestServer(t, settings)
+}
+
+func TestCookieWritesRequireSameOrigin(t *testing.T) {
+	h := newAccountsTestServer(t, map[string]string{"CORS_ALLOWED_ORIGINS": "https://app.example"})
+	cookie := localLogin(t, h, "admin", "correct horse battery")
+
+	tests := []struct {
+		name        string
+		origin      string
+		referer     string
+		contentType string
+		want        int
+	}{
+		{"cross-site form post", "https://evil.example", "", "text/plain", http.StatusForbidden},
+		{"cross-site JSON", "https://evil.example", "", "application/json", http.StatusForbidden},
+		{"cross-site referer", "", "https://evil.example/page", "text/plain", http.StatusForbidden},
+		{"same origin", "http://example.com", "", "application/json", http.StatusOK},
+		{"allowed origin", "https://app.example", "", "application/json", http.StatusOK},
+		{"no origin", "", "", "application/json", http.StatusOK},
+	}
+	for i, tt := range tests {
+		body := `{"name":"drive` + string(rune('a'+i)) + `","auto_init":true}`
+		req := httptest.NewRequest("POST", "/api/repos", strings.NewReader(body))
+		req.AddCookie(cookie)
+		req.Header.Set("Content-Type", tt.contentType)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.referer != "" {
+			req.Header.Set("Referer", tt.referer)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: POST /api/repos = %d %s, want %d", tt.name, rec.Code, rec.Body.String(), tt.want)
+		}
+	}
+
+	// 不带 cookie 的请求不受影响，由认证中间件处理
+	req := httptest.NewRequest("POST", "/api/repos", strings.NewReader(`{"name":"other"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://evil.example")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("cross-site request without a session = %d, want 401", rec.Code)
+	}
+}
+
+func TestProxyStripsCredentials(t *testing.T) {
+	var received http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "planted"})
+		w.Header().Set("X-Upstream", "yes")
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	h := newLocalTestServer(t, map[string]string{"USERS_FILE": "", "LOCAL_STORAGE_NO_AUTH": "true"})
+
+	req := httptest.NewRequest("GET", "/api/proxy?target="+upstream.URL, nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "secret-session"})
+	req.Header.Set("Authorization", "token secret-token")
+	req.Header.Set("Proxy-Authorization", "Basic c2VjcmV0")
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "1")
+	req.Header.Set("X-Storage-Account", "work")
+	req.Header.Set("Accept", "text/plain")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Fatalf("proxy = %d %q", rec.Code, rec.Body.String())
+	}
+	for _, key := range []string{"Cookie", "Authorization", "Proxy-Authorization", "X-Hop", "X-Storage-Account"} {
+		if value := received.Get(key); value != "" {
+			t.Errorf("proxy forwarded %s: %q", key, value)
+		}
+	}
+	if received.Get("Accept") != "text/plain" {
+		t.Errorf("proxy dropped Accept: %v", received)
+	}
+	if rec.Header().Get("Set-Cookie") != "" {
+		t.Errorf("proxy passed Set-Cookie from the target: %q", rec.Header().Get("Set-Cookie"))
+	}
+	if rec.Header().Get("X-Upstream") != "yes" {
+		t.Errorf("proxy dropped response headers: %v", rec.Header())
+	}
+}
+
+func TestProxyRequestIsBounded(t *testing.T) {
+	released := make(chan struct{})
+	canceled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(canceled)
+		case <-released:
+		}
+	}))
+	defer upstream.Close()
+	defer close(released)
+
+	h := newLocalTestServer(t, map[string]string{
+		"USERS_FILE":            "",
+		"LOCAL_STORAGE_NO_AUTH": "true",
+		"API_REQUEST_TIMEOUT":   "100ms",
+	})
+
+	req := httptest.NewRequest("GET", "/api/proxy?target="+upstream.URL, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("proxy to a stalled target = %d %s, want 504", rec.Code, rec.Body.String())
+	}
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not canceled after the timeout")
+	}
+}
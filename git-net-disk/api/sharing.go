@@ -0,0 +1,182 @@
+package api
+
+import (
+	"strconv"
+
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/github"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SharingHandler 协作者和邀请相关的 API 处理器
+type SharingHandler struct {
+	backends *backendProvider
+}
+
+// NewSharingHandler 创建新的共享处理器
+func NewSharingHandler(backends *backendProvider) *SharingHandler {
+	return &SharingHandler{
+		backends: backends,
+	}
+}
+
+// ListCollaborators 列出仓库协作者
+func (h *SharingHandler) ListCollaborators(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	collaborators, err := client.ListCollaborators(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, collaborators, "Collaborators listed successfully")
+}
+
+// AddCollaborator 邀请协作者或修改已有协作者的权限
+func (h *SharingHandler) AddCollaborator(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Permission string `json:"permission"` // read、write 或 admin，默认 write
+	}
+
+	// 请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(middleware.BindError(err))
+			return
+		}
+	}
+	if req.Permission == "" {
+		req.Permission = "write"
+	}
+	if !github.ValidCollaboratorPermission(req.Permission) {
+		c.JSON(400, gin.H{"error": "permission must be read, write or admin"})
+		return
+	}
+
+	invitation, err := client.AddCollaborator(c.Request.Context(), c.Param("owner"), c.Param("repo"), c.Param("username"), req.Permission)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// 已是协作者时没有新邀请，只更新了权限
+	if invitation == nil {
+		middleware.Success(c, gin.H{"permission": req.Permission}, "Collaborator permission updated successfully")
+		return
+	}
+	middleware.Success(c, invitation, "Invitation sent successfully")
+}
+
+// RemoveCollaborator 移除仓库协作者
+func (h *SharingHandler) RemoveCollaborator(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := client.RemoveCollaborator(c.Request.Context(), c.Param("owner"), c.Param("repo"), c.Param("username")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"message": "Collaborator removed successfully"}, "Collaborator removed successfully")
+}
+
+// ListRepositoryInvitations 列出仓库已发出但未接受的邀请
+func (h *SharingHandler) ListRepositoryInvitations(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := client.ListRepositoryInvitations(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, invitations, "Invitations listed successfully")
+}
+
+// ListUserInvitations 列出当前用户收到的待处理邀请
+func (h *SharingHandler) ListUserInvitations(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := client.ListUserInvitations(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, invitations, "Invitations listed successfully")
+}
+
+// AcceptInvitation 接受邀请，之后仓库会以共享仓库出现在列表中
+func (h *SharingHandler) AcceptInvitation(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid invitation id"})
+		return
+	}
+
+	if err := client.AcceptInvitation(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"message": "Invitation accepted successfully"}, "Invitation accepted successfully")
+}
+
+// DeclineInvitation 拒绝邀请
+func (h *SharingHandler) DeclineInvitation(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid invitation id"})
+		return
+	}
+
+	if err := client.DeclineInvitation(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, gin.H{"message": "Invitation declined successfully"}, "Invitation declined successfully")
+}
+
+// RegisterSharingRoutes 注册协作者和邀请相关的路由
+func RegisterSharingRoutes(router *gin.RouterGroup, backends *backendProvider) error {
+	handler := NewSharingHandler(backends)
+
+	router.GET("/repos/:owner/:repo/collaborators", handler.ListCollaborators)
+	router.PUT("/repos/:owner/:repo/collaborators/:username", handler.AddCollaborator)
+	router.DELETE("/repos/:owner/:repo/collaborators/:username", handler.RemoveCollaborator)
+	router.GET("/repos/:owner/:repo/invitations", handler.ListRepositoryInvitations)
+	router.GET("/invitations", handler.ListUserInvitations)
+	router.POST("/invitations/:id/accept", handler.AcceptInvitation)
+	router.DELETE("/invitations/:id", handler.DeclineInvitation)
+
+	return nil
+}
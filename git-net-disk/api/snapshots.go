@@ -0,0 +1,112 @@
+package api
+
+import (
+	"git-net-disk/api/middleware"
+	"git-net-disk/internal/github"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SnapshotsHandler 快照相关的 API 处理器
+type SnapshotsHandler struct {
+	backends *backendProvider
+}
+
+// NewSnapshotsHandler 创建新的快照处理器
+func NewSnapshotsHandler(backends *backendProvider) *SnapshotsHandler {
+	return &SnapshotsHandler{
+		backends: backends,
+	}
+}
+
+// ListSnapshots 列出仓库的所有快照
+func (h *SnapshotsHandler) ListSnapshots(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	snapshots, err := client.ListSnapshots(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, snapshots, "Snapshots listed successfully")
+}
+
+// CreateSnapshot 从当前 head 创建快照
+func (h *SnapshotsHandler) CreateSnapshot(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name    string `json:"name" binding:"required"`
+		Type    string `json:"type"`   // tag（默认）或 branch
+		Branch  string `json:"branch"` // 来源分支，默认使用仓库默认分支
+		Message string `json:"message"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(middleware.BindError(err))
+		return
+	}
+
+	if !requirePermission(c, client, c.Param("owner"), c.Param("repo"), github.PermissionWrite) {
+		return
+	}
+
+	snapshot, err := client.CreateSnapshot(c.Request.Context(), c.Param("owner"), c.Param("repo"), req.Name, req.Type, req.Branch, req.Message)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, snapshot, "Snapshot created successfully")
+}
+
+// RestoreSnapshot 将分支回滚到快照，生成一个新提交
+func (h *SnapshotsHandler) RestoreSnapshot(c *gin.Context) {
+	client, ok := h.backends.githubClientFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Branch  string `json:"branch"`
+		Message string `json:"message"`
+	}
+
+	// 请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(middleware.BindError(err))
+			return
+		}
+	}
+
+	if !requirePermission(c, client, c.Param("owner"), c.Param("repo"), github.PermissionWrite) {
+		return
+	}
+
+	commit, err := client.RestoreSnapshot(c.Request.Context(), c.Param("owner"), c.Param("repo"), c.Param("name"), req.Branch, req.Message)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	middleware.Success(c, commit, "Snapshot restored successfully")
+}
+
+// RegisterSnapshotsRoutes 注册快照相关的路由
+func RegisterSnapshotsRoutes(router *gin.RouterGroup, backends *backendProvider) error {
+	handler := NewSnapshotsHandler(backends)
+
+	router.GET("/snapshots/:owner/:repo", handler.ListSnapshots)
+	router.POST("/snapshots/:owner/:repo", handler.CreateSnapshot)
+	router.POST("/snapshots/:owner/:repo/:name/restore", handler.RestoreSnapshot)
+
+	return nil
+}
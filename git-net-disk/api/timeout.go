@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 默认的操作时限，传输类操作默认不限时，只在客户端断开时取消
+const (
+	defaultRequestTimeout  = 30 * time.Second
+	defaultTransferTimeout = 0
+)
+
+// transferRoutes 耗时取决于文件大小或仓库规模的操作，按 "方法 路由" 索引，使用传输时限
+var transferRoutes = map[string]bool{
+	"GET /api/raw/:owner/:repo/*path":  true,
+	"PUT /api/raw/:owner/:repo/*path":  true,
+	"PUT /api/file/:owner/:repo/*path": true, // JSON 上传，内容以 base64 放在请求体中
+	"GET /api/audit/export":            true,
+
+	"POST /api/snapshots/:owner/:repo":               true,
+	"POST /api/snapshots/:owner/:repo/:name/restore": true,
+}
+
+// operationTimeouts 每个请求调用存储后端的时限，0 表示不限时
+type operationTimeouts struct {
+	request  time.Duration
+	transfer time.Duration
+}
+
+// operationTimeoutsFromEnv 读取 API_REQUEST_TIMEOUT 和 API_TRANSFER_TIMEOUT，值为 Go 的时长格式，0 表示不限时
+func operationTimeoutsFromEnv() (operationTimeouts, error) {
+	timeouts := operationTimeouts{
+		request:  defaultRequestTimeout,
+		transfer: defaultTransferTimeout,
+	}
+	for name, target := range map[string]*time.Duration{
+		"API_REQUEST_TIMEOUT":  &timeouts.request,
+		"API_TRANSFER_TIMEOUT": &timeouts.transfer,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return operationTimeouts{}, fmt.Errorf("invalid %s: %q", name, value)
+		}
+		*target = d
+	}
+	return timeouts, nil
+}
+
+// forRoute 返回路由适用的时限
+func (t operationTimeouts) forRoute(method, route string) time.Duration {
+	if transferRoutes[method+" "+route] {
+		return t.transfer
+	}
+	return t.request
+}
+
+// timeoutMiddleware 为请求上下文设置截止时间，处理器把它传给存储后端，超时或客户端断开时上游请求随之取消
+func timeoutMiddleware(timeouts operationTimeouts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := timeouts.forRoute(c.Request.Method, c.FullPath())
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOperationTimeoutForRoute(t *testing.T) {
+	timeouts := operationTimeouts{request: 30 * time.Second, transfer: 10 * time.Minute}
+
+	tests := []struct {
+		method, route string
+		want          time.Duration
+	}{
+		{"PUT", "/api/raw/:owner/:repo/*path", timeouts.transfer},
+		{"PUT", "/api/file/:owner/:repo/*path", timeouts.transfer},
+		{"GET", "/api/file/:owner/:repo/*path", timeouts.request},
+		{"DELETE", "/api/file/:owner/:repo/*path", timeouts.request},
+	}
+	for _, tt := range tests {
+		if got := timeouts.forRoute(tt.method, tt.route); got != tt.want {
+			t.Errorf("%s %s = %s, want %s", tt.method, tt.route, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 操作结果
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Entry 一条审计记录
+type Entry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	// Login 发起操作的用户，GitHub 登录名或本地账户名，匿名请求为空
+	Login string `json:"login"`
+	// Actor 身份来源：github、local、api_key 或 anonymous
+	Actor string `json:"actor"`
+	// APIKey 使用 API key 时的 key ID
+	APIKey string `json:"api_key,omitempty"`
+	IP     string `json:"ip"`
+	// Action 操作名称，例如 file.write、repo.delete
+	Action string `json:"action"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Owner  string `json:"owner,omitempty"`
+	Repo   string `json:"repo,omitempty"`
+	// File 文件操作的文件路径
+	File   string `json:"file,omitempty"`
+	Status int    `json:"status"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Filter 查询条件，零值字段不参与过滤
+type Filter struct {
+	Login  string
+	Action string
+	Owner  string
+	Repo   string
+	// File 文件路径前缀
+	File   string
+	Result string
+	Since  time.Time
+	Until  time.Time
+}
+
+// Match 检查记录是否满足查询条件
+func (f Filter) Match(e *Entry) bool {
+	if f.Login != "" && !strings.EqualFold(e.Login, f.Login) {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action && !strings.HasPrefix(e.Action, f.Action+".") {
+		return false
+	}
+	if f.Owner != "" && !strings.EqualFold(e.Owner, f.Owner) {
+		return false
+	}
+	if f.Repo != "" && !strings.EqualFold(e.Repo, f.Repo) {
+		return false
+	}
+	if f.File != "" && !strings.HasPrefix(e.File, strings.Trim(f.File, "/")) {
+		return false
+	}
+	if f.Result != "" && e.Result != f.Result {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Log 只追加的审计日志，每行一条 JSON 记录
+type Log struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// Open 打开审计日志，文件不存在时创建
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	return &Log{path: path, file: file}, nil
+}
+
+// Append 追加一条记录
+func (l *Log) Append(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// 单次 write 写入整行，即使进程崩溃也不会与其他记录交错
+	_, err = l.file.Write(data)
+	return err
+}
+
+// Each 按时间顺序遍历满足条件的记录，fn 返回 false 时停止
+func (l *Log) Each(filter Filter, fn func(entry *Entry) bool) error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var entry Entry
+			// 跳过无法解析的行，例如磁盘写满时留下的半行
+			if json.Unmarshal(line, &entry) == nil && filter.Match(&entry) {
+				if !fn(&entry) {
+					return nil
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Query 返回满足条件的记录，最新的在前
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	entries := []Entry{}
+	err := l.Each(filter, func(entry *Entry) bool {
+		entries = append(entries, *entry)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Close 关闭日志文件
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
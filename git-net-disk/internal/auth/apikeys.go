@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKeyPrefix 服务器签发的 API key 的前缀，用于与 GitHub token 区分
+const APIKeyPrefix = "gnd_"
+
+// lastUsedSaveInterval 最近使用时间的持久化间隔，避免每个请求都写文件
+const lastUsedSaveInterval = time.Minute
+
+// API key 允许的操作
+const (
+	OperationRead   = "read"
+	OperationWrite  = "write"
+	OperationDelete = "delete"
+)
+
+// API key 的错误类型，调用方可以用 errors.Is 判断
+var (
+	// ErrAPIKeyNotFound key 不存在或已被撤销
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyExpired key 已过期
+	ErrAPIKeyExpired = errors.New("API key has expired")
+	// ErrInvalidAPIKey key 的作用范围或操作不符合要求
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+// KeyScope API key 可访问的仓库和路径前缀
+type KeyScope struct {
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+	// Path 路径前缀，为空表示整个仓库
+	Path string `json:"path,omitempty"`
+}
+
+// Covers 检查作用范围是否包含仓库中的路径
+func (s KeyScope) Covers(owner, repo, path string) bool {
+	if !strings.EqualFold(s.Owner, owner) || !strings.EqualFold(s.Repo, repo) {
+		return false
+	}
+	if s.Path == "" {
+		return true
+	}
+	path = strings.Trim(path, "/")
+	return path == s.Path || strings.HasPrefix(path, s.Path+"/")
+}
+
+// APIKey 服务器签发的长期凭据，只能在限定的仓库、路径和操作范围内使用
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Hint key 的前几位，便于在列表中辨认
+	Hint string `json:"hint"`
+	// Hash key 的 SHA-256，服务器不保存明文
+	Hash string `json:"hash"`
+	// Owner 创建者的用户名
+	Owner string `json:"owner"`
+	// Local 创建者为本地账户，请求使用服务器 token 并受账户授权限制
+	Local bool `json:"local"`
+	// Token 创建者的 GitHub token，AES-GCM 加密，本地账户为空
+	Token      string     `json:"token,omitempty"`
+	Scopes     []KeyScope `json:"scopes"`
+	Operations []string   `json:"operations"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+}
+
+// Allows 检查 key 是否允许对仓库中的路径执行操作
+func (k *APIKey) Allows(owner, repo, path, operation string) bool {
+	allowed := false
+	for _, op := range k.Operations {
+		if op == operation {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false
+	}
+	for _, scope := range k.Scopes {
+		if scope.Covers(owner, repo, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired 检查 key 是否已过期
+func (k *APIKey) Expired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// ValidateKeyScopes 检查并规范化作用范围和操作
+func ValidateKeyScopes(scopes []KeyScope, operations []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+	for i := range scopes {
+		if scopes[i].Owner == "" || scopes[i].Repo == "" {
+			return fmt.Errorf("%w: scope requires owner and repo", ErrInvalidAPIKey)
+		}
+		scopes[i].Path = strings.Trim(scopes[i].Path, "/")
+	}
+	if len(operations) == 0 {
+		return fmt.Errorf("%w: at least one operation is required", ErrInvalidAPIKey)
+	}
+	for _, op := range operations {
+		if op != OperationRead && op != OperationWrite && op != OperationDelete {
+			return fmt.Errorf("%w: unsupported operation %q, expected read, write or delete", ErrInvalidAPIKey, op)
+		}
+	}
+	return nil
+}
+
+// APIKeyStore API key 存储，path 非空时持久化到 JSON 文件
+type APIKeyStore struct {
+	mu   sync.Mutex
+	aead cipher.AEAD
+	path string
+	keys map[string]*APIKey // 按 ID 索引
+	// lastSaved 上次持久化的时间，用于限制最近使用时间的写入频率
+	lastSaved time.Time
+}
+
+// NewAPIKeyStore 创建 API key 存储，secret 用于加密创建者的 GitHub token
+func NewAPIKeyStore(secret, path string) (*APIKeyStore, error) {
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &APIKeyStore{
+		aead: aead,
+		path: path,
+		keys: make(map[string]*APIKey),
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read API key store: %v", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &store.keys); err != nil {
+				return nil, fmt.Errorf("invalid API key store: %v", err)
+			}
+		}
+	}
+	return store, nil
+}
+
+// Create 签发新 key，返回 key 的记录和只显示一次的明文
+// token 为创建者的 GitHub token，本地账户传空字符串
+func (s *APIKeyStore) Create(name, owner string, local bool, token string, scopes []KeyScope, operations []string, expiresAt *time.Time) (*APIKey, string, error) {
+	if err := ValidateKeyScopes(scopes, operations); err != nil {
+		return nil, "", err
+	}
+
+	id, err := RandomString(9)
+	if err != nil {
+		return nil, "", err
+	}
+	random, err := RandomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+	secret := APIKeyPrefix + random
+
+	key := &APIKey{
+		ID:         id,
+		Name:       name,
+		Hint:       secret[:len(APIKeyPrefix)+6],
+		Hash:       hashAPIKey(secret),
+		Owner:      owner,
+		Local:      local,
+		Scopes:     scopes,
+		Operations: operations,
+		CreatedAt:  time.Now(),
+		ExpiresAt:  expiresAt,
+	}
+	if token != "" {
+		key.Token, err = sealString(s.aead, token)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[id] = key
+	if err := s.saveLocked(); err != nil {
+		delete(s.keys, id)
+		return nil, "", err
+	}
+	copied := *key
+	return &copied, secret, nil
+}
+
+// Authenticate 校验 key 明文，返回 key 和创建者的 GitHub token，并记录最近使用时间
+func (s *APIKeyStore) Authenticate(secret, ip string) (*APIKey, string, error) {
+	hash := hashAPIKey(secret)
+
+	s.mu.Lock()
+	var key *APIKey
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		s.mu.Unlock()
+		return nil, "", ErrAPIKeyNotFound
+	}
+	if key.Expired() {
+		s.mu.Unlock()
+		return nil, "", ErrAPIKeyExpired
+	}
+
+	now := time.Now()
+	key.LastUsedAt = &now
+	key.LastUsedIP = ip
+	if now.Sub(s.lastSaved) >= lastUsedSaveInterval {
+		if err := s.saveLocked(); err != nil {
+			fmt.Printf("[WARN] Failed to save API key usage: %v\n", err)
+		}
+	}
+	copied := *key
+	s.mu.Unlock()
+
+	if copied.Token == "" {
+		return &copied, "", nil
+	}
+	token, err := openString(s.aead, copied.Token)
+	if err != nil {
+		return nil, "", err
+	}
+	return &copied, token, nil
+}
+
+// List 列出 owner 创建的 key，按创建时间排序
+func (s *APIKeyStore) List(owner string, local bool) []APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []APIKey{}
+	for _, key := range s.keys {
+		if key.Owner == owner && key.Local == local {
+			keys = append(keys, *key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+// Delete 撤销 owner 创建的 key
+func (s *APIKeyStore) Delete(id, owner string, local bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok || key.Owner != owner || key.Local != local {
+		return ErrAPIKeyNotFound
+	}
+	delete(s.keys, id)
+	if err := s.saveLocked(); err != nil {
+		s.keys[id] = key
+		return err
+	}
+	return nil
+}
+
+// DeleteOwner 撤销 owner 创建的所有 key，用于删除本地账户
+func (s *APIKeyStore) DeleteOwner(owner string, local bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, key := range s.keys {
+		if key.Owner == owner && key.Local == local {
+			delete(s.keys, id)
+		}
+	}
+	return s.saveLocked()
+}
+
+// saveLocked 持久化 key，调用方需持有锁
+func (s *APIKeyStore) saveLocked() error {
+	s.lastSaved = time.Now()
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免写到一半时崩溃损坏存储
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// hashAPIKey 计算 key 明文的 SHA-256
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound 会话不存在或已过期
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session 登录会话，GitHub token 加密保存
+type Session struct {
+	ID    string `json:"id"`
+	Login string `json:"login"`
+	Token string `json:"token"` // AES-GCM 加密后的 token，base64 编码
+	// Local 本地账户的会话，不保存 token，请求使用服务器配置的 token
+	Local     bool      `json:"local,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SessionStore 服务端会话存储，path 非空时持久化到 JSON 文件
+type SessionStore struct {
+	mu       sync.Mutex
+	aead     cipher.AEAD
+	path     string
+	ttl      time.Duration
+	sessions map[string]*Session
+}
+
+// NewSessionStore 创建会话存储，secret 用于派生加密 token 的密钥
+func NewSessionStore(secret, path string, ttl time.Duration) (*SessionStore, error) {
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &SessionStore{
+		aead:     aead,
+		path:     path,
+		ttl:      ttl,
+		sessions: make(map[string]*Session),
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read session store: %v", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &store.sessions); err != nil {
+				return nil, fmt.Errorf("invalid session store: %v", err)
+			}
+		}
+	}
+	return store, nil
+}
+
+// Create 为 token 创建新会话，返回会话 ID
+func (s *SessionStore) Create(login, token string) (*Session, error) {
+	id, err := RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.seal(token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:        id,
+		Login:     login,
+		Token:     sealed,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = session
+	return session, s.saveLocked()
+}
+
+// CreateLocal 为本地账户创建会话
+func (s *SessionStore) CreateLocal(username string) (*Session, error) {
+	id, err := RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:        id,
+		Login:     username,
+		Local:     true,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = session
+	return session, s.saveLocked()
+}
+
+// DeleteUser 删除本地账户的所有会话，用于删除账户或修改密码后强制重新登录
+func (s *SessionStore) DeleteUser(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if session.Local && session.Login == username {
+			delete(s.sessions, id)
+		}
+	}
+	return s.saveLocked()
+}
+
+// Get 返回会话和解密后的 token
+
+func (s *SessionStore) Get(id string) (*Session, string, error) {
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	if ok && time.Now().After(session.ExpiresAt) {
+		delete(s.sessions, id)
+		s.saveLocked()
+		ok = false
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, "", ErrSessionNotFound
+	}
+
+	if session.Local {
+		return session, "", nil
+	}
+
+	token, err := s.open(session.Token)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// Delete 删除会话
+func (s *SessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return s.saveLocked()
+}
+
+// saveLocked 持久化会话，调用方需持有锁
+func (s *SessionStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免写到一半时崩溃损坏存储
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// seal 加密 token
+func (s *SessionStore) seal(plaintext string) (string, error) {
+	return sealString(s.aead, plaintext)
+}
+
+// open 解密 token
+func (s *SessionStore) open(sealed string) (string, error) {
+	return openString(s.aead, sealed)
+}
+
+// newAEAD 由 secret 派生 AES-GCM 密钥
+func newAEAD(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealString 加密字符串，返回 base64 编码的 nonce 和密文
+func sealString(aead cipher.AEAD, plaintext string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openString 解密 sealString 的结果
+func openString(aead cipher.AEAD, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid sealed token")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// RandomString 生成 n 字节随机数的 URL 安全编码
+func RandomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 用户存储的错误类型，调用方可以用 errors.Is 判断
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists 用户名已被占用
+	ErrUserExists = errors.New("user already exists")
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrInvalidAccount 用户名、密码、角色或授权不符合要求
+	ErrInvalidAccount = errors.New("invalid account")
+)
+
+// 本地账户的角色
+const (
+	// RoleAdmin 管理员可以访问服务器 token 能访问的所有仓库，并管理账户
+	RoleAdmin = "admin"
+	// RoleMember 成员只能访问自己的主目录和被授权的目录
+	RoleMember = "member"
+)
+
+// 授权的访问级别
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// minPasswordLength 密码最短长度
+const minPasswordLength = 8
+
+// dummyHash 用户不存在时参与比较的哈希，首次使用时计算
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("git-net-disk"), bcrypt.DefaultCost)
+	return hash
+})
+
+// usernamePattern 用户名同时用作仓库名和目录名，只允许小写字母、数字、- 和 _
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,38}$`)
+
+// Grant 对仓库或仓库中某个目录的访问授权
+type Grant struct {
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+	// Path 目录前缀，为空表示整个仓库
+	Path   string `json:"path,omitempty"`
+	Access string `json:"access"` // read 或 write
+}
+
+// Covers 检查授权是否覆盖仓库中的路径，write 为 true 时还要求写权限
+func (g Grant) Covers(owner, repo, path string, write bool) bool {
+	if !strings.EqualFold(g.Owner, owner) || !strings.EqualFold(g.Repo, repo) {
+		return false
+	}
+	if write && g.Access != AccessWrite {
+		return false
+	}
+	if g.Path == "" {
+		return true
+	}
+	path = strings.Trim(path, "/")
+	return path == g.Path || strings.HasPrefix(path, g.Path+"/")
+}
+
+// User 本地账户，成员使用服务器配置的 GitHub token 访问自己的主目录
+type User struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+	// Home 主目录，成员对其有写权限
+	Home Grant `json:"home"`
+	// Grants 管理员额外授予的访问权限
+	Grants    []Grant   `json:"grants"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Allowed 检查用户是否可以访问仓库中的路径
+func (u *User) Allowed(owner, repo, path string, write bool) bool {
+	if u.Role == RoleAdmin {
+		return true
+	}
+	if u.Home.Repo != "" && u.Home.Covers(owner, repo, path, write) {
+		return true
+	}
+	for _, grant := range u.Grants {
+		if grant.Covers(owner, repo, path, write) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanSeeRepository 检查用户是否有仓库中任意位置的访问权限，用于过滤仓库列表
+func (u *User) CanSeeRepository(owner, repo string) bool {
+	if u.Role == RoleAdmin {
+		return true
+	}
+	for _, grant := range append([]Grant{u.Home}, u.Grants...) {
+		if strings.EqualFold(grant.Owner, owner) && strings.EqualFold(grant.Repo, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRole 检查角色是否为 admin 或 member
+func ValidateRole(role string) error {
+	if role != RoleAdmin && role != RoleMember {
+		return fmt.Errorf("%w: unsupported role %q, expected admin or member", ErrInvalidAccount, role)
+	}
+	return nil
+}
+
+// ValidateUsername 检查用户名是否合法
+func ValidateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("%w: username %q must be 1-39 lowercase letters, digits, '-' or '_'", ErrInvalidAccount, username)
+	}
+	return nil
+}
+
+// ValidateGrant 检查并规范化授权
+func ValidateGrant(grant *Grant) error {
+	if grant.Owner == "" || grant.Repo == "" {
+		return fmt.Errorf("%w: grant requires owner and repo", ErrInvalidAccount)
+	}
+	if grant.Access != AccessRead && grant.Access != AccessWrite {
+		return fmt.Errorf("%w: unsupported access %q, expected read or write", ErrInvalidAccount, grant.Access)
+	}
+	grant.Path = strings.Trim(grant.Path, "/")
+	return nil
+}
+
+// usersBucket 存放账户的 bbolt bucket，键为用户名，值为账户的 JSON
+var usersBucket = []byte("users")
+
+// openTimeout 等待其他进程释放数据库文件锁的时间
+var openTimeout = 5 * time.Second
+
+// UserStore 本地账户存储，持久化到嵌入式 bbolt 数据库
+// 每次修改在一个事务中完成，数据库文件被打开它的进程独占锁定
+type UserStore struct {
+	db *bolt.DB
+}
+
+// NewUserStore 打开账户数据库，文件不存在时创建空数据库
+func NewUserStore(path string) (*UserStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create user store directory: %v", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("user store %s is locked by another process", path)
+		}
+		return nil, fmt.Errorf("failed to open user store: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(usersBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize user store: %v", err)
+	}
+	return &UserStore{db: db}, nil
+}
+
+// Close 关闭数据库并释放文件锁
+func (s *UserStore) Close() error {
+	return s.db.Close()
+}
+
+// Len 返回账户数量
+func (s *UserStore) Len() int {
+	n := 0
+	s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(usersBucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+// Create 创建账户，home 为成员的主目录
+func (s *UserStore) Create(username, password, role string, home Grant) (*User, error) {
+	if err := ValidateUsername(username); err != nil {
+		return nil, err
+	}
+	if err := ValidateRole(role); err != nil {
+		return nil, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		Home:         home,
+		Grants:       []Grant{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket.Get([]byte(username)) != nil {
+			return fmt.Errorf("%w: %s", ErrUserExists, username)
+		}
+		return putUser(bucket, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Get 返回账户
+func (s *UserStore) Get(username string) (*User, error) {
+	var user *User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		user, err = getUser(tx.Bucket(usersBucket), username)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// List 按用户名顺序列出所有账户
+func (s *UserStore) List() []User {
+	users := []User{}
+	s.db.View(func(tx *bolt.Tx) error {
+		// bbolt 按键的字节序遍历，即按用户名排序
+		return tx.Bucket(usersBucket).ForEach(func(key, value []byte) error {
+			var user User
+			if err := json.Unmarshal(value, &user); err != nil {
+				fmt.Printf("[WARN] Skipping unreadable account %s: %v\n", key, err)
+				return nil
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+	return users
+}
+
+// Authenticate 校验用户名和密码
+func (s *UserStore) Authenticate(username, password string) (*User, error) {
+	user, err := s.Get(username)
+	if err != nil {
+		if !errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		// 用户不存在时也计算一次哈希，避免通过响应时间猜测用户名
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// Update 在一个事务中读取并修改账户，fn 返回错误时不保存
+func (s *UserStore) Update(username string, fn func(user *User) error) (*User, error) {
+	var user *User
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		var err error
+		user, err = getUser(bucket, username)
+		if err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+		user.UpdatedAt = time.Now()
+		return putUser(bucket, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetPassword 修改密码
+func (s *UserStore) SetPassword(username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = s.Update(username, func(user *User) error {
+		user.PasswordHash = hash
+		return nil
+	})
+	return err
+}
+
+// Delete 删除账户，主目录中的数据保留
+func (s *UserStore) Delete(username string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		if bucket.Get([]byte(username)) == nil {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, username)
+		}
+		return bucket.Delete([]byte(username))
+	})
+}
+
+// getUser 从 bucket 中读取账户，返回的账户不引用事务内的内存
+func getUser(bucket *bolt.Bucket, username string) (*User, error) {
+	data := bucket.Get([]byte(username))
+	if data == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	var user User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, fmt.Errorf("invalid account %s: %v", username, err)
+	}
+	return &user, nil
+}
+
+// putUser 把账户写入 bucket
+func putUser(bucket *bolt.Bucket, user *User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(user.Username), data)
+}
+
+// ValidatePassword 检查密码长度
+func ValidatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("%w: password must be at least %d characters", ErrInvalidAccount, minPasswordLength)
+	}
+	return nil
+}
+
+// hashPassword 校验密码并计算 bcrypt 哈希
+func hashPassword(password string) (string, error) {
+	if err := ValidatePassword(password); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openStore 打开临时目录中的账户数据库，测试结束时关闭
+func openStore(t *testing.T, path string) *UserStore {
+	t.Helper()
+	store, err := NewUserStore(path)
+	if err != nil {
+		t.Fatalf("NewUserStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestUserStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	store := openStore(t, path)
+
+	if _, err := store.Create("bob", "correct horse battery", RoleMember, Grant{Owner: "o", Repo: "bob", Access: AccessWrite}); err != nil {
+		t.Fatalf("Create bob: %v", err)
+	}
+	if _, err := store.Create("alice", "correct horse battery", RoleAdmin, Grant{}); err != nil {
+		t.Fatalf("Create alice: %v", err)
+	}
+	if _, err := store.Create("bob", "correct horse battery", RoleMember, Grant{}); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("Create duplicate err = %v, want ErrUserExists", err)
+	}
+
+	// fn 返回错误时不保存修改
+	_, err := store.Update("bob", func(user *User) error {
+		user.Role = RoleAdmin
+		return ErrInvalidAccount
+	})
+	if !errors.Is(err, ErrInvalidAccount) {
+		t.Fatalf("Update err = %v, want ErrInvalidAccount", err)
+	}
+	if _, err := store.Update("bob", func(user *User) error {
+		user.Grants = append(user.Grants, Grant{Owner: "o", Repo: "shared", Access: AccessRead})
+		return nil
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := store.Delete("alice"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete("alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Delete missing err = %v, want ErrUserNotFound", err)
+	}
+	store.Close()
+
+	reopened := openStore(t, path)
+	if n := reopened.Len(); n != 1 {
+		t.Fatalf("Len after reopen = %d, want 1", n)
+	}
+	user, err := reopened.Authenticate("bob", "correct horse battery")
+	if err != nil {
+		t.Fatalf("Authenticate after reopen: %v", err)
+	}
+	if user.Role != RoleMember || len(user.Grants) != 1 || !user.Allowed("o", "shared", "docs", false) {
+		t.Fatalf("account after reopen = %+v", user)
+	}
+	if _, err := reopened.Authenticate("bob", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate with wrong password err = %v", err)
+	}
+	if _, err := reopened.Authenticate("alice", "correct horse battery"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate deleted account err = %v", err)
+	}
+}
+
+func TestUserStoreIsLocked(t *testing.T) {
+	timeout := openTimeout
+	openTimeout = 100 * time.Millisecond
+	defer func() { openTimeout = timeout }()
+
+	path := filepath.Join(t.TempDir(), "users.db")
+	openStore(t, path)
+	if store, err := NewUserStore(path); err == nil {
+		store.Close()
+		t.Fatal("a second NewUserStore on an open database should fail")
+	}
+}
@@ -0,0 +1,395 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git-net-disk/internal/proxy"
+)
+
+// tokenRefreshMargin 安装 token 在过期前多久刷新
+const tokenRefreshMargin = 5 * time.Minute
+
+// AppAuth 以 GitHub App 安装身份认证，缓存并自动刷新安装 token
+type AppAuth struct {
+	Client         *http.Client
+	appID          string
+	installationID int64
+	key            *rsa.PrivateKey
+	// tokenURL 换取安装 token 的地址，可指向本地模拟服务
+	tokenURL string
+	// now 当前时间，便于测试时固定 JWT 的签发时间
+	now func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewAppAuth 创建 GitHub App 认证，privateKeyPEM 为 App 设置页面下载的私钥
+// tokenURL 为空时使用 {APIURL}/app/installations/{id}/access_tokens
+func NewAppAuth(appID string, installationID int64, privateKeyPEM []byte, endpoints Endpoints, tokenURL string) (*AppAuth, error) {
+	key, err := ParsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	client, err := proxy.NewHTTPClient(proxy.ProxyConfig{Enabled: false})
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenURL == "" {
+		endpoints = DefaultEndpoints().Override(endpoints)
+		tokenURL = fmt.Sprintf("%s/app/installations/%d/access_tokens", endpoints.APIURL, installationID)
+	}
+
+	return &AppAuth{
+		Client:         client,
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		tokenURL:       tokenURL,
+		now:            time.Now,
+	}, nil
+}
+
+// ParsePrivateKey 解析 PKCS#1 或 PKCS#8 格式的 RSA 私钥
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid GitHub App private key: not an RSA key")
+	}
+	return key, nil
+}
+
+// JWT 生成用于换取安装 token 的 RS256 JWT，有效期 9 分钟
+// iat 提前 60 秒以容忍与 GitHub 之间的时钟偏差
+func (a *AppAuth) JWT() (string, error) {
+	now := a.now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token 返回有效的安装 token，缓存的 token 即将过期时自动刷新
+func (a *AppAuth) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && a.now().Add(tokenRefreshMargin).Before(a.expires) {
+		return a.token, nil
+	}
+
+	jwt, err := a.JWT()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", "GitNetDisk")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create installation token: %s - %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	a.token = result.Token
+	a.expires = result.ExpiresAt
+	return a.token, nil
+}
+
+// NewAppClient 创建以 App 安装身份访问 GitHub 的客户端，不需要用户 token
+func NewAppClient(auth *AppAuth, proxyConfig proxy.ProxyConfig, endpoints Endpoints) (*Client, error) {
+	identity := fmt.Sprintf("installation:%d", auth.installationID)
+	client, err := newClient("", identity, proxyConfig, endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只对 GitHub 自身的地址附加 token，Release 附件会重定向到对象存储，不能带上认证头
+	hosts := make(map[string]bool)
+	for _, raw := range []string{client.baseURL, client.uploadURL, client.rawURL} {
+		if u, err := neturl.Parse(raw); err == nil {
+			hosts[u.Host] = true
+		}
+	}
+
+	base := client.Client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Client.Transport = &appTransport{base: base, auth: auth, hosts: hosts}
+	client.installation = true
+	client.quotaKey = quotaKey(client.baseURL, identity)
+	return client, nil
+}
+
+// appTransport 为每个请求附加安装 token
+type appTransport struct {
+	base  http.RoundTripper
+	auth  *AppAuth
+	hosts map[string]bool
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.hosts[req.URL.Host] || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	token, err := t.auth.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrip 不能修改传入的请求
+	clone := req.Clone(req.Context())
+	clone.Header.Set("Authorization", "token "+token)
+	return t.base.RoundTrip(clone)
+}
+
+// NewPublicAppClient 与 NewAppClient 相同，但只允许只读访问公开仓库，用于没有 token 的匿名请求
+// 匿名请求借用安装的配额，不能借用安装对私有仓库的权限：写请求返回 ErrForbidden，私有仓库按不存在处理
+func NewPublicAppClient(auth *AppAuth, proxyConfig proxy.ProxyConfig, endpoints Endpoints) (*Client, error) {
+	client, err := NewAppClient(auth, proxyConfig, endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := neturl.Parse(client.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := neturl.Parse(client.rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client.Client.Transport = &publicTransport{
+		base:    client.Client.Transport,
+		api:     api,
+		raw:     raw,
+		private: make(map[string]bool),
+	}
+	return client, nil
+}
+
+// publicTransport 拒绝写请求和公开仓库以外的 GitHub 接口
+type publicTransport struct {
+	base http.RoundTripper
+	api  *neturl.URL
+	raw  *neturl.URL
+
+	mu sync.Mutex
+	// private 按 owner/repo 缓存仓库是否私有
+	private map[string]bool
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *publicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.api.Host && req.URL.Host != t.raw.Host {
+		// Release 附件重定向到的对象存储，链接本身已由 GitHub 签名
+		return t.base.RoundTrip(req)
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return nil, fmt.Errorf("%w: anonymous requests are read-only", ErrForbidden)
+	}
+
+	var owner, repo string
+	switch {
+	case req.URL.Host == t.api.Host && strings.HasPrefix(req.URL.Path, t.api.Path+"/repos/"):
+		owner, repo = splitRepoPath(strings.TrimPrefix(req.URL.Path, t.api.Path+"/repos/"))
+	case req.URL.Host == t.api.Host && req.URL.Path == t.api.Path+"/rate_limit":
+		return t.base.RoundTrip(req)
+	case req.URL.Host == t.raw.Host && strings.HasPrefix(req.URL.Path, t.raw.Path+"/"):
+		owner, repo = splitRepoPath(strings.TrimPrefix(req.URL.Path, t.raw.Path+"/"))
+	}
+	if owner == "" || repo == "" {
+		return nil, fmt.Errorf("%w: sign in to use %s", ErrForbidden, req.URL.Path)
+	}
+
+	private, err := t.isPrivate(req, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	if private {
+		return nil, fmt.Errorf("%w: repository %s/%s", ErrNotFound, owner, repo)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// isPrivate 查询仓库是否私有，仓库不存在时返回 false，由原请求得到 404
+func (t *publicTransport) isPrivate(req *http.Request, owner, repo string) (bool, error) {
+	key := owner + "/" + repo
+	t.mu.Lock()
+	private, ok := t.private[key]
+	t.mu.Unlock()
+	if ok {
+		return private, nil
+	}
+
+	url := *t.api
+	url.Path = t.api.Path + "/repos/" + key
+	check, err := http.NewRequestWithContext(req.Context(), http.MethodGet, url.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	check.Header.Set("Accept", "application/vnd.github.v3+json")
+	check.Header.Set("User-Agent", "GitNetDisk")
+
+	resp, err := t.base.RoundTrip(check)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		var repository struct {
+			Private bool `json:"private"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&repository); err != nil {
+			return false, err
+		}
+		private = repository.Private
+	}
+
+	t.mu.Lock()
+	t.private[key] = private
+	t.mu.Unlock()
+	return private, nil
+}
+
+// splitRepoPath 从 owner/repo/... 形式的路径中取出所有者和仓库名
+func splitRepoPath(path string) (string, string) {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// listInstallationRepositories 列出 App 安装可访问的所有仓库
+func (c *Client) listInstallationRepositories(ctx context.Context) ([]Repository, error) {
+	repositories := []Repository{}
+	url := fmt.Sprintf("%s/installation/repositories?per_page=100", c.baseURL)
+	for url != "" {
+		var page struct {
+			Repositories []Repository `json:"repositories"`
+		}
+
+		req, err := c.newJSONRequest(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := c.handleError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		repositories = append(repositories, page.Repositories...)
+		url = NextPageURL(resp.Header.Get("Link"))
+	}
+	return repositories, nil
+}
+
+// AppAuthFromEnv 读取 GitHub App 配置，未设置 GITHUB_APP_ID 时返回 nil
+// GITHUB_APP_INSTALLATION_ID 为安装 ID，私钥来自 GITHUB_APP_PRIVATE_KEY 或 GITHUB_APP_PRIVATE_KEY_FILE，
+// GITHUB_APP_TOKEN_URL 可覆盖换取安装 token 的地址
+func AppAuthFromEnv(endpoints Endpoints) (*AppAuth, error) {
+	appID := os.Getenv("GITHUB_APP_ID")
+	if appID == "" {
+		return nil, nil
+	}
+
+	installationID, err := strconv.ParseInt(os.Getenv("GITHUB_APP_INSTALLATION_ID"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GITHUB_APP_INSTALLATION_ID: %v", err)
+	}
+
+	key := []byte(os.Getenv("GITHUB_APP_PRIVATE_KEY"))
+	if path := os.Getenv("GITHUB_APP_PRIVATE_KEY_FILE"); len(key) == 0 && path != "" {
+		key, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App private key: %v", err)
+		}
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("GITHUB_APP_PRIVATE_KEY or GITHUB_APP_PRIVATE_KEY_FILE is required")
+	}
+
+	return NewAppAuth(appID, installationID, key, endpoints, os.Getenv("GITHUB_APP_TOKEN_URL"))
+}
@@ -0,0 +1,232 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git-net-disk/internal/proxy"
+)
+
+// newTestKey 生成测试用的 RSA 私钥及其 PKCS#1 PEM
+func newTestKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// verifyJWT 校验 RS256 签名并返回 claims
+func verifyJWT(t *testing.T, jwt string, key *rsa.PublicKey) map[string]interface{} {
+	t.Helper()
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT has %d parts", len(parts))
+	}
+
+	var header map[string]string
+	data, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if err := json.Unmarshal(data, &header); err != nil || header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Fatalf("JWT header = %s", data)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("JWT signature: %v", err)
+	}
+
+	var claims map[string]interface{}
+	data, _ = base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(data, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+// fakeTokenEndpoint 模拟换取安装 token 的接口，每次签发新 token，有效期 1 小时
+type fakeTokenEndpoint struct {
+	t      *testing.T
+	key    *rsa.PublicKey
+	now    func() time.Time
+	issued atomic.Int32
+}
+
+func (f *fakeTokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.URL.Path != "/app/installations/42/access_tokens" {
+		http.NotFound(w, r)
+		return
+	}
+
+	claims := verifyJWT(f.t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), f.key)
+	now := f.now()
+	if claims["iss"] != "1234" {
+		f.t.Errorf("iss = %v, want 1234", claims["iss"])
+	}
+	if iat := int64(claims["iat"].(float64)); iat != now.Add(-60*time.Second).Unix() {
+		f.t.Errorf("iat = %d, want 60s before now", iat)
+	}
+	if exp := int64(claims["exp"].(float64)); exp != now.Add(9*time.Minute).Unix() {
+		f.t.Errorf("exp = %d, want 9m after now", exp)
+	}
+
+	n := f.issued.Add(1)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":      fmt.Sprintf("inst-token-%d", n),
+		"expires_at": now.Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+}
+
+// newTestAppAuth 创建指向模拟 token 接口的 App 认证，返回可调整的当前时间和模拟服务的接口地址
+func newTestAppAuth(t *testing.T, handler func(*fakeTokenEndpoint) http.Handler) (*AppAuth, *fakeTokenEndpoint, *time.Time, Endpoints) {
+	t.Helper()
+	key, keyPEM := newTestKey(t)
+	now := time.Now().Truncate(time.Second)
+
+	endpoint := &fakeTokenEndpoint{t: t, key: &key.PublicKey, now: func() time.Time { return now }}
+	server := httptest.NewServer(handler(endpoint))
+	t.Cleanup(server.Close)
+
+	t.Setenv("GITHUB_APP_ID", "1234")
+	t.Setenv("GITHUB_APP_INSTALLATION_ID", "42")
+	t.Setenv("GITHUB_APP_PRIVATE_KEY", string(keyPEM))
+	t.Setenv("GITHUB_APP_TOKEN_URL", server.URL+"/app/installations/42/access_tokens")
+
+	endpoints := Endpoints{APIURL: server.URL, RawURL: server.URL + "/raw"}
+	auth, err := AppAuthFromEnv(endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.now = func() time.Time { return now }
+	return auth, endpoint, &now, endpoints
+}
+
+func TestAppAuthTokenCachingAndRefresh(t *testing.T) {
+	auth, endpoint, now, _ := newTestAppAuth(t, func(f *fakeTokenEndpoint) http.Handler { return f })
+	endpoint.now = func() time.Time { return *now }
+	ctx := context.Background()
+
+	token, err := auth.Token(ctx)
+	if err != nil || token != "inst-token-1" {
+		t.Fatalf("Token = %q, %v", token, err)
+	}
+
+	// 有效期内复用缓存
+	*now = now.Add(30 * time.Minute)
+	if token, _ := auth.Token(ctx); token != "inst-token-1" || endpoint.issued.Load() != 1 {
+		t.Fatalf("cached Token = %q after %d requests", token, endpoint.issued.Load())
+	}
+
+	// 距过期不足 tokenRefreshMargin 时刷新
+	*now = now.Add(26 * time.Minute)
+	if token, _ := auth.Token(ctx); token != "inst-token-2" || endpoint.issued.Load() != 2 {
+		t.Fatalf("refreshed Token = %q after %d requests", token, endpoint.issued.Load())
+	}
+}
+
+func TestAppAuthTokenError(t *testing.T) {
+	auth, _, _, _ := newTestAppAuth(t, func(f *fakeTokenEndpoint) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message":"A JSON web token could not be decoded"}`, http.StatusUnauthorized)
+		})
+	})
+
+	if _, err := auth.Token(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Token err = %v, want the 401 from the token endpoint", err)
+	}
+}
+
+// newFakeAppGitHub 模拟 GitHub：alice/public 为公开仓库，alice/secret 为私有仓库，只接受安装 token
+func newFakeAppGitHub(t *testing.T) (*AppAuth, *atomic.Int32, Endpoints) {
+	t.Helper()
+	var writes atomic.Int32
+	auth, _, _, endpoints := newTestAppAuth(t, func(f *fakeTokenEndpoint) http.Handler {
+		mux := http.NewServeMux()
+		mux.Handle("/app/", f)
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "token inst-token-1" {
+				http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+				return
+			}
+			if r.Method != "GET" {
+				writes.Add(1)
+			}
+			switch r.URL.Path {
+			case "/repos/alice/public":
+				fmt.Fprint(w, `{"name":"public","private":false}`)
+			case "/repos/alice/secret":
+				fmt.Fprint(w, `{"name":"secret","private":true}`)
+			case "/repos/alice/public/contents/a.txt", "/repos/alice/secret/contents/a.txt":
+				fmt.Fprint(w, `{"name":"a.txt","path":"a.txt","sha":"abc","type":"file"}`)
+			case "/raw/alice/public/main/a.txt", "/raw/alice/secret/main/a.txt":
+				fmt.Fprint(w, "hello")
+			default:
+				http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			}
+		})
+		return mux
+	})
+	return auth, &writes, endpoints
+}
+
+func TestPublicAppClient(t *testing.T) {
+	auth, writes, endpoints := newFakeAppGitHub(t)
+	client, err := NewPublicAppClient(auth, proxy.ProxyConfig{}, endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if file, err := client.GetFileContent(ctx, "alice", "public", "a.txt", ""); err != nil || file.SHA != "abc" {
+		t.Fatalf("public file = %+v, %v", file, err)
+	}
+	if _, err := client.GetFileContent(ctx, "alice", "secret", "a.txt", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("private file err = %v, want ErrNotFound", err)
+	}
+	if resp, err := client.OpenRaw(ctx, "alice", "public", "main", "a.txt"); err != nil {
+		t.Fatalf("public raw: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+	if _, err := client.OpenRaw(ctx, "alice", "secret", "main", "a.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("private raw err = %v, want ErrNotFound", err)
+	}
+	if _, err := client.CreateOrUpdateFile(ctx, "alice", "public", "a.txt", "aGk=", "add", "", ""); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("write err = %v, want ErrForbidden", err)
+	}
+	if _, err := client.GetUser(ctx); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("GetUser err = %v, want ErrForbidden", err)
+	}
+	if writes.Load() != 0 {
+		t.Fatalf("%d writes reached GitHub", writes.Load())
+	}
+
+	// 完整的 App 客户端不受限制
+	full, err := NewAppClient(auth, proxy.ProxyConfig{}, endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := full.GetFileContent(ctx, "alice", "secret", "a.txt", ""); err != nil {
+		t.Fatalf("installation client private file: %v", err)
+	}
+}
@@ -0,0 +1,433 @@
+package github
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 缓存的默认大小
+const (
+	defaultCacheMemoryMB = 64
+	defaultCacheDiskMB   = 512
+)
+
+// cacheEntry 一条缓存的 GET 响应
+type cacheEntry struct {
+	Key string `json:"key"`
+	// Scope 失效范围，例如 https://api.github.com|/repos/owner/repo
+	Scope        string      `json:"scope"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	StoredAt     time.Time   `json:"stored_at"`
+}
+
+// size 条目占用的内存，按响应体估算
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.Body)) + int64(len(e.Key)) + 512
+}
+
+// responseCache GitHub 响应的条件请求缓存，内存 LRU 加可选的磁盘层
+// 条目只在 GitHub 返回 304 时使用，因此不会返回过期内容，写操作让相关条目失效以避开 GitHub 的复制延迟
+type responseCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	lru      *list.List // 最近使用的在前
+	items    map[string]*list.Element
+	// invalidated 各失效范围最近一次写操作的时间，早于它保存的条目不再使用
+	invalidated map[string]time.Time
+	disk        *diskCache
+}
+
+// responseCacheFromEnv 根据 GITHUB_CACHE_SIZE_MB 创建内存缓存，设置 GITHUB_CACHE_DIR 时启用磁盘层
+// GITHUB_CACHE=off 时禁用缓存
+func responseCacheFromEnv() *responseCache {
+	if os.Getenv("GITHUB_CACHE") == "off" {
+		return nil
+	}
+
+	memoryMB := defaultCacheMemoryMB
+	if n, err := strconv.Atoi(os.Getenv("GITHUB_CACHE_SIZE_MB")); err == nil && n > 0 {
+		memoryMB = n
+	}
+	cache := &responseCache{
+		maxBytes:    int64(memoryMB) << 20,
+		lru:         list.New(),
+		items:       make(map[string]*list.Element),
+		invalidated: make(map[string]time.Time),
+	}
+
+	if dir := os.Getenv("GITHUB_CACHE_DIR"); dir != "" {
+		diskMB := defaultCacheDiskMB
+		if n, err := strconv.Atoi(os.Getenv("GITHUB_CACHE_DISK_SIZE_MB")); err == nil && n > 0 {
+			diskMB = n
+		}
+		disk, err := openDiskCache(dir, int64(diskMB)<<20)
+		if err != nil {
+			fmt.Printf("[WARN] GitHub disk cache disabled: %v\n", err)
+		} else {
+			cache.disk = disk
+		}
+	}
+	return cache
+}
+
+// responseCacheInstance 进程内所有客户端共用的缓存，首次使用时根据环境变量创建
+var responseCacheInstance = sync.OnceValue(responseCacheFromEnv)
+
+// get 查找条目，内存未命中时读取磁盘层，条目所在范围在保存后被写过时视为未命中
+func (c *responseCache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if c.validLocked(entry) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry
+		}
+		c.removeLocked(elem)
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	if c.disk == nil {
+		return nil
+	}
+	entry := c.disk.load(key)
+	if entry == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.validLocked(entry) {
+		return nil
+	}
+	c.addLocked(entry)
+	return entry
+}
+
+// put 保存条目，磁盘层启用时同时写入磁盘
+func (c *responseCache) put(entry *cacheEntry) {
+	// 超过内存上限八分之一的响应不缓存，避免一个大目录挤掉其他条目
+	if entry.size() > c.maxBytes/8 {
+		return
+	}
+
+	c.mu.Lock()
+	if elem, ok := c.items[entry.Key]; ok {
+		c.removeLocked(elem)
+	}
+	c.addLocked(entry)
+	c.mu.Unlock()
+
+	if c.disk != nil {
+		c.disk.store(entry)
+	}
+}
+
+// invalidate 让范围内在此之前保存的条目失效
+func (c *responseCache) invalidate(scopes ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, scope := range scopes {
+		c.invalidated[scope] = now
+	}
+}
+
+// validLocked 检查条目保存之后所在范围是否被写过，调用方需持有锁
+func (c *responseCache) validLocked(entry *cacheEntry) bool {
+	invalidated, ok := c.invalidated[entry.Scope]
+	return !ok || entry.StoredAt.After(invalidated)
+}
+
+// addLocked 把条目放到 LRU 前端并淘汰超出上限的条目，调用方需持有锁
+func (c *responseCache) addLocked(entry *cacheEntry) {
+	c.items[entry.Key] = c.lru.PushFront(entry)
+	c.size += entry.size()
+	for c.size > c.maxBytes {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+// removeLocked 从内存中移除条目，磁盘层保留，调用方需持有锁
+func (c *responseCache) removeLocked(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.items, entry.Key)
+	c.size -= entry.size()
+}
+
+// diskCache 磁盘层，每个条目一个 JSON 文件，文件名为键的哈希
+type diskCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	size     int64
+}
+
+// openDiskCache 打开磁盘缓存目录并统计已有条目的大小
+func openDiskCache(dir string, maxBytes int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	disk := &diskCache{dir: dir, maxBytes: maxBytes}
+	for _, file := range files {
+		if info, err := file.Info(); err == nil && !file.IsDir() {
+			disk.size += info.Size()
+		}
+	}
+	return disk, nil
+}
+
+// path 条目的文件路径
+func (d *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load 读取条目，不存在或无法解析时返回 nil
+func (d *diskCache) load(key string) *cacheEntry {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	// 哈希碰撞时键不一致
+	if json.Unmarshal(data, &entry) != nil || entry.Key != key {
+		return nil
+	}
+	return &entry
+}
+
+// store 写入条目，超过上限时删除最旧的文件
+func (d *diskCache) store(entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := d.path(entry.Key)
+	if info, err := os.Stat(path); err == nil {
+		d.size -= info.Size()
+	}
+	// 先写临时文件再重命名，并发读取不会看到写了一半的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	d.size += int64(len(data))
+
+	if d.size > d.maxBytes {
+		d.evictLocked()
+	}
+}
+
+// evictLocked 按修改时间删除最旧的文件，直到占用降到上限的四分之三，调用方需持有锁
+func (d *diskCache) evictLocked() {
+	files, err := os.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	cached := make([]cachedFile, 0, len(files))
+	var total int64
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || file.IsDir() {
+			continue
+		}
+		cached = append(cached, cachedFile{filepath.Join(d.dir, file.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(cached, func(i, j int) bool { return cached[i].modTime.Before(cached[j].modTime) })
+
+	for _, file := range cached {
+		if total <= d.maxBytes*3/4 {
+			break
+		}
+		if os.Remove(file.path) == nil {
+			total -= file.size
+		}
+	}
+	d.size = total
+}
+
+// cacheTransport 为 API 的 GET 请求发送条件请求，GitHub 返回 304 时使用缓存的响应体，304 不消耗配额
+type cacheTransport struct {
+	base   http.RoundTripper
+	cache  *responseCache
+	client *Client
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.String(), t.client.baseURL) {
+		return t.base.RoundTrip(req)
+	}
+	if req.Method != http.MethodGet {
+		resp, err := t.base.RoundTrip(req)
+		if req.Method != http.MethodHead && req.Method != http.MethodOptions {
+			t.cache.invalidate(t.scopesWrittenBy(req.URL.Path)...)
+		}
+		return resp, err
+	}
+	// 调用方自己带了条件或范围请求头时不介入
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	// 键包含身份，不同 token 的响应互不可见
+	key := t.client.quotaKey + " " + req.Header.Get("Accept") + " " + req.URL.String()
+	entry := t.cache.get(key)
+	if entry != nil {
+		conditional := req.Clone(req.Context())
+		if entry.ETag != "" {
+			conditional.Header.Set("If-None-Match", entry.ETag)
+		} else {
+			conditional.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+		req = conditional
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return cachedResponse(req, resp, entry), nil
+	}
+	if resp.StatusCode == http.StatusOK {
+		return t.store(key, req, resp), nil
+	}
+	return resp, nil
+}
+
+// store 读取可缓存的响应并保存，返回可以继续读取响应体的响应
+func (t *cacheTransport) store(key string, req *http.Request, resp *http.Response) *http.Response {
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" || strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		return resp
+	}
+
+	limit := t.cache.maxBytes / 8
+	if resp.ContentLength > limit {
+		return resp
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil || int64(len(body)) > limit {
+		// 没有缓存，把已读的部分和剩余内容拼回去
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.cache.put(&cacheEntry{
+		Key:          key,
+		Scope:        t.client.baseURL + "|" + cacheScope(req.URL.Path),
+		ETag:         etag,
+		LastModified: lastModified,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		StoredAt:     time.Now(),
+	})
+	return resp
+}
+
+// scopesWrittenBy 写操作影响的失效范围
+func (t *cacheTransport) scopesWrittenBy(path string) []string {
+	scope := cacheScope(path)
+	scopes := []string{scope}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "repos", len(parts) == 4 && parts[0] == "repos" && parts[3] == "topics":
+		// 仓库的创建、修改和删除会改变仓库列表
+		scopes = append(scopes, "/user/repos", "/orgs/"+strings.ToLower(parts[1]), "/installation/repositories")
+	case len(parts) > 0 && parts[0] == "user":
+		// 接受邀请等操作会改变当前用户的仓库列表
+		scopes = append(scopes, "/user/repos")
+	case len(parts) > 1 && parts[0] == "orgs":
+		// 团队授权会改变团队成员的仓库列表
+		scopes = append(scopes, "/user/repos")
+	}
+
+	for i := range scopes {
+		scopes[i] = t.client.baseURL + "|" + scopes[i]
+	}
+	return scopes
+}
+
+// cacheScope 请求路径所属的失效范围，仓库内的接口按仓库划分，其他按前两级路径划分
+func cacheScope(path string) string {
+	parts := strings.Split(strings.Trim(strings.ToLower(path), "/"), "/")
+	if len(parts) >= 3 && parts[0] == "repos" {
+		return "/" + strings.Join(parts[:3], "/")
+	}
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// cachedResponse 用缓存的响应体构造 200 响应，配额等响应头使用 304 响应中的最新值
+func cachedResponse(req *http.Request, notModified *http.Response, entry *cacheEntry) *http.Response {
+	header := entry.Header.Clone()
+	for name, values := range notModified.Header {
+		header[name] = values
+	}
+	header.Set("Content-Length", strconv.Itoa(len(entry.Body)))
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
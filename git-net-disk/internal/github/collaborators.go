@@ -0,0 +1,113 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// collaboratorPermissions 网盘的权限级别到 GitHub 权限名的映射
+var collaboratorPermissions = map[string]string{
+	"read":  "pull",
+	"write": "push",
+	"admin": "admin",
+}
+
+// Collaborator 仓库协作者
+type Collaborator struct {
+	Login     string `json:"login"`
+	ID        int64  `json:"id"`
+	AvatarURL string `json:"avatar_url"`
+	// RoleName read、triage、write、maintain 或 admin
+	RoleName string `json:"role_name"`
+}
+
+// Invitation 仓库邀请
+type Invitation struct {
+	ID          int64      `json:"id"`
+	Repository  Repository `json:"repository"`
+	Invitee     *Owner     `json:"invitee"`
+	Inviter     *Owner     `json:"inviter"`
+	Permissions string     `json:"permissions"` // read、write、admin 等
+	HTMLURL     string     `json:"html_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Expired     bool       `json:"expired"`
+}
+
+// ValidCollaboratorPermission 检查权限级别是否为 read、write 或 admin
+func ValidCollaboratorPermission(permission string) bool {
+	_, ok := collaboratorPermissions[permission]
+	return ok
+}
+
+// ListCollaborators 列出仓库的所有协作者
+func (c *Client) ListCollaborators(ctx context.Context, owner, repo string) ([]Collaborator, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/collaborators?per_page=100", c.baseURL, owner, repo)
+	return getAllPages[Collaborator](ctx, c, url, 0)
+}
+
+// AddCollaborator 邀请用户成为协作者，permission 为 read、write 或 admin
+// 用户已是协作者时只更新权限，返回的邀请为 nil
+func (c *Client) AddCollaborator(ctx context.Context, owner, repo, username, permission string) (*Invitation, error) {
+	githubPermission, ok := collaboratorPermissions[permission]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported permission %q", ErrRepositoryInvalid, permission)
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/%s/collaborators/%s", c.baseURL, owner, repo, username)
+
+	requestBody := struct {
+		Permission string `json:"permission"`
+	}{
+		Permission: githubPermission,
+	}
+
+	var invitation Invitation
+	if err := c.doRepoJSON(ctx, owner, repo, "PUT", url, requestBody, &invitation, http.StatusCreated, http.StatusNoContent); err != nil {
+		return nil, err
+	}
+	if invitation.ID == 0 {
+		return nil, nil
+	}
+	return &invitation, nil
+}
+
+// RemoveCollaborator 移除仓库协作者
+func (c *Client) RemoveCollaborator(ctx context.Context, owner, repo, username string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/collaborators/%s", c.baseURL, owner, repo, username)
+	return c.doRepoJSON(ctx, owner, repo, "DELETE", url, nil, nil, http.StatusNoContent)
+}
+
+// ListRepositoryInvitations 列出仓库尚未接受的邀请
+func (c *Client) ListRepositoryInvitations(ctx context.Context, owner, repo string) ([]Invitation, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/invitations?per_page=100", c.baseURL, owner, repo)
+	return getAllPages[Invitation](ctx, c, url, 0)
+}
+
+// ListUserInvitations 列出当前用户收到的待处理邀请
+func (c *Client) ListUserInvitations(ctx context.Context) ([]Invitation, error) {
+	url := fmt.Sprintf("%s/user/repository_invitations?per_page=100", c.baseURL)
+	return getAllPages[Invitation](ctx, c, url, 0)
+}
+
+// AcceptInvitation 接受邀请
+func (c *Client) AcceptInvitation(ctx context.Context, id int64) error {
+	url := fmt.Sprintf("%s/user/repository_invitations/%d", c.baseURL, id)
+	return c.doJSON(ctx, "PATCH", url, nil, nil, http.StatusNoContent)
+}
+
+// DeclineInvitation 拒绝邀请
+func (c *Client) DeclineInvitation(ctx context.Context, id int64) error {
+	url := fmt.Sprintf("%s/user/repository_invitations/%d", c.baseURL, id)
+	return c.doJSON(ctx, "DELETE", url, nil, nil, http.StatusNoContent)
+}
+
+// ListSharedRepositories 列出他人以协作者身份共享给当前用户的仓库
+func (c *Client) ListSharedRepositories(ctx context.Context) ([]Repository, error) {
+	// App 安装没有"他人共享"的概念
+	if c.installation {
+		return []Repository{}, nil
+	}
+	return c.ListRepositories(ctx, RepoListOptions{Affiliation: "collaborator"})
+}
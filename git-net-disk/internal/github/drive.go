@@ -0,0 +1,67 @@
+package github
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DriveSettingsFile 网盘设置文件，位于默认分支根目录，存在即表示仓库是网盘
+	DriveSettingsFile = ".gitnetdisk.json"
+	// DriveTopic 网盘仓库的主题，没有设置文件时也可用来标记网盘
+	DriveTopic = "gitnetdisk"
+)
+
+// DriveSettings 网盘的设置，保存在 DriveSettingsFile 中
+type DriveSettings struct {
+	// DisplayName 显示名称，为空时使用仓库名
+	DisplayName string `json:"display_name,omitempty"`
+	// Icon 图标名称或 emoji
+	Icon string `json:"icon,omitempty"`
+	// DefaultBranch 网盘使用的分支，为空时使用仓库默认分支
+	DefaultBranch string `json:"default_branch,omitempty"`
+	// Encrypted 文件在客户端加密后再上传
+	Encrypted bool             `json:"encrypted"`
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+}
+
+// RetentionPolicy 历史版本和快照的保留策略，0 表示不限制
+type RetentionPolicy struct {
+	KeepDays      int `json:"keep_days,omitempty"`
+	KeepSnapshots int `json:"keep_snapshots,omitempty"`
+}
+
+// HasTopic 检查仓库是否带有指定主题
+func (r *Repository) HasTopic(topic string) bool {
+	for _, t := range r.Topics {
+		if strings.EqualFold(t, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeDriveSettings 解析 contents 接口返回的 base64 设置文件
+func DecodeDriveSettings(file *FileEntry) (*DriveSettings, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s encoding: %v", DriveSettingsFile, err)
+	}
+
+	var settings DriveSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", DriveSettingsFile, err)
+	}
+	return &settings, nil
+}
+
+// EncodeDriveSettings 将设置编码为可写入 contents 接口的 base64 内容
+func EncodeDriveSettings(settings *DriveSettings) (string, error) {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(append(data, '\n')), nil
+}
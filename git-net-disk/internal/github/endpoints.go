@@ -0,0 +1,67 @@
+package github
+
+import (
+	"os"
+	"strings"
+)
+
+// Endpoints GitHub 各类接口的根地址
+type Endpoints struct {
+	APIURL    string `json:"api_url"`    // REST API
+	UploadURL string `json:"upload_url"` // Release 附件上传
+	RawURL    string `json:"raw_url"`    // 原始文件内容
+	WebURL    string `json:"web_url"`    // 网页和 OAuth 授权
+}
+
+// DefaultEndpoints github.com 的接口地址
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		APIURL:    "https://api.github.com",
+		UploadURL: "https://uploads.github.com",
+		RawURL:    "https://raw.githubusercontent.com",
+		WebURL:    "https://github.com",
+	}
+}
+
+// EnterpriseEndpoints 根据 GitHub Enterprise Server 的根地址推导接口地址
+func EnterpriseEndpoints(serverURL string) Endpoints {
+	base := strings.TrimRight(serverURL, "/")
+	return Endpoints{
+		APIURL:    base + "/api/v3",
+		UploadURL: base + "/api/uploads",
+		RawURL:    base + "/raw",
+		WebURL:    base,
+	}
+}
+
+// EndpointsFromEnv 读取服务器级别的接口配置
+// GITHUB_SERVER_URL 指定 Enterprise 根地址，GITHUB_API_URL/GITHUB_UPLOAD_URL/GITHUB_RAW_URL/GITHUB_WEB_URL 可单独覆盖
+func EndpointsFromEnv() Endpoints {
+	endpoints := DefaultEndpoints()
+	if serverURL := os.Getenv("GITHUB_SERVER_URL"); serverURL != "" {
+		endpoints = EnterpriseEndpoints(serverURL)
+	}
+	return endpoints.Override(Endpoints{
+		APIURL:    os.Getenv("GITHUB_API_URL"),
+		UploadURL: os.Getenv("GITHUB_UPLOAD_URL"),
+		RawURL:    os.Getenv("GITHUB_RAW_URL"),
+		WebURL:    os.Getenv("GITHUB_WEB_URL"),
+	})
+}
+
+// Override 用 other 中非空的地址覆盖当前配置
+func (e Endpoints) Override(other Endpoints) Endpoints {
+	if other.APIURL != "" {
+		e.APIURL = strings.TrimRight(other.APIURL, "/")
+	}
+	if other.UploadURL != "" {
+		e.UploadURL = strings.TrimRight(other.UploadURL, "/")
+	}
+	if other.RawURL != "" {
+		e.RawURL = strings.TrimRight(other.RawURL, "/")
+	}
+	if other.WebURL != "" {
+		e.WebURL = strings.TrimRight(other.WebURL, "/")
+	}
+	return e
+}
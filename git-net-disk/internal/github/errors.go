@@ -0,0 +1,98 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 各存储后端共用的哨兵错误，ErrorMiddleware 分别返回 404、409、403 和 400
+// GitHub 返回 404、409 和 403 的 *APIError 也可以用 errors.Is 与前三者比较
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrConflict  = errors.New("conflict")
+	ErrForbidden = errors.New("forbidden")
+	ErrInvalid   = errors.New("invalid request")
+)
+
+// FieldError GitHub 422 响应中针对单个字段的错误
+type FieldError struct {
+	Resource string `json:"resource,omitempty"`
+	Field    string `json:"field,omitempty"`
+	// Code GitHub 的错误类型，例如 missing、missing_field、invalid、already_exists、custom
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// String 返回字段错误的可读描述
+func (e FieldError) String() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s.%s: %s", e.Resource, e.Field, e.Code)
+}
+
+// APIError GitHub 接口返回的错误响应，保留上游状态码和字段错误，配额耗尽和权限不足分别为 *RateLimitError 和 *PermissionError
+type APIError struct {
+	// StatusCode GitHub 返回的 HTTP 状态码
+	StatusCode int `json:"status"`
+	// Message GitHub 返回的 message，响应体不是 JSON 时为空
+	Message string       `json:"message,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+	// DocumentationURL GitHub 文档中对应接口的地址
+	DocumentationURL string `json:"documentation_url,omitempty"`
+	// RequestID GitHub 的 X-GitHub-Request-Id，向 GitHub 反馈问题时使用
+	RequestID string `json:"github_request_id,omitempty"`
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	msg := "GitHub API error: " + e.Message
+	if len(e.Errors) > 0 {
+		details := make([]string, 0, len(e.Errors))
+		for _, fieldErr := range e.Errors {
+			details = append(details, fieldErr.String())
+		}
+		msg += " - Details: " + strings.Join(details, "; ")
+	}
+	return msg
+}
+
+// Is 让 errors.Is 按状态码把 GitHub 的错误与通用哨兵错误比较
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// parseFieldErrors 解析 errors 字段，部分接口返回字符串数组而不是对象数组
+func parseFieldErrors(raw json.RawMessage) []FieldError {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var fieldErrors []FieldError
+	if json.Unmarshal(raw, &fieldErrors) == nil {
+		return fieldErrors
+	}
+
+	var messages []string
+	if json.Unmarshal(raw, &messages) == nil {
+		for _, message := range messages {
+			fieldErrors = append(fieldErrors, FieldError{Code: "custom", Message: message})
+		}
+	}
+	return fieldErrors
+}
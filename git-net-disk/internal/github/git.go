@@ -0,0 +1,321 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Reference Git 引用信息
+type Reference struct {
+	Ref    string `json:"ref"`
+	Object struct {
+		SHA  string `json:"sha"`
+		Type string `json:"type"`
+	} `json:"object"`
+}
+
+// GitActor 提交者或打标签者信息
+type GitActor struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// GitCommit Git 底层提交对象
+type GitCommit struct {
+	SHA       string   `json:"sha"`
+	Message   string   `json:"message"`
+	Author    GitActor `json:"author"`
+	Committer GitActor `json:"committer"`
+	Tree      struct {
+		SHA string `json:"sha"`
+	} `json:"tree"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+}
+
+// TreeEntry Git 树条目
+type TreeEntry struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Type string `json:"type"` // blob 或 tree
+	// SHA 为 nil 时表示从树中删除该路径
+	SHA  *string `json:"sha"`
+	Size int     `json:"size,omitempty"`
+}
+
+// Tree Git 树对象
+type Tree struct {
+	SHA       string      `json:"sha"`
+	Tree      []TreeEntry `json:"tree"`
+	Truncated bool        `json:"truncated"`
+}
+
+// GetRef 获取分支引用，ref 形如 heads/main
+func (c *Client) GetRef(ctx context.Context, owner, repo, ref string) (*Reference, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/ref/%s", c.baseURL, owner, repo, ref)
+
+	var reference Reference
+	if err := c.doJSON(ctx, "GET", url, nil, &reference, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &reference, nil
+}
+
+// ListMatchingRefs 列出以 prefix 开头的引用，prefix 形如 tags/snapshot/
+func (c *Client) ListMatchingRefs(ctx context.Context, owner, repo, prefix string) ([]Reference, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/matching-refs/%s?per_page=100", c.baseURL, owner, repo, prefix)
+
+	return getAllPages[Reference](ctx, c, url, 0)
+}
+
+// CreateRef 创建引用，ref 形如 heads/name 或 tags/name
+func (c *Client) CreateRef(ctx context.Context, owner, repo, ref, sha string) (*Reference, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/refs", c.baseURL, owner, repo)
+
+	requestBody := struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	}{
+		Ref: "refs/" + ref,
+		SHA: sha,
+	}
+
+	var reference Reference
+	if err := c.doJSON(ctx, "POST", url, requestBody, &reference, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &reference, nil
+}
+
+// UpdateRef 将引用移动到新的提交，force 为 false 时只允许快进
+func (c *Client) UpdateRef(ctx context.Context, owner, repo, ref, sha string, force bool) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/refs/%s", c.baseURL, owner, repo, ref)
+
+	requestBody := struct {
+		SHA   string `json:"sha"`
+		Force bool   `json:"force"`
+	}{
+		SHA:   sha,
+		Force: force,
+	}
+
+	return c.doJSON(ctx, "PATCH", url, requestBody, nil, http.StatusOK)
+}
+
+// GetGitCommit 获取提交对象
+func (c *Client) GetGitCommit(ctx context.Context, owner, repo, sha string) (*GitCommit, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/commits/%s", c.baseURL, owner, repo, sha)
+
+	var commit GitCommit
+	if err := c.doJSON(ctx, "GET", url, nil, &commit, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &commit, nil
+}
+
+// CreateGitCommit 基于树对象创建提交
+func (c *Client) CreateGitCommit(ctx context.Context, owner, repo, message, treeSHA string, parents []string) (*GitCommit, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/commits", c.baseURL, owner, repo)
+
+	requestBody := struct {
+		Message string   `json:"message"`
+		Tree    string   `json:"tree"`
+		Parents []string `json:"parents"`
+	}{
+		Message: message,
+		Tree:    treeSHA,
+		Parents: parents,
+	}
+
+	var commit GitCommit
+	if err := c.doJSON(ctx, "POST", url, requestBody, &commit, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &commit, nil
+}
+
+// GitTag 附注标签对象
+type GitTag struct {
+	SHA     string   `json:"sha"`
+	Tag     string   `json:"tag"`
+	Message string   `json:"message"`
+	Tagger  GitActor `json:"tagger"`
+	Object  struct {
+		SHA  string `json:"sha"`
+		Type string `json:"type"`
+	} `json:"object"`
+}
+
+// GetTag 获取附注标签对象
+func (c *Client) GetTag(ctx context.Context, owner, repo, sha string) (*GitTag, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/tags/%s", c.baseURL, owner, repo, sha)
+
+	var tag GitTag
+	if err := c.doJSON(ctx, "GET", url, nil, &tag, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// CreateTag 创建指向提交的附注标签对象，仍需 CreateRef 才能生效
+func (c *Client) CreateTag(ctx context.Context, owner, repo, tag, message, commitSHA string) (*GitTag, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/tags", c.baseURL, owner, repo)
+
+	requestBody := struct {
+		Tag     string `json:"tag"`
+		Message string `json:"message"`
+		Object  string `json:"object"`
+		Type    string `json:"type"`
+	}{
+		Tag:     tag,
+		Message: message,
+		Object:  commitSHA,
+		Type:    "commit",
+	}
+
+	var result GitTag
+	if err := c.doJSON(ctx, "POST", url, requestBody, &result, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetTree 获取树对象，recursive 为 true 时展开所有子目录
+func (c *Client) GetTree(ctx context.Context, owner, repo, sha string, recursive bool) (*Tree, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/trees/%s", c.baseURL, owner, repo, sha)
+	if recursive {
+		url += "?recursive=1"
+	}
+
+	var tree Tree
+	if err := c.doJSON(ctx, "GET", url, nil, &tree, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+// CreateTree 在 baseTree 之上创建新的树对象
+func (c *Client) CreateTree(ctx context.Context, owner, repo, baseTree string, entries []TreeEntry) (*Tree, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/trees", c.baseURL, owner, repo)
+
+	requestBody := struct {
+		BaseTree string      `json:"base_tree,omitempty"`
+		Tree     []TreeEntry `json:"tree"`
+	}{
+		BaseTree: baseTree,
+		Tree:     entries,
+	}
+
+	var tree Tree
+	if err := c.doJSON(ctx, "POST", url, requestBody, &tree, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+// GetBlob 获取 blob 的原始内容
+func (c *Client) GetBlob(ctx context.Context, owner, repo, sha string) ([]byte, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/blobs/%s", c.baseURL, owner, repo, sha)
+
+	var blob struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	if err := c.doJSON(ctx, "GET", url, nil, &blob, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	if blob.Encoding != "base64" {
+		return []byte(blob.Content), nil
+	}
+	// GitHub 返回的 base64 内容带有换行
+	return base64.StdEncoding.DecodeString(strings.ReplaceAll(blob.Content, "\n", ""))
+}
+
+// CreateBlob 上传 blob 并返回其 SHA
+func (c *Client) CreateBlob(ctx context.Context, owner, repo string, content []byte) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/git/blobs", c.baseURL, owner, repo)
+
+	requestBody := struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}{
+		Content:  base64.StdEncoding.EncodeToString(content),
+		Encoding: "base64",
+	}
+
+	var result struct {
+		SHA string `json:"sha"`
+	}
+	if err := c.doJSON(ctx, "POST", url, requestBody, &result, http.StatusCreated); err != nil {
+		return "", err
+	}
+	return result.SHA, nil
+}
+
+// newJSONRequest 创建带认证头的请求，in 非 nil 时编码为 JSON 请求体
+func (c *Client) newJSONRequest(ctx context.Context, method, url string, in interface{}) (*http.Request, error) {
+	var body *bytes.Buffer
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, method, url, body)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.setRequestHeaders(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// doJSON 发送 JSON 请求并解析响应，expected 为可接受的状态码
+func (c *Client) doJSON(ctx context.Context, method, url string, in, out interface{}, expected ...int) error {
+	req, err := c.newJSONRequest(ctx, method, url, in)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	ok := false
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return c.handleError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
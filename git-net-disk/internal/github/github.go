@@ -2,10 +2,13 @@ package github
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,9 +17,17 @@ import (
 
 // Client GitHub API 客户端
 type Client struct {
-	Client  *http.Client
-	token   string
-	baseURL string
+	Client    *http.Client
+	token     string
+	baseURL   string
+	uploadURL string // Release 附件上传地址
+	rawURL    string // 原始文件内容地址
+	// installation 为 true 时以 GitHub App 安装身份访问，token 由传输层附加
+	installation bool
+	// quotaKey 配额记录的键，同一实例上的同一身份共享配额
+	quotaKey string
+	// observer 收到带配额信息的响应时的回调
+	observer func(status RateLimitStatus)
 }
 
 // Repository GitHub 仓库信息
@@ -31,6 +42,24 @@ type Repository struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	Size        int       `json:"size"`
+	// DefaultBranch 默认分支
+	DefaultBranch string `json:"default_branch"`
+	// Archived 已归档的仓库只读
+	Archived bool     `json:"archived"`
+	Topics   []string `json:"topics,omitempty"`
+	// SharedWithMe 仓库由他人以协作者身份共享给当前用户，由服务端标记
+	SharedWithMe bool `json:"shared_with_me"`
+	// Permissions 当前用户对仓库的权限，包含团队授予的权限
+	Permissions *RepoPermissions `json:"permissions,omitempty"`
+	// Drive 网盘设置，仅在请求网盘信息时由服务端填充，nil 表示不是网盘
+	Drive *DriveSettings `json:"drive,omitempty"`
+}
+
+// RepoPermissions 当前用户对仓库的权限
+type RepoPermissions struct {
+	Admin bool `json:"admin"`
+	Push  bool `json:"push"`
+	Pull  bool `json:"pull"`
 }
 
 // Owner 仓库所有者信息
@@ -38,6 +67,7 @@ type Owner struct {
 	Login     string `json:"login"`
 	ID        int64  `json:"id"`
 	AvatarURL string `json:"avatar_url"`
+	Type      string `json:"type,omitempty"` // User 或 Organization
 }
 
 // FileEntry 文件条目信息
@@ -54,6 +84,8 @@ type FileEntry struct {
 	Content     string    `json:"content,omitempty"`
 	Encoding    string    `json:"encoding,omitempty"`
 	LastCommit  Commit    `json:"last_commit,omitempty"`
+	// LargeObject 文件内容存放在 Release 附件中时的指针
+	LargeObject *LargeObjectPointer `json:"large_object,omitempty"`
 }
 
 // Commit 提交信息
@@ -84,52 +116,151 @@ type Committer struct {
 	Email string `json:"email"`
 }
 
-// NewClient 创建新的 GitHub API 客户端
+// NewClient 创建新的 GitHub API 客户端，使用 github.com 的接口地址
 func NewClient(token string, proxyConfig proxy.ProxyConfig) (*Client, error) {
-	client, err := proxy.NewHTTPClient(proxyConfig)
+	return NewClientWithEndpoints(token, proxyConfig, DefaultEndpoints())
+}
+
+// NewClientWithEndpoints 创建使用指定接口地址的客户端，用于 GitHub Enterprise Server
+func NewClientWithEndpoints(token string, proxyConfig proxy.ProxyConfig, endpoints Endpoints) (*Client, error) {
+	return newClient(token, proxy.TokenFingerprint(token), proxyConfig, endpoints)
+}
+
+// newClient 创建客户端，底层连接从客户端池中按 fingerprint 和代理配置复用
+func newClient(token, fingerprint string, proxyConfig proxy.ProxyConfig, endpoints Endpoints) (*Client, error) {
+	client, err := proxy.DefaultPool().Client(fingerprint, proxyConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{
-		Client:  client,
-		token:   token,
-		baseURL: "https://api.github.com",
-	}, nil
+	endpoints = DefaultEndpoints().Override(endpoints)
+	c := &Client{
+		Client:    client,
+		token:     token,
+		baseURL:   endpoints.APIURL,
+		uploadURL: endpoints.UploadURL,
+		rawURL:    endpoints.RawURL,
+	}
+
+	// 匿名请求按服务器 IP 共享配额
+	identity := "anonymous"
+	if token != "" {
+		identity = "token:" + token
+	}
+	c.quotaKey = quotaKey(endpoints.APIURL, identity)
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &retryTransport{base: base, client: c}
+	if cache := responseCacheInstance(); cache != nil {
+		client.Transport = &cacheTransport{base: client.Transport, cache: cache, client: c}
+	}
+	return c, nil
 }
 
-// ListRepositories 列出用户的仓库
-func (c *Client) ListRepositories() ([]Repository, error) {
-	url := fmt.Sprintf("%s/user/repos", c.baseURL)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+// User GitHub 用户信息
+type User struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatar_url"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+}
+
+// GetUser 获取当前认证用户的信息
+func (c *Client) GetUser(ctx context.Context) (*User, error) {
+	url := fmt.Sprintf("%s/user", c.baseURL)
+
+	var user User
+	if err := c.doJSON(ctx, "GET", url, nil, &user, http.StatusOK); err != nil {
 		return nil, err
 	}
+	return &user, nil
+}
+
+// OpenRaw 从 raw 内容地址读取文件，调用方负责关闭返回的 Body
+func (c *Client) OpenRaw(ctx context.Context, owner, repo, ref, path string) (*http.Response, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	url := fmt.Sprintf("%s/%s/%s/%s/%s", c.rawURL, neturl.PathEscape(owner), neturl.PathEscape(repo), escapePath(ref), escapePath(path))
 
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 	c.setRequestHeaders(req)
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		return nil, c.handleError(resp)
 	}
+	return resp, nil
+}
+
+// ListRepositories 列出用户可访问的所有仓库，自动跟随分页
+func (c *Client) ListRepositories(ctx context.Context, opts RepoListOptions) ([]Repository, error) {
+	// 安装 token 不代表用户，只能列出安装授权的仓库，可见性在本地过滤
+	if c.installation {
+		repositories, err := c.listInstallationRepositories(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Visibility == "" || opts.Visibility == "all" {
+			return repositories, nil
+		}
+		filtered := repositories[:0]
+		for _, repository := range repositories {
+			if repository.Private == (opts.Visibility == "private") {
+				filtered = append(filtered, repository)
+			}
+		}
+		return filtered, nil
+	}
+
+	query := neturl.Values{}
+	query.Set("per_page", "100")
+	if opts.Visibility != "" {
+		query.Set("visibility", opts.Visibility)
+	}
+	if opts.Affiliation != "" {
+		query.Set("affiliation", opts.Affiliation)
+	}
+	url := fmt.Sprintf("%s/user/repos?%s", c.baseURL, query.Encode())
 
-	var repositories []Repository
-	if err := json.NewDecoder(resp.Body).Decode(&repositories); err != nil {
+	return getAllPages[Repository](ctx, c, url, 0)
+}
+
+// GetRepository 获取单个仓库信息
+func (c *Client) GetRepository(ctx context.Context, owner, repo string) (*Repository, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s", c.baseURL, owner, repo)
+
+	var repository Repository
+	if err := c.doJSON(ctx, "GET", url, nil, &repository, http.StatusOK); err != nil {
 		return nil, err
 	}
+	return &repository, nil
+}
 
-	return repositories, nil
+// DefaultBranch 返回仓库的默认分支名
+func (c *Client) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	repository, err := c.GetRepository(ctx, owner, repo)
+	if err != nil {
+		return "", err
+	}
+	return repository.DefaultBranch, nil
 }
 
-// ListFiles 列出仓库中的文件
-func (c *Client) ListFiles(owner, repo, path string) ([]FileEntry, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path)
-	req, err := http.NewRequest("GET", url, nil)
+// ListFiles 列出仓库中的文件，ref 为空时使用默认分支
+func (c *Client) ListFiles(ctx context.Context, owner, repo, path, ref string) ([]FileEntry, error) {
+	url := withRef(fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path), ref)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -151,13 +282,18 @@ func (c *Client) ListFiles(owner, repo, path string) ([]FileEntry, error) {
 		return nil, err
 	}
 
+	// contents 接口最多返回 1000 个条目，超过时改用树接口读取完整目录
+	if len(files) >= contentsListLimit {
+		return c.listTreeEntries(ctx, owner, repo, path, ref)
+	}
+
 	return files, nil
 }
 
-// GetFileContent 获取文件内容
-func (c *Client) GetFileContent(owner, repo, path string) (*FileEntry, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path)
-	req, err := http.NewRequest("GET", url, nil)
+// GetFileContent 获取文件内容，ref 为空时使用默认分支
+func (c *Client) GetFileContent(ctx context.Context, owner, repo, path, ref string) (*FileEntry, error) {
+	url := withRef(fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path), ref)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -182,18 +318,48 @@ func (c *Client) GetFileContent(owner, repo, path string) (*FileEntry, error) {
 	return &file, nil
 }
 
-// CreateOrUpdateFile 创建或更新文件
-func (c *Client) CreateOrUpdateFile(owner, repo, path, content, message, branch string) (*FileEntry, error) {
+// GetFileSHA 获取文件当前的 blob SHA，文件不存在时返回空字符串
+func (c *Client) GetFileSHA(ctx context.Context, owner, repo, path, branch string) (string, error) {
+	url := withRef(fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path), branch)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	c.setRequestHeaders(req)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", c.handleError(resp)
+	}
+
+	var file FileEntry
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return "", err
+	}
+
+	return file.SHA, nil
+}
+
+// CreateOrUpdateFile 创建或更新文件，sha 为空时只能创建新文件
+func (c *Client) CreateOrUpdateFile(ctx context.Context, owner, repo, path, content, message, branch, sha string) (*FileEntry, error) {
 	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path)
 	
 	// 前端已经发送了 base64 编码的内容，直接使用
 	// 不需要再次编码
-	fmt.Printf("[DEBUG] CreateOrUpdateFile - owner: %s, repo: %s, path: %s\n", owner, repo, path)
-	fmt.Printf("[DEBUG] Content length: %d bytes\n", len(content))
 
 	requestBody := CreateFileRequest{
 		Message: message,
 		Content: content, // 直接使用前端传来的 base64 内容
+		SHA:     sha,
 		Branch:  branch,
 	}
 
@@ -202,7 +368,7 @@ func (c *Client) CreateOrUpdateFile(owner, repo, path, content, message, branch
 		return nil, err
 	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
@@ -231,7 +397,7 @@ func (c *Client) CreateOrUpdateFile(owner, repo, path, content, message, branch
 }
 
 // DeleteFile 删除文件
-func (c *Client) DeleteFile(owner, repo, path, sha, message, branch string) error {
+func (c *Client) DeleteFile(ctx context.Context, owner, repo, path, sha, message, branch string) error {
 	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", c.baseURL, owner, repo, path)
 
 	requestBody := struct {
@@ -249,7 +415,7 @@ func (c *Client) DeleteFile(owner, repo, path, sha, message, branch string) erro
 		return err
 	}
 
-	req, err := http.NewRequest("DELETE", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
@@ -269,8 +435,47 @@ func (c *Client) DeleteFile(owner, repo, path, sha, message, branch string) erro
 	return nil
 }
 
+// ListCommits 列出影响指定路径的提交历史，path 为空时列出整个仓库
+// 最多读取 maxCommitPages 页
+func (c *Client) ListCommits(ctx context.Context, owner, repo, path, ref string) ([]Commit, error) {
+	query := neturl.Values{}
+	if path != "" {
+		query.Set("path", path)
+	}
+	if ref != "" {
+		query.Set("sha", ref)
+	}
+	query.Set("per_page", "100")
+	url := fmt.Sprintf("%s/repos/%s/%s/commits?%s", c.baseURL, owner, repo, query.Encode())
+
+	return getAllPages[Commit](ctx, c, url, maxCommitPages)
+}
+
+// ListCommitsPage 读取从第 offset 个提交开始的至多 limit 个提交，只请求覆盖这一段的上游分页
+func (c *Client) ListCommitsPage(ctx context.Context, owner, repo, path, ref string, offset, limit int) ([]Commit, bool, error) {
+	const perPage = 100
+	query := neturl.Values{}
+	if path != "" {
+		query.Set("path", path)
+	}
+	if ref != "" {
+		query.Set("sha", ref)
+	}
+	query.Set("per_page", strconv.Itoa(perPage))
+
+	return CollectPage(offset, limit, perPage, func(page int) ([]Commit, error) {
+		query.Set("page", strconv.Itoa(page))
+		url := fmt.Sprintf("%s/repos/%s/%s/commits?%s", c.baseURL, owner, repo, query.Encode())
+		var commits []Commit
+		if err := c.doJSON(ctx, "GET", url, nil, &commits, http.StatusOK); err != nil {
+			return nil, err
+		}
+		return commits, nil
+	})
+}
+
 // CreateRepository 创建新仓库
-func (c *Client) CreateRepository(name, description string, isPrivate, autoInit bool) (*Repository, error) {
+func (c *Client) CreateRepository(ctx context.Context, name, description string, isPrivate, autoInit bool) (*Repository, error) {
 	url := fmt.Sprintf("%s/user/repos", c.baseURL)
 
 	requestBody := struct {
@@ -290,7 +495,7 @@ func (c *Client) CreateRepository(name, description string, isPrivate, autoInit
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
@@ -320,65 +525,68 @@ func (c *Client) setRequestHeaders(req *http.Request) {
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 	req.Header.Set("User-Agent", "GitNetDisk")
 	if c.token != "" {
-		authHeader := fmt.Sprintf("token %s", c.token)
-		req.Header.Set("Authorization", authHeader)
-		// 调试日志：查看发送给GitHub的Authorization头
-		fmt.Printf("[DEBUG] Sending Authorization header to GitHub: %s\n", authHeader)
+		req.Header.Set("Authorization", "token "+c.token)
 	}
 }
 
-// handleError 处理错误响应
+// handleError 将错误响应转换为 *RateLimitError、*PermissionError 或 *APIError
 func (c *Client) handleError(resp *http.Response) error {
+	if rateLimit := c.rateLimitFromResponse(resp); rateLimit != nil {
+		return rateLimit
+	}
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-GitHub-Request-Id"),
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("HTTP error: %s", resp.Status)
+		return apiErr
 	}
 
 	var errorResponse struct {
-		Message string `json:"message"`
-		Errors  []struct {
-			Resource string `json:"resource"`
-			Field    string `json:"field"`
-			Code     string `json:"code"`
-			Message  string `json:"message"`
-		} `json:"errors"`
-		DocumentationURL string `json:"documentation_url"`
+		Message          string          `json:"message"`
+		Errors           json.RawMessage `json:"errors"`
+		DocumentationURL string          `json:"documentation_url"`
 	}
-
 	if err := json.Unmarshal(body, &errorResponse); err != nil {
-		return fmt.Errorf("HTTP error: %s - %s", resp.Status, string(body))
-	}
-
-	// 构建详细的错误信息
-	if errorResponse.Message != "" {
-		errMsg := fmt.Sprintf("GitHub API error: %s", errorResponse.Message)
-		
-		// 添加详细错误信息
-		if len(errorResponse.Errors) > 0 {
-			errMsg += " - Details: "
-			for i, e := range errorResponse.Errors {
-				if i > 0 {
-					errMsg += "; "
-				}
-				if e.Message != "" {
-					errMsg += e.Message
-				} else {
-					errMsg += fmt.Sprintf("%s.%s: %s", e.Resource, e.Field, e.Code)
-				}
-			}
-		}
-		
-		// 打印完整错误信息用于调试
-		fmt.Printf("[ERROR] GitHub API Error: %s\n", errMsg)
-		if errorResponse.DocumentationURL != "" {
-			fmt.Printf("[ERROR] Documentation: %s\n", errorResponse.DocumentationURL)
-		}
-		fmt.Printf("[ERROR] Response Body: %s\n", string(body))
-		
-		return fmt.Errorf("%s", errMsg)
+		// 代理或网关返回的 HTML 错误页没有可用信息，只保留状态码
+		return apiErr
+	}
+	apiErr.Message = errorResponse.Message
+	apiErr.DocumentationURL = errorResponse.DocumentationURL
+	apiErr.Errors = parseFieldErrors(errorResponse.Errors)
+
+	// 没有 Retry-After 的次级限流只能从 message 识别，GitHub 建议至少等待一分钟
+	if (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) &&
+		strings.Contains(strings.ToLower(apiErr.Message), "secondary rate limit") {
+		return &RateLimitError{Reset: time.Now().Add(time.Minute), Secondary: true}
+	}
+
+	// token 缺少作用域或权限时给出可操作的提示
+	if permErr := permissionErrorFromResponse(resp, apiErr.Message); permErr != nil {
+		return permErr
 	}
 
-	return fmt.Errorf("HTTP error: %s", resp.Status)
+	return apiErr
+}
+
+// withRef 为 contents 接口追加 ref 查询参数
+func withRef(rawURL, ref string) string {
+	if ref == "" {
+		return rawURL
+	}
+	return rawURL + "?ref=" + neturl.QueryEscape(ref)
+}
+
+// escapePath 逐段转义路径，保留分隔的斜杠，文件名中的 #、?、% 和空格不会改变 URL 的含义
+func escapePath(p string) string {
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = neturl.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
 }
 
 // ParseRepoPath 解析仓库路径
@@ -0,0 +1,78 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git-net-disk/internal/proxy"
+)
+
+func TestOpenRawEscapesPath(t *testing.T) {
+	var gotPath, gotRawQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotRawQuery = r.URL.Path, r.URL.RawQuery
+		io.WriteString(w, "content")
+	}))
+	defer server.Close()
+
+	client, err := NewClientWithEndpoints("test-token", proxy.ProxyConfig{}, Endpoints{RawURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.OpenRaw(context.Background(), "octo", "drive", "feature/x", "docs/50% off #1?.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if want := "/octo/drive/feature/x/docs/50% off #1?.txt"; gotPath != want || gotRawQuery != "" {
+		t.Fatalf("requested path %q query %q, want %q", gotPath, gotRawQuery, want)
+	}
+}
+
+func TestCollectPage(t *testing.T) {
+	// 上游共 25 个条目，每页 10 个
+	const total, perPage = 25, 10
+	fetchFrom := func(fetched *[]int) func(page int) ([]int, error) {
+		return func(page int) ([]int, error) {
+			*fetched = append(*fetched, page)
+			var items []int
+			for i := (page - 1) * perPage; i < page*perPage && i < total; i++ {
+				items = append(items, i)
+			}
+			return items, nil
+		}
+	}
+
+	tests := []struct {
+		offset, limit int
+		first, n      int
+		more          bool
+		pages         []int
+	}{
+		{0, 5, 0, 5, true, []int{1}},
+		{0, 10, 0, 10, true, []int{1, 2}},
+		{8, 5, 8, 5, true, []int{1, 2}},
+		{20, 10, 20, 5, false, []int{3}},
+		{15, 10, 15, 10, false, []int{2, 3}},
+		{30, 10, 0, 0, false, []int{4}},
+	}
+	for _, tt := range tests {
+		var fetched []int
+		items, more, err := CollectPage(tt.offset, tt.limit, perPage, fetchFrom(&fetched))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != tt.n || (tt.n > 0 && items[0] != tt.first) || more != tt.more {
+			t.Errorf("CollectPage(%d, %d) = %v, %v", tt.offset, tt.limit, items, more)
+		}
+		if fmt.Sprint(fetched) != fmt.Sprint(tt.pages) {
+			t.Errorf("CollectPage(%d, %d) fetched pages %v, want %v", tt.offset, tt.limit, fetched, tt.pages)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// storageReleaseTag 存放大文件的隐藏草稿 Release 的标签名
+const storageReleaseTag = "gitnetdisk-storage"
+
+// MaxLargeObjectSize GitHub 单个 Release 附件的大小上限，附件必须小于 2 GiB
+const MaxLargeObjectSize int64 = 2<<30 - 1
+
+// ErrLargeObjectTooLarge 文件超过 Release 附件的大小上限
+var ErrLargeObjectTooLarge = errors.New("file exceeds the 2 GiB release asset limit")
+
+// storageReleaseLocks 按仓库串行查找和创建存储 Release，避免并发上传各自创建一个草稿
+var storageReleaseLocks sync.Map
+
+// pointerVersion 指针文件格式版本
+const pointerVersion = 1
+
+// LargeObjectPointer 存储在仓库树中的大文件指针
+type LargeObjectPointer struct {
+	Version     int    `json:"gitnetdisk_large_object"`
+	AssetID     int64  `json:"asset_id"`
+	AssetName   string `json:"asset_name"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// maxPointerSize 指针文件的最大尺寸，超过即视为普通文件
+const maxPointerSize = 1024
+
+// ParseLargeObjectPointer 判断内容是否为大文件指针
+func ParseLargeObjectPointer(data []byte) (*LargeObjectPointer, bool) {
+	if len(data) > maxPointerSize || !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil, false
+	}
+
+	var pointer LargeObjectPointer
+	if err := json.Unmarshal(data, &pointer); err != nil {
+		return nil, false
+	}
+	if pointer.Version != pointerVersion || pointer.AssetID == 0 {
+		return nil, false
+	}
+	return &pointer, true
+}
+
+// Encode 序列化指针文件内容
+func (p *LargeObjectPointer) Encode() []byte {
+	data, _ := json.MarshalIndent(p, "", "  ")
+	return append(data, '\n')
+}
+
+// UploadLargeObject 将文件上传到存储 Release，返回写入树中的指针
+// 附件以内容的 SHA256 命名，相同内容只会上传一次
+func (c *Client) UploadLargeObject(ctx context.Context, owner, repo string, file *os.File, size int64, sha256Hex, contentType string) (*LargeObjectPointer, error) {
+	if size > MaxLargeObjectSize {
+		return nil, ErrLargeObjectTooLarge
+	}
+
+	release, err := c.ensureStorageRelease(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	// Release 列表中的附件可能不完整，单独分页列出
+	assets, err := c.ListReleaseAssets(ctx, owner, repo, release.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pointer := &LargeObjectPointer{
+		Version:     pointerVersion,
+		AssetName:   sha256Hex,
+		Size:        size,
+		SHA256:      sha256Hex,
+		ContentType: contentType,
+	}
+
+	for _, asset := range assets {
+		if asset.Name == sha256Hex && asset.Size == size {
+			pointer.AssetID = asset.ID
+			return pointer, nil
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	asset, err := c.UploadReleaseAsset(ctx, owner, repo, release.ID, sha256Hex, contentType, file, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload large object: %w", err)
+	}
+
+	pointer.AssetID = asset.ID
+	return pointer, nil
+}
+
+// OpenLargeObject 打开指针对应的附件内容流
+func (c *Client) OpenLargeObject(ctx context.Context, owner, repo string, pointer *LargeObjectPointer) (*http.Response, error) {
+	return c.DownloadReleaseAsset(ctx, owner, repo, pointer.AssetID)
+}
+
+// ensureStorageRelease 查找或创建存储 Release
+// 同一进程内按仓库串行执行；其他服务器同时创建了草稿时，所有实例都使用 ID 最小的那个并删除自己多建的
+func (c *Client) ensureStorageRelease(ctx context.Context, owner, repo string) (*Release, error) {
+	key := c.baseURL + "/" + strings.ToLower(owner+"/"+repo)
+	lock, _ := storageReleaseLocks.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if release, err := c.findStorageRelease(ctx, owner, repo); err != nil || release != nil {
+		return release, err
+	}
+
+	fmt.Printf("[INFO] Creating storage release for %s/%s\n", owner, repo)
+	created, err := c.CreateRelease(ctx, owner, repo, storageReleaseTag, "GitNetDisk storage",
+		"Large files stored by GitNetDisk. Do not publish or delete this release.", true)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := c.findStorageRelease(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	if release == nil || release.ID == created.ID {
+		return created, nil
+	}
+	if err := c.DeleteRelease(ctx, owner, repo, created.ID); err != nil {
+		fmt.Printf("[WARN] Failed to delete duplicate storage release %d of %s/%s: %v\n", created.ID, owner, repo, err)
+	}
+	return release, nil
+}
+
+// findStorageRelease 返回 ID 最小的存储 Release，不存在时返回 nil
+func (c *Client) findStorageRelease(ctx context.Context, owner, repo string) (*Release, error) {
+	releases, err := c.ListReleases(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	var found *Release
+	for i := range releases {
+		if releases[i].TagName == storageReleaseTag && (found == nil || releases[i].ID < found.ID) {
+			found = &releases[i]
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,157 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"git-net-disk/internal/proxy"
+)
+
+// OAuthApp GitHub OAuth App，支持网页授权流程和设备授权流程
+type OAuthApp struct {
+	Client       *http.Client
+	clientID     string
+	clientSecret string
+	webURL       string // 形如 https://github.com
+}
+
+// DeviceCode 设备授权流程的第一步返回的信息
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// OAuthToken 授权成功后得到的 token
+type OAuthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Scope       string `json:"scope"`
+}
+
+// OAuthError 授权接口返回的错误，例如 authorization_pending、slow_down、expired_token
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	Interval    int    `json:"interval"`
+}
+
+// Error 实现 error 接口
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("GitHub OAuth error: %s (%s)", e.Description, e.Code)
+	}
+	return fmt.Sprintf("GitHub OAuth error: %s", e.Code)
+}
+
+// Pending 设备授权尚未完成，客户端应继续轮询
+func (e *OAuthError) Pending() bool {
+	return e.Code == "authorization_pending" || e.Code == "slow_down"
+}
+
+// NewOAuthApp 创建 OAuth App 客户端，clientSecret 只在网页授权流程中使用
+func NewOAuthApp(clientID, clientSecret string, endpoints Endpoints) (*OAuthApp, error) {
+	client, err := proxy.NewHTTPClient(proxy.ProxyConfig{Enabled: false})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthApp{
+		Client:       client,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		webURL:       endpoints.WebURL,
+	}, nil
+}
+
+// AuthorizeURL 网页授权流程中跳转到 GitHub 授权页面的地址
+func (a *OAuthApp) AuthorizeURL(state, redirectURL string, scopes []string) string {
+	query := neturl.Values{}
+	query.Set("client_id", a.clientID)
+	query.Set("state", state)
+	query.Set("scope", strings.Join(scopes, " "))
+	if redirectURL != "" {
+		query.Set("redirect_uri", redirectURL)
+	}
+	return fmt.Sprintf("%s/login/oauth/authorize?%s", a.webURL, query.Encode())
+}
+
+// ExchangeCode 用回调中的 code 换取 token
+func (a *OAuthApp) ExchangeCode(ctx context.Context, code, redirectURL string) (*OAuthToken, error) {
+	form := neturl.Values{}
+	form.Set("client_id", a.clientID)
+	form.Set("client_secret", a.clientSecret)
+	form.Set("code", code)
+	if redirectURL != "" {
+		form.Set("redirect_uri", redirectURL)
+	}
+	return a.requestToken(ctx, form)
+}
+
+// RequestDeviceCode 开始设备授权流程，用户需要在 VerificationURI 输入 UserCode
+func (a *OAuthApp) RequestDeviceCode(ctx context.Context, scopes []string) (*DeviceCode, error) {
+	form := neturl.Values{}
+	form.Set("client_id", a.clientID)
+	form.Set("scope", strings.Join(scopes, " "))
+
+	var code DeviceCode
+	if err := a.postForm(ctx, a.webURL+"/login/device/code", form, &code); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// PollDeviceToken 查询设备授权结果，用户尚未完成授权时返回 Pending 的 *OAuthError
+func (a *OAuthApp) PollDeviceToken(ctx context.Context, deviceCode string) (*OAuthToken, error) {
+	form := neturl.Values{}
+	form.Set("client_id", a.clientID)
+	form.Set("device_code", deviceCode)
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	return a.requestToken(ctx, form)
+}
+
+// requestToken 调用 access_token 接口，错误以 200 状态码和 error 字段返回
+func (a *OAuthApp) requestToken(ctx context.Context, form neturl.Values) (*OAuthToken, error) {
+	var result struct {
+		OAuthToken
+		OAuthError
+	}
+	if err := a.postForm(ctx, a.webURL+"/login/oauth/access_token", form, &result); err != nil {
+		return nil, err
+	}
+	if result.OAuthError.Code != "" {
+		return nil, &result.OAuthError
+	}
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("GitHub OAuth error: empty access token")
+	}
+	return &result.OAuthToken, nil
+}
+
+// postForm 以表单提交并解析 JSON 响应
+func (a *OAuthApp) postForm(ctx context.Context, url string, form neturl.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "GitNetDisk")
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub OAuth error: HTTP %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
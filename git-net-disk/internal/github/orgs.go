@@ -0,0 +1,91 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Organization GitHub 组织
+type Organization struct {
+	Login       string `json:"login"`
+	ID          int64  `json:"id"`
+	AvatarURL   string `json:"avatar_url"`
+	Description string `json:"description"`
+}
+
+// Team 组织内的团队
+type Team struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Privacy     string `json:"privacy"`
+	// Permission 团队对仓库的默认权限
+	Permission string `json:"permission"`
+}
+
+// ListOrganizations 列出当前用户所属的组织
+func (c *Client) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	url := fmt.Sprintf("%s/user/orgs?per_page=100", c.baseURL)
+	return getAllPages[Organization](ctx, c, url, 0)
+}
+
+// ListOrgRepositories 列出当前用户在组织中可见的所有仓库
+func (c *Client) ListOrgRepositories(ctx context.Context, org string) ([]Repository, error) {
+	url := fmt.Sprintf("%s/orgs/%s/repos?type=all&per_page=100", c.baseURL, org)
+	return getAllPages[Repository](ctx, c, url, 0)
+}
+
+// CreateOrgRepository 在组织下创建仓库
+func (c *Client) CreateOrgRepository(ctx context.Context, org, name, description string, isPrivate, autoInit bool) (*Repository, error) {
+	url := fmt.Sprintf("%s/orgs/%s/repos", c.baseURL, org)
+
+	requestBody := struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Private     bool   `json:"private"`
+		AutoInit    bool   `json:"auto_init"`
+	}{
+		Name:        name,
+		Description: description,
+		Private:     isPrivate,
+		AutoInit:    autoInit,
+	}
+
+	var repo Repository
+	if err := c.doJSON(ctx, "POST", url, requestBody, &repo, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// ListTeams 列出组织中当前用户可见的团队
+func (c *Client) ListTeams(ctx context.Context, org string) ([]Team, error) {
+	url := fmt.Sprintf("%s/orgs/%s/teams?per_page=100", c.baseURL, org)
+	return getAllPages[Team](ctx, c, url, 0)
+}
+
+// SetTeamRepoPermission 设置团队对组织仓库的权限，permission 为 read、write 或 admin
+func (c *Client) SetTeamRepoPermission(ctx context.Context, org, teamSlug, repo, permission string) error {
+	githubPermission, ok := collaboratorPermissions[permission]
+	if !ok {
+		return fmt.Errorf("%w: unsupported permission %q", ErrRepositoryInvalid, permission)
+	}
+
+	url := fmt.Sprintf("%s/orgs/%s/teams/%s/repos/%s/%s", c.baseURL, org, teamSlug, org, repo)
+
+	requestBody := struct {
+		Permission string `json:"permission"`
+	}{
+		Permission: githubPermission,
+	}
+
+	return c.doRepoJSON(ctx, org, repo, "PUT", url, requestBody, nil, http.StatusNoContent)
+}
+
+// RemoveTeamRepo 取消团队对组织仓库的访问
+func (c *Client) RemoveTeamRepo(ctx context.Context, org, teamSlug, repo string) error {
+	url := fmt.Sprintf("%s/orgs/%s/teams/%s/repos/%s/%s", c.baseURL, org, teamSlug, org, repo)
+	return c.doRepoJSON(ctx, org, repo, "DELETE", url, nil, nil, http.StatusNoContent)
+}
@@ -0,0 +1,154 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// maxCommitPages 读取提交历史时最多跟随的页数，避免长历史耗尽配额
+const maxCommitPages = 10
+
+// contentsListLimit contents 接口单个目录返回的条目上限
+const contentsListLimit = 1000
+
+// RepoListOptions 仓库列表的上游过滤条件
+type RepoListOptions struct {
+	Visibility  string // all, public, private
+	Affiliation string // owner, collaborator, organization_member 的逗号分隔组合
+}
+
+// getAllPages 沿 Link 头的 next 链接读取所有分页，maxPages 为 0 时不限制页数
+func getAllPages[T any](ctx context.Context, c *Client, url string, maxPages int) ([]T, error) {
+	items := []T{}
+	for pages := 0; url != "" && (maxPages == 0 || pages < maxPages); pages++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		c.setRequestHeaders(req)
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := c.handleError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var page []T
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page...)
+		url = NextPageURL(resp.Header.Get("Link"))
+	}
+	return items, nil
+}
+
+// CollectPage 按固定大小的上游分页读取从第 offset 个条目开始的至多 limit 个条目，只请求覆盖这一段的分页
+// fetch 读取第 page 页（从 1 开始），返回的条目少于 perPage 时视为最后一页；more 表示之后还有条目
+func CollectPage[T any](offset, limit, perPage int, fetch func(page int) ([]T, error)) (items []T, more bool, err error) {
+	items = []T{}
+	skip := offset % perPage
+	for page := offset/perPage + 1; ; page++ {
+		entries, err := fetch(page)
+		if err != nil {
+			return nil, false, err
+		}
+		if skip < len(entries) {
+			items = append(items, entries[skip:]...)
+		}
+		skip = 0
+		if len(items) > limit {
+			return items[:limit], true, nil
+		}
+		if len(entries) < perPage {
+			return items, false, nil
+		}
+	}
+}
+
+// NextPageURL 从 Link 头中取出 rel="next" 的地址，没有下一页时返回空字符串
+func NextPageURL(linkHeader string) string {
+	for _, link := range strings.Split(linkHeader, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return target
+			}
+		}
+	}
+	return ""
+}
+
+// listTreeEntries 通过树接口列出目录，用于超过 contents 接口上限的大目录
+func (c *Client) listTreeEntries(ctx context.Context, owner, repo, dir, ref string) ([]FileEntry, error) {
+	dir = strings.Trim(dir, "/")
+
+	// 根目录直接使用 ref 作为 tree-ish，子目录从上级目录取得树对象 SHA
+	treeSHA := ref
+	if treeSHA == "" {
+		treeSHA = "HEAD"
+	}
+	if dir != "" {
+		parentDir := path.Dir(dir)
+		if parentDir == "." {
+			parentDir = ""
+		}
+		parent, err := c.ListFiles(ctx, owner, repo, parentDir, ref)
+		if err != nil {
+			return nil, err
+		}
+		treeSHA = ""
+		for _, entry := range parent {
+			if entry.Path == dir && entry.Type == "dir" {
+				treeSHA = entry.SHA
+				break
+			}
+		}
+		if treeSHA == "" {
+			return nil, fmt.Errorf("directory not found: %s", dir)
+		}
+	}
+
+	tree, err := c.GetTree(ctx, owner, repo, treeSHA, false)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileEntry, 0, len(tree.Tree))
+	for _, entry := range tree.Tree {
+		file := FileEntry{
+			Name: entry.Path,
+			Path: path.Join(dir, entry.Path),
+			Size: entry.Size,
+			Type: "file",
+		}
+		if entry.SHA != nil {
+			file.SHA = *entry.SHA
+		}
+		switch entry.Type {
+		case "tree":
+			file.Type = "dir"
+		case "commit":
+			file.Type = "submodule"
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,270 @@
+package github
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitError GitHub 配额耗尽时返回的错误
+type RateLimitError struct {
+	// Limit 当前身份每小时的配额
+	Limit int
+	// Reset 配额恢复的时间
+	Reset time.Time
+	// Anonymous 请求没有携带 token，使用的是按 IP 计算的匿名配额
+	Anonymous bool
+	// Secondary 触发的是次级限流，例如短时间内请求或写入过多，与剩余配额无关
+	Secondary bool
+}
+
+// Error 实现 error 接口
+func (e *RateLimitError) Error() string {
+	if e.Secondary {
+		return fmt.Sprintf("GitHub secondary rate limit exceeded, retry after %s", e.Reset.Format(time.RFC3339))
+	}
+	msg := fmt.Sprintf("GitHub API rate limit exceeded (limit %d), resets at %s", e.Limit, e.Reset.Format(time.RFC3339))
+	if e.Anonymous {
+		msg += "; anonymous access shares a small per-IP quota, sign in with a token for a higher limit"
+	}
+	return msg
+}
+
+// RetryAfter 距离配额恢复的时间
+func (e *RateLimitError) RetryAfter() time.Duration {
+	wait := time.Until(e.Reset)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// RateLimitStatus 响应头中的配额状态
+type RateLimitStatus struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	Reset     time.Time `json:"reset"`
+	// Resource 配额所属的类别，例如 core、search、graphql
+	Resource string `json:"resource,omitempty"`
+}
+
+// rateLimitStatusFromHeader 解析 X-RateLimit-* 响应头
+func rateLimitStatusFromHeader(header http.Header) RateLimitStatus {
+	status := RateLimitStatus{Resource: header.Get("X-RateLimit-Resource")}
+	status.Limit, _ = strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	status.Remaining, _ = strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	status.Used, _ = strconv.Atoi(header.Get("X-RateLimit-Used"))
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		status.Reset = time.Unix(reset, 0)
+	}
+	return status
+}
+
+// rateLimitFromResponse 根据响应头判断是否为配额耗尽，不是时返回 nil
+func (c *Client) rateLimitFromResponse(resp *http.Response) *RateLimitError {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	retryAfter := resp.Header.Get("Retry-After")
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" && retryAfter == "" {
+		return nil
+	}
+
+	e := &RateLimitError{
+		Anonymous: c.token == "" && !c.installation,
+		Secondary: resp.Header.Get("X-RateLimit-Remaining") != "0",
+	}
+	e.Limit, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+		e.Reset = time.Now().Add(time.Duration(seconds) * time.Second)
+	} else if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		e.Reset = time.Unix(reset, 0)
+	} else {
+		e.Reset = time.Now().Add(time.Minute)
+	}
+	return e
+}
+
+// maxTrackedQuotas 配额记录数量上限，超过时清理长时间未使用的身份
+const maxTrackedQuotas = 10000
+
+// quotaIdleTTL 身份的配额记录在这段时间内未使用时可以清理
+const quotaIdleTTL = 2 * time.Hour
+
+// QuotaStatus 一个身份在各配额类别上的状态
+type QuotaStatus struct {
+	// Resources 按类别索引的配额，例如 core、search、graphql
+	Resources map[string]RateLimitStatus `json:"resources"`
+	// PausedUntil 触发次级限流后暂停请求的截止时间
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+}
+
+// quotaState 单个身份的配额记录，由同一身份的所有客户端共享
+type quotaState struct {
+	mu        sync.Mutex
+	resources map[string]RateLimitStatus
+	paused    time.Time
+	lastUsed  time.Time
+}
+
+// observe 根据响应头更新配额，响应没有配额信息时返回 false
+func (q *quotaState) observe(header http.Header) (RateLimitStatus, bool) {
+	status := rateLimitStatusFromHeader(header)
+	if status.Limit == 0 {
+		return status, false
+	}
+	if status.Resource == "" {
+		status.Resource = "core"
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.resources[status.Resource] = status
+	return status, true
+}
+
+// pauseUntil 次级限流后暂停同一身份的请求
+func (q *quotaState) pauseUntil(t time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if t.After(q.paused) {
+		q.paused = t
+	}
+}
+
+// delayBefore 计算发送请求前需要等待的时间，exhausted 表示配额已耗尽或处于次级限流暂停中
+// 剩余配额低于 reservePercent 时把剩余请求平摊到配额恢复之前
+func (q *quotaState) delayBefore(resource string, reservePercent int) (delay time.Duration, limit int, exhausted bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.lastUsed = now
+	status, ok := q.resources[resource]
+	if q.paused.After(now) {
+		return q.paused.Sub(now), status.Limit, true
+	}
+	if !ok || !status.Reset.After(now) {
+		return 0, status.Limit, false
+	}
+
+	untilReset := status.Reset.Sub(now)
+	if status.Remaining <= 0 {
+		return untilReset, status.Limit, true
+	}
+	if status.Remaining > status.Limit*reservePercent/100 {
+		return 0, status.Limit, false
+	}
+	// 乐观地扣减一次，避免并发请求都按同一个剩余值计算
+	status.Remaining--
+	q.resources[resource] = status
+	return untilReset / time.Duration(status.Remaining+2), status.Limit, false
+}
+
+// snapshot 返回当前配额的副本
+func (q *quotaState) snapshot() QuotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := QuotaStatus{Resources: make(map[string]RateLimitStatus, len(q.resources))}
+	for name, resource := range q.resources {
+		status.Resources[name] = resource
+	}
+	if q.paused.After(time.Now()) {
+		paused := q.paused
+		status.PausedUntil = &paused
+	}
+	return status
+}
+
+// quotaRegistry 按身份索引的配额记录
+type quotaRegistry struct {
+	mu     sync.Mutex
+	states map[string]*quotaState
+}
+
+// quotas 进程内共享的配额记录，同一 token 的不同请求使用同一份记录
+var quotas = &quotaRegistry{states: make(map[string]*quotaState)}
+
+// get 返回身份的配额记录，不存在时创建
+func (r *quotaRegistry) get(key string) *quotaState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if state, ok := r.states[key]; ok {
+		return state
+	}
+	if len(r.states) >= maxTrackedQuotas {
+		r.pruneLocked()
+	}
+	state := &quotaState{resources: make(map[string]RateLimitStatus), lastUsed: time.Now()}
+	r.states[key] = state
+	return state
+}
+
+// pruneLocked 清理长时间未使用的记录，调用方需持有锁
+func (r *quotaRegistry) pruneLocked() {
+	cutoff := time.Now().Add(-quotaIdleTTL)
+	for key, state := range r.states {
+		state.mu.Lock()
+		idle := state.lastUsed.Before(cutoff)
+		state.mu.Unlock()
+		if idle {
+			delete(r.states, key)
+		}
+	}
+}
+
+// quotaKey 计算配额记录的键，不同实例和身份的配额互相独立，token 只保存哈希
+func quotaKey(apiURL, identity string) string {
+	sum := sha256.Sum256([]byte(identity))
+	return apiURL + "|" + hex.EncodeToString(sum[:8])
+}
+
+// OnRateLimit 设置收到带配额信息的响应时的回调，用于把配额转发给调用方
+func (c *Client) OnRateLimit(fn func(status RateLimitStatus)) {
+	c.observer = fn
+}
+
+// QuotaStatus 返回客户端身份最近一次观察到的配额，不发送请求
+func (c *Client) QuotaStatus() QuotaStatus {
+	return quotas.get(c.quotaKey).snapshot()
+}
+
+// RateLimits 查询客户端身份的当前配额，/rate_limit 接口本身不消耗配额
+func (c *Client) RateLimits(ctx context.Context) (QuotaStatus, error) {
+	url := fmt.Sprintf("%s/rate_limit", c.baseURL)
+
+	var result struct {
+		Resources map[string]struct {
+			Limit     int   `json:"limit"`
+			Remaining int   `json:"remaining"`
+			Used      int   `json:"used"`
+			Reset     int64 `json:"reset"`
+		} `json:"resources"`
+	}
+	if err := c.doJSON(ctx, "GET", url, nil, &result, http.StatusOK); err != nil {
+		return QuotaStatus{}, err
+	}
+
+	quota := quotas.get(c.quotaKey)
+	quota.mu.Lock()
+	for name, resource := range result.Resources {
+		quota.resources[name] = RateLimitStatus{
+			Limit:     resource.Limit,
+			Remaining: resource.Remaining,
+			Used:      resource.Used,
+			Reset:     time.Unix(resource.Reset, 0),
+			Resource:  name,
+		}
+	}
+	quota.mu.Unlock()
+	return quota.snapshot(), nil
+}
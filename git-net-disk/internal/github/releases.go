@@ -0,0 +1,134 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"time"
+)
+
+// Release GitHub Release 信息
+type Release struct {
+	ID        int64          `json:"id"`
+	TagName   string         `json:"tag_name"`
+	Name      string         `json:"name"`
+	Body      string         `json:"body"`
+	Draft     bool           `json:"draft"`
+	CreatedAt time.Time      `json:"created_at"`
+	Assets    []ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset Release 附件信息
+type ReleaseAsset struct {
+	ID                 int64     `json:"id"`
+	Name               string    `json:"name"`
+	Size               int64     `json:"size"`
+	ContentType        string    `json:"content_type"`
+	BrowserDownloadURL string    `json:"browser_download_url"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// ListReleases 列出仓库的 Release，包括草稿
+func (c *Client) ListReleases(ctx context.Context, owner, repo string) ([]Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases?per_page=100", c.baseURL, owner, repo)
+
+	return getAllPages[Release](ctx, c, url, 0)
+}
+
+// ListReleaseAssets 列出 Release 的全部附件
+func (c *Client) ListReleaseAssets(ctx context.Context, owner, repo string, releaseID int64) ([]ReleaseAsset, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/%d/assets?per_page=100", c.baseURL, owner, repo, releaseID)
+
+	return getAllPages[ReleaseAsset](ctx, c, url, 0)
+}
+
+// CreateRelease 创建 Release，draft 为 true 时不会创建标签也不会公开
+func (c *Client) CreateRelease(ctx context.Context, owner, repo, tagName, name, body string, draft bool) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases", c.baseURL, owner, repo)
+
+	requestBody := struct {
+		TagName string `json:"tag_name"`
+		Name    string `json:"name"`
+		Body    string `json:"body"`
+		Draft   bool   `json:"draft"`
+	}{
+		TagName: tagName,
+		Name:    name,
+		Body:    body,
+		Draft:   draft,
+	}
+
+	var release Release
+	if err := c.doJSON(ctx, "POST", url, requestBody, &release, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// DeleteRelease 删除 Release，不会删除对应的标签
+func (c *Client) DeleteRelease(ctx context.Context, owner, repo string, releaseID int64) error {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/%d", c.baseURL, owner, repo, releaseID)
+
+	return c.doJSON(ctx, "DELETE", url, nil, nil, http.StatusNoContent)
+}
+
+// UploadReleaseAsset 以流的方式上传 Release 附件
+func (c *Client) UploadReleaseAsset(ctx context.Context, owner, repo string, releaseID int64, name, contentType string, body io.Reader, size int64) (*ReleaseAsset, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/%d/assets?name=%s", c.uploadURL, owner, repo, releaseID, neturl.QueryEscape(name))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setRequestHeaders(req)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = size
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, c.handleError(resp)
+	}
+
+	var asset ReleaseAsset
+	if err := json.NewDecoder(resp.Body).Decode(&asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// DownloadReleaseAsset 下载 Release 附件，调用方负责关闭返回的 Body
+func (c *Client) DownloadReleaseAsset(ctx context.Context, owner, repo string, assetID int64) (*http.Response, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/assets/%d", c.baseURL, owner, repo, assetID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setRequestHeaders(req)
+	// 请求二进制内容，GitHub 会重定向到存储地址，跨域重定向时 Authorization 头会被去掉
+	req.Header.Set("Accept", "application/octet-stream")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, c.handleError(resp)
+	}
+	return resp, nil
+}
@@ -0,0 +1,109 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// 仓库管理操作的错误类型，调用方可以用 errors.Is 判断
+var (
+	// ErrRepositoryNotFound 仓库不存在，或 token 无权访问私有仓库
+	ErrRepositoryNotFound = errors.New("repository not found")
+	// ErrRepositoryForbidden token 缺少所需权限，例如删除仓库需要 delete_repo
+	ErrRepositoryForbidden = errors.New("insufficient permission for repository")
+	// ErrRepositoryInvalid 请求参数无效，例如新名称已被占用
+	ErrRepositoryInvalid = errors.New("invalid repository update")
+)
+
+// RepositoryUpdate 仓库可修改的属性，nil 字段保持不变
+type RepositoryUpdate struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Private     *bool   `json:"private,omitempty"`
+	Archived    *bool   `json:"archived,omitempty"`
+}
+
+// UpdateRepository 修改仓库名称、描述、可见性或归档状态
+func (c *Client) UpdateRepository(ctx context.Context, owner, repo string, update RepositoryUpdate) (*Repository, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s", c.baseURL, owner, repo)
+
+	var repository Repository
+	if err := c.doRepoJSON(ctx, owner, repo, "PATCH", url, update, &repository, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &repository, nil
+}
+
+// ReplaceTopics 用 names 替换仓库的全部主题，返回更新后的主题
+func (c *Client) ReplaceTopics(ctx context.Context, owner, repo string, names []string) ([]string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/topics", c.baseURL, owner, repo)
+
+	requestBody := struct {
+		Names []string `json:"names"`
+	}{
+		Names: names,
+	}
+
+	var topics struct {
+		Names []string `json:"names"`
+	}
+	if err := c.doRepoJSON(ctx, owner, repo, "PUT", url, requestBody, &topics, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return topics.Names, nil
+}
+
+// GetTopics 获取仓库的主题
+func (c *Client) GetTopics(ctx context.Context, owner, repo string) ([]string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/topics", c.baseURL, owner, repo)
+
+	var topics struct {
+		Names []string `json:"names"`
+	}
+	if err := c.doRepoJSON(ctx, owner, repo, "GET", url, nil, &topics, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return topics.Names, nil
+}
+
+// DeleteRepository 删除仓库，token 需要 delete_repo 权限
+func (c *Client) DeleteRepository(ctx context.Context, owner, repo string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s", c.baseURL, owner, repo)
+	return c.doRepoJSON(ctx, owner, repo, "DELETE", url, nil, nil, http.StatusNoContent)
+}
+
+// doRepoJSON 与 doJSON 相同，但把仓库接口常见的错误状态码映射为 ErrRepository* 错误
+func (c *Client) doRepoJSON(ctx context.Context, owner, repo, method, url string, in, out interface{}, expected ...int) error {
+	req, err := c.newJSONRequest(ctx, method, url, in)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			if out == nil || resp.StatusCode == http.StatusNoContent {
+				return nil
+			}
+			return json.NewDecoder(resp.Body).Decode(out)
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s/%s", ErrRepositoryNotFound, owner, repo)
+	case http.StatusForbidden:
+		return fmt.Errorf("%w: %w", ErrRepositoryForbidden, c.handleError(resp))
+	case http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %w", ErrRepositoryInvalid, c.handleError(resp))
+	}
+	return c.handleError(resp)
+}
@@ -0,0 +1,255 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secondaryRateLimitMinDelay 次级限流没有给出 Retry-After 时的最短等待时间
+const secondaryRateLimitMinDelay = 5 * time.Second
+
+// RetryPolicy GitHub 请求的重试和配额保护策略
+type RetryPolicy struct {
+	// MaxRetries 首次请求失败后最多重试的次数，0 表示不重试
+	MaxRetries int
+	// BaseDelay 指数退避的初始等待时间
+	BaseDelay time.Duration
+	// MaxDelay 单次等待的上限，Retry-After 或配额恢复时间超过它时不再等待，直接返回错误
+	MaxDelay time.Duration
+	// ReservePercent 剩余配额低于上限的这个百分比时放慢请求，把剩余配额平摊到恢复之前
+	ReservePercent int
+}
+
+// DefaultRetryPolicy 默认的重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     3,
+		BaseDelay:      500 * time.Millisecond,
+		MaxDelay:       20 * time.Second,
+		ReservePercent: 5,
+	}
+}
+
+// RetryPolicyFromEnv 读取 GITHUB_MAX_RETRIES、GITHUB_RETRY_BASE_DELAY、GITHUB_RETRY_MAX_DELAY 和 GITHUB_RATE_LIMIT_RESERVE_PERCENT
+// 未设置或无法解析的值使用默认策略
+func RetryPolicyFromEnv() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	if n, err := strconv.Atoi(os.Getenv("GITHUB_MAX_RETRIES")); err == nil && n >= 0 {
+		policy.MaxRetries = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("GITHUB_RETRY_BASE_DELAY")); err == nil && d > 0 {
+		policy.BaseDelay = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("GITHUB_RETRY_MAX_DELAY")); err == nil && d > 0 {
+		policy.MaxDelay = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("GITHUB_RATE_LIMIT_RESERVE_PERCENT")); err == nil && n >= 0 && n < 100 {
+		policy.ReservePercent = n
+	}
+	return policy
+}
+
+// retryPolicy 进程内所有客户端共用的策略，首次使用时从环境变量读取
+var retryPolicy = sync.OnceValue(RetryPolicyFromEnv)
+
+// backoff 第 attempt 次重试前的等待时间，使用 full jitter 避免多个请求同时重试
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// retryTransport 为 GitHub 请求添加重试、次级限流退避和配额保护，并记录每个身份的配额
+type retryTransport struct {
+	base   http.RoundTripper
+	client *Client
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := retryPolicy()
+	quota := quotas.get(t.client.quotaKey)
+	resource := resourceForRequest(req)
+	// 只有 API 地址计入配额，raw 内容和附件下载不受影响
+	tracked := strings.HasPrefix(req.URL.String(), t.client.baseURL)
+
+	for attempt := 0; ; attempt++ {
+		if tracked {
+			if err := t.waitForQuota(req.Context(), quota, resource, policy); err != nil {
+				return nil, err
+			}
+		}
+
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err == nil && tracked {
+			if status, ok := quota.observe(resp.Header); ok && t.client.observer != nil {
+				t.client.observer(status)
+			}
+		}
+
+		delay, retry, secondary := t.retryDelay(req, resp, err, attempt, policy)
+		if secondary && tracked {
+			// 同一身份的其他请求也暂停，避免继续触发次级限流
+			quota.pauseUntil(time.Now().Add(delay))
+		}
+		if !retry {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		fmt.Printf("[WARN] Retrying GitHub request %s %s in %s (attempt %d/%d)\n", req.Method, req.URL.Path, delay.Round(time.Millisecond), attempt+1, policy.MaxRetries)
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay 判断请求是否需要重试以及重试前的等待时间，secondary 表示响应为次级限流
+func (t *retryTransport) retryDelay(req *http.Request, resp *http.Response, err error, attempt int, policy RetryPolicy) (delay time.Duration, retry bool, secondary bool) {
+	if err != nil {
+		// 网络错误只重试只读请求，请求被取消或超时时不重试
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || !isSafeMethod(req.Method) {
+			return 0, false, false
+		}
+		return policy.backoff(attempt), t.canRetry(req, attempt, policy), false
+	}
+
+	if isSecondaryRateLimit(resp) {
+		// 次级限流的请求没有被执行，任何方法都可以重试
+		delay = retryAfter(resp)
+		if delay == 0 {
+			delay = max(policy.backoff(attempt), secondaryRateLimitMinDelay)
+		}
+		return delay, delay <= policy.MaxDelay && t.canRetry(req, attempt, policy), true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// 写请求可能已在 GitHub 端生效，例如更新文件后重试会因 SHA 变化返回 409
+		if !isSafeMethod(req.Method) {
+			return 0, false, false
+		}
+		delay = retryAfter(resp)
+		if delay == 0 {
+			delay = policy.backoff(attempt)
+		}
+		return delay, delay <= policy.MaxDelay && t.canRetry(req, attempt, policy), false
+	}
+	return 0, false, false
+}
+
+// canRetry 检查是否还有重试次数，以及请求体能否重放
+func (t *retryTransport) canRetry(req *http.Request, attempt int, policy RetryPolicy) bool {
+	if attempt >= policy.MaxRetries {
+		return false
+	}
+	// 流式上传等无法重放请求体的请求不能重试
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// waitForQuota 在发送请求前检查配额，剩余配额不多时放慢请求，耗尽时等待恢复或直接返回 *RateLimitError
+func (t *retryTransport) waitForQuota(ctx context.Context, quota *quotaState, resource string, policy RetryPolicy) error {
+	delay, limit, exhausted := quota.delayBefore(resource, policy.ReservePercent)
+	if delay <= 0 {
+		return nil
+	}
+	if exhausted && delay > policy.MaxDelay {
+		return &RateLimitError{
+			Limit:     limit,
+			Reset:     time.Now().Add(delay),
+			Anonymous: t.client.token == "" && !t.client.installation,
+		}
+	}
+	return sleepContext(ctx, min(delay, policy.MaxDelay))
+}
+
+// isSafeMethod 检查方法是否只读，重复执行不会产生副作用
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// isSecondaryRateLimit 检查响应是否为次级限流，GitHub 返回 403 或 429，并在 message 中说明
+func isSecondaryRateLimit(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	// 主配额耗尽不是次级限流，等待时间由 X-RateLimit-Reset 决定
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return false
+	}
+	if resp.Header.Get("Retry-After") != "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(peekBody(resp)), "secondary rate limit")
+}
+
+// peekBody 读取错误响应体用于判断，并放回响应中供后续处理
+func peekBody(resp *http.Response) string {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// retryAfter 解析 Retry-After 响应头，没有时返回 0
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// resourceForRequest 请求计入的配额类别
+func resourceForRequest(req *http.Request) string {
+	switch {
+	case strings.Contains(req.URL.Path, "/search/"):
+		return "search"
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	}
+	return "core"
+}
+
+// sleepContext 等待 d，ctx 结束时提前返回 ctx 的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,216 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotPrefix 快照在标签和分支中的命名空间
+const snapshotPrefix = "snapshot/"
+
+// snapshotNamePattern 合法的快照名称
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+// Snapshot 网盘快照信息
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`   // tag 或 branch
+	Ref       string    `json:"ref"`    // 用作 ListFiles/GetFileContent 的 ref 参数
+	Commit    string    `json:"commit"` // 快照指向的提交
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateSnapshot 从分支当前 head 创建快照，branch 为空时使用默认分支
+func (c *Client) CreateSnapshot(ctx context.Context, owner, repo, name, snapshotType, branch, message string) (*Snapshot, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: invalid snapshot name %s", ErrInvalid, name)
+	}
+	if snapshotType == "" {
+		snapshotType = "tag"
+	}
+	if snapshotType != "tag" && snapshotType != "branch" {
+		return nil, fmt.Errorf("%w: invalid snapshot type %s", ErrInvalid, snapshotType)
+	}
+
+	// 标签和分支共用名称空间，避免 ref 参数产生歧义
+	existing, err := c.findSnapshotRef(ctx, owner, repo, name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%w: snapshot %s already exists", ErrConflict, name)
+	}
+
+	if branch == "" {
+		repository, err := c.GetRepository(ctx, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		branch = repository.DefaultBranch
+	}
+
+	head, err := c.GetRef(ctx, owner, repo, "heads/"+branch)
+	if err != nil {
+		return nil, err
+	}
+	commitSHA := head.Object.SHA
+
+	if message == "" {
+		message = fmt.Sprintf("Snapshot %s of %s", name, branch)
+	}
+
+	snapshot := &Snapshot{
+		Name:    name,
+		Type:    snapshotType,
+		Ref:     snapshotPrefix + name,
+		Commit:  commitSHA,
+		Message: message,
+	}
+
+	if snapshotType == "branch" {
+		// 分支快照没有自己的时间，与 ListSnapshots 一致取所指提交的时间
+		commit, err := c.GetGitCommit(ctx, owner, repo, commitSHA)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := c.CreateRef(ctx, owner, repo, "heads/"+snapshotPrefix+name, commitSHA); err != nil {
+			return nil, err
+		}
+		snapshot.CreatedAt = commit.Committer.Date
+		return snapshot, nil
+	}
+
+	// 附注标签会记录创建时间
+	tag, err := c.CreateTag(ctx, owner, repo, snapshotPrefix+name, message, commitSHA)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.CreateRef(ctx, owner, repo, "tags/"+snapshotPrefix+name, tag.SHA); err != nil {
+		return nil, err
+	}
+	snapshot.CreatedAt = tag.Tagger.Date
+	return snapshot, nil
+}
+
+// ListSnapshots 列出所有快照，按时间倒序
+func (c *Client) ListSnapshots(ctx context.Context, owner, repo string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+
+	for _, kind := range []string{"tags", "heads"} {
+		refs, err := c.ListMatchingRefs(ctx, owner, repo, kind+"/"+snapshotPrefix)
+		if err != nil {
+			return nil, err
+		}
+		for i := range refs {
+			snapshot, err := c.resolveSnapshot(ctx, owner, repo, &refs[i])
+			if err != nil {
+				return nil, err
+			}
+			snapshots = append(snapshots, *snapshot)
+		}
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// RestoreSnapshot 将分支内容回滚到快照，以新提交的方式保留历史
+func (c *Client) RestoreSnapshot(ctx context.Context, owner, repo, name, branch, message string) (*GitCommit, error) {
+	ref, err := c.findSnapshotRef(ctx, owner, repo, name)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := c.resolveSnapshot(ctx, owner, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	snapshotCommit, err := c.GetGitCommit(ctx, owner, repo, snapshot.Commit)
+	if err != nil {
+		return nil, err
+	}
+
+	if branch == "" {
+		repository, err := c.GetRepository(ctx, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		branch = repository.DefaultBranch
+	}
+	head, err := c.GetRef(ctx, owner, repo, "heads/"+branch)
+	if err != nil {
+		return nil, err
+	}
+
+	if message == "" {
+		message = fmt.Sprintf("Restore snapshot %s", name)
+	}
+	commit, err := c.CreateGitCommit(ctx, owner, repo, message, snapshotCommit.Tree.SHA, []string{head.Object.SHA})
+	if err != nil {
+		return nil, err
+	}
+
+	// 只允许快进，分支在此期间被修改时返回错误
+	if err := c.UpdateRef(ctx, owner, repo, "heads/"+branch, commit.SHA, false); err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
+
+// findSnapshotRef 按名称查找快照引用
+func (c *Client) findSnapshotRef(ctx context.Context, owner, repo, name string) (*Reference, error) {
+	for _, kind := range []string{"tags", "heads"} {
+		refs, err := c.ListMatchingRefs(ctx, owner, repo, kind+"/"+snapshotPrefix+name)
+		if err != nil {
+			return nil, err
+		}
+		// matching-refs 是前缀匹配，需要精确比对
+		for i := range refs {
+			if refs[i].Ref == "refs/"+kind+"/"+snapshotPrefix+name {
+				return &refs[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: snapshot %s", ErrNotFound, name)
+}
+
+// resolveSnapshot 将引用解析为快照信息
+func (c *Client) resolveSnapshot(ctx context.Context, owner, repo string, ref *Reference) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+
+	if strings.HasPrefix(ref.Ref, "refs/heads/") {
+		snapshot.Type = "branch"
+		snapshot.Ref = strings.TrimPrefix(ref.Ref, "refs/heads/")
+	} else {
+		snapshot.Type = "tag"
+		snapshot.Ref = strings.TrimPrefix(ref.Ref, "refs/tags/")
+	}
+	snapshot.Name = strings.TrimPrefix(snapshot.Ref, snapshotPrefix)
+
+	// 附注标签取标签时间，其余取所指提交的时间
+	if ref.Object.Type == "tag" {
+		tag, err := c.GetTag(ctx, owner, repo, ref.Object.SHA)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Commit = tag.Object.SHA
+		snapshot.Message = strings.TrimSpace(tag.Message)
+		snapshot.CreatedAt = tag.Tagger.Date
+		return snapshot, nil
+	}
+
+	commit, err := c.GetGitCommit(ctx, owner, repo, ref.Object.SHA)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Commit = commit.SHA
+	snapshot.CreatedAt = commit.Committer.Date
+	return snapshot, nil
+}
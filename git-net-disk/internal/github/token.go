@@ -0,0 +1,355 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrTokenInvalid GitHub 拒绝了 token，可能已过期、被撤销或格式错误
+var ErrTokenInvalid = errors.New("GitHub rejected the token: it is invalid, expired or revoked")
+
+// token 类型，由 token 前缀区分
+const (
+	TokenTypeClassic      = "classic"        // ghp_ 经典个人访问令牌
+	TokenTypeFineGrained  = "fine_grained"   // github_pat_ 细粒度个人访问令牌
+	TokenTypeOAuth        = "oauth"          // gho_ OAuth App 令牌
+	TokenTypeUserToServer = "user_to_server" // ghu_ GitHub App 用户令牌
+	TokenTypeInstallation = "installation"   // ghs_ GitHub App 安装令牌
+	TokenTypeUnknown      = "unknown"        // 旧格式或 GitHub Enterprise 的令牌
+)
+
+// 仓库操作所需的权限级别
+const (
+	PermissionRead  = "pull"
+	PermissionWrite = "push"
+	PermissionAdmin = "admin"
+	// PermissionDelete 删除仓库，需要 admin 角色和 delete_repo 作用域
+	PermissionDelete = "delete"
+)
+
+// TokenInfo token 的身份、作用域和配额
+type TokenInfo struct {
+	// Login token 所属用户，安装令牌为空
+	Login string `json:"login"`
+	Type  string `json:"type"`
+	// FineGrained 细粒度令牌没有作用域，权限按仓库单独授予
+	FineGrained bool `json:"fine_grained"`
+	// Scopes 经典令牌和 OAuth 令牌的作用域，来自 X-OAuth-Scopes
+	Scopes []string `json:"scopes"`
+	// ExpiresAt 过期时间，nil 表示永不过期或 GitHub 未返回
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	RateLimit RateLimitStatus `json:"rate_limit"`
+}
+
+// HasScope 检查经典令牌是否拥有作用域，父作用域包含子作用域，例如 repo 包含 public_repo
+func (t *TokenInfo) HasScope(scope string) bool {
+	return hasScope(t.Scopes, scope)
+}
+
+// PermissionError token 缺少操作所需的权限，Hint 说明如何补齐
+type PermissionError struct {
+	Owner string `json:"owner,omitempty"`
+	Repo  string `json:"repo,omitempty"`
+	// Required 操作需要的权限级别，见 Permission* 常量
+	Required string `json:"required,omitempty"`
+	// Role 当前用户在仓库中的角色权限
+	Role string `json:"role,omitempty"`
+	// MissingScopes 经典令牌缺少的作用域
+	MissingScopes []string `json:"missing_scopes,omitempty"`
+	// AcceptedPermissions 细粒度令牌需要的权限，来自 X-Accepted-GitHub-Permissions，例如 contents=write
+	AcceptedPermissions string `json:"accepted_permissions,omitempty"`
+	Hint                string `json:"hint"`
+}
+
+// Error 实现 error 接口
+func (e *PermissionError) Error() string {
+	if e.Owner != "" {
+		return fmt.Sprintf("insufficient permission for %s/%s: %s", e.Owner, e.Repo, e.Hint)
+	}
+	return "insufficient permission: " + e.Hint
+}
+
+// InspectToken 查询 token 的身份、作用域、过期时间和配额
+func (c *Client) InspectToken(ctx context.Context) (*TokenInfo, error) {
+	// 安装令牌不代表用户，无法访问 /user
+	url := fmt.Sprintf("%s/user", c.baseURL)
+	if c.installation {
+		url = fmt.Sprintf("%s/rate_limit", c.baseURL)
+	}
+
+	req, err := c.newJSONRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrTokenInvalid
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleError(resp)
+	}
+
+	info := &TokenInfo{
+		Type:      c.tokenType(),
+		RateLimit: rateLimitStatusFromHeader(resp.Header),
+	}
+	if !c.installation {
+		var user User
+		if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+			return nil, err
+		}
+		info.Login = user.Login
+	}
+
+	// 经典令牌和 OAuth 令牌总会返回 X-OAuth-Scopes，可能为空；细粒度令牌没有这个头
+	if values, ok := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]; ok {
+		info.Scopes = parseScopes(strings.Join(values, ","))
+	} else if info.Type != TokenTypeInstallation {
+		info.FineGrained = true
+		if info.Type == TokenTypeUnknown {
+			info.Type = TokenTypeFineGrained
+		}
+	}
+	if info.Type == TokenTypeFineGrained {
+		info.FineGrained = true
+	}
+	if info.Scopes == nil {
+		info.Scopes = []string{}
+	}
+
+	if expires := parseTokenExpiration(resp.Header.Get("GitHub-Authentication-Token-Expiration")); !expires.IsZero() {
+		info.ExpiresAt = &expires
+	}
+	return info, nil
+}
+
+// CheckRepoPermission 检查当前 token 对仓库是否有 permission 级别的权限
+// 权限不足时返回 *PermissionError，仓库不存在时返回 ErrRepositoryNotFound
+// 细粒度令牌和安装令牌的仓库权限无法预先查询，只检查用户角色，实际缺少的权限由 GitHub 的 403 响应说明
+func (c *Client) CheckRepoPermission(ctx context.Context, owner, repo, permission string) error {
+	url := fmt.Sprintf("%s/repos/%s/%s", c.baseURL, owner, repo)
+	req, err := c.newJSONRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	values, classic := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]
+	scopes := parseScopes(strings.Join(values, ","))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return ErrTokenInvalid
+	case http.StatusNotFound:
+		// 缺少 repo 作用域的经典令牌看不到私有仓库，GitHub 只返回 404
+		if classic && !hasScope(scopes, "repo") {
+			return &PermissionError{
+				Owner:         owner,
+				Repo:          repo,
+				Required:      permission,
+				MissingScopes: []string{"repo"},
+				Hint:          "the repository was not found; if it is private, the token needs the repo scope",
+			}
+		}
+		return fmt.Errorf("%w: %s/%s", ErrRepositoryNotFound, owner, repo)
+	default:
+		return c.handleError(resp)
+	}
+
+	var repository Repository
+	if err := json.NewDecoder(resp.Body).Decode(&repository); err != nil {
+		return err
+	}
+
+	if c.installation {
+		return nil
+	}
+
+	if permErr := checkRole(&repository, permission); permErr != nil {
+		permErr.Owner, permErr.Repo = owner, repo
+		return permErr
+	}
+
+	if classic {
+		if missing := missingScopes(scopes, &repository, permission); len(missing) > 0 {
+			return &PermissionError{
+				Owner:         owner,
+				Repo:          repo,
+				Required:      permission,
+				MissingScopes: missing,
+				Hint:          fmt.Sprintf("the token is missing the %s scope; create a new token or re-authorize with it", strings.Join(missing, ", ")),
+			}
+		}
+	}
+	return nil
+}
+
+// checkRole 检查用户在仓库中的角色是否满足权限要求
+func checkRole(repository *Repository, permission string) *PermissionError {
+	if repository.Permissions == nil {
+		if permission == PermissionRead {
+			return nil
+		}
+		return &PermissionError{Required: permission, Hint: "sign in with a token to modify this repository"}
+	}
+
+	role := "read"
+	if repository.Permissions.Push {
+		role = "write"
+	}
+	if repository.Permissions.Admin {
+		role = "admin"
+	}
+
+	var granted bool
+	switch permission {
+	case PermissionRead:
+		granted = repository.Permissions.Pull
+	case PermissionWrite:
+		granted = repository.Permissions.Push
+	case PermissionAdmin, PermissionDelete:
+		granted = repository.Permissions.Admin
+	}
+	if granted {
+		return nil
+	}
+
+	needed := "write"
+	if permission == PermissionAdmin || permission == PermissionDelete {
+		needed = "admin"
+	}
+	return &PermissionError{
+		Required: permission,
+		Role:     role,
+		Hint:     fmt.Sprintf("you have %s access to this repository; ask an owner to grant you %s access", role, needed),
+	}
+}
+
+// missingScopes 返回经典令牌执行操作还缺少的作用域
+func missingScopes(scopes []string, repository *Repository, permission string) []string {
+	var missing []string
+	if permission != PermissionRead {
+		// 公开仓库的写操作 public_repo 即可，私有仓库需要 repo
+		if repository.Private && !hasScope(scopes, "repo") {
+			missing = append(missing, "repo")
+		} else if !repository.Private && !hasScope(scopes, "public_repo") {
+			missing = append(missing, "public_repo")
+		}
+	}
+	if permission == PermissionDelete && !hasScope(scopes, "delete_repo") {
+		missing = append(missing, "delete_repo")
+	}
+	return missing
+}
+
+// permissionErrorFromResponse 根据 GitHub 在 403 响应中给出的所需作用域或权限构造错误，没有这些信息时返回 nil
+func permissionErrorFromResponse(resp *http.Response, message string) *PermissionError {
+	if resp.StatusCode != http.StatusForbidden {
+		return nil
+	}
+
+	if accepted := resp.Header.Get("X-Accepted-GitHub-Permissions"); accepted != "" {
+		return &PermissionError{
+			AcceptedPermissions: accepted,
+			Hint:                fmt.Sprintf("%s; the token needs the %s permission for this repository", message, accepted),
+		}
+	}
+
+	accepted := parseScopes(resp.Header.Get("X-Accepted-OAuth-Scopes"))
+	if len(accepted) == 0 {
+		return nil
+	}
+	granted := parseScopes(resp.Header.Get("X-OAuth-Scopes"))
+	for _, scope := range accepted {
+		if hasScope(granted, scope) {
+			// 作用域足够，说明是用户角色不足
+			return nil
+		}
+	}
+	return &PermissionError{
+		MissingScopes: accepted,
+		Hint:          fmt.Sprintf("%s; the token needs one of these scopes: %s", message, strings.Join(accepted, ", ")),
+	}
+}
+
+// tokenType 根据前缀判断 token 类型
+func (c *Client) tokenType() string {
+	if c.installation {
+		return TokenTypeInstallation
+	}
+	switch {
+	case strings.HasPrefix(c.token, "github_pat_"):
+		return TokenTypeFineGrained
+	case strings.HasPrefix(c.token, "ghp_"):
+		return TokenTypeClassic
+	case strings.HasPrefix(c.token, "gho_"):
+		return TokenTypeOAuth
+	case strings.HasPrefix(c.token, "ghu_"):
+		return TokenTypeUserToServer
+	case strings.HasPrefix(c.token, "ghs_"):
+		return TokenTypeInstallation
+	}
+	return TokenTypeUnknown
+}
+
+// scopeParents 作用域到包含它的父作用域
+var scopeParents = map[string]string{
+	"public_repo":     "repo",
+	"repo:status":     "repo",
+	"repo:invite":     "repo",
+	"repo_deployment": "repo",
+	"read:org":        "admin:org",
+	"write:org":       "admin:org",
+	"read:user":       "user",
+	"user:email":      "user",
+}
+
+// hasScope 检查作用域列表是否包含 scope 或它的父作用域
+func hasScope(scopes []string, scope string) bool {
+	parent := scopeParents[scope]
+	if scope == "read:org" && hasScope(scopes, "write:org") {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope || (parent != "" && s == parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseScopes 解析逗号分隔的作用域列表
+func parseScopes(header string) []string {
+	scopes := []string{}
+	for _, scope := range strings.Split(header, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// parseTokenExpiration 解析 GitHub-Authentication-Token-Expiration 头，例如 "2024-03-01 10:00:00 UTC"
+func parseTokenExpiration(header string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05 MST", "2006-01-02 15:04:05 -0700"} {
+		if t, err := time.Parse(layout, header); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -8,6 +8,9 @@ import (
 	"time"
 )
 
+// ResponseHeaderTimeout 发出请求后等待响应头的最长时间
+const ResponseHeaderTimeout = 60 * time.Second
+
 // ProxyConfig 代理配置
 type ProxyConfig struct {
 	Type     string `json:"type"`     // 代理类型: http, socks5
@@ -19,10 +22,29 @@ type ProxyConfig struct {
 }
 
 // NewHTTPClient 创建支持代理的 HTTP 客户端
+// 每次调用都会新建连接，需要复用连接的调用方应使用 DefaultPool
 func NewHTTPClient(config ProxyConfig) (*http.Client, error) {
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Transport: transport,
+	}
+
+	return client, nil
+}
+
+// newTransport 创建支持代理的传输层
+func newTransport(config ProxyConfig) (http.RoundTripper, error) {
 	// 如果是 Trojan 代理，使用专门的客户端
 	if config.Enabled && config.Type == "trojan" {
-		return NewTrojanHTTPClient(config)
+		client, err := NewTrojanHTTPClient(config)
+		if err != nil {
+			return nil, err
+		}
+		return client.Transport, nil
 	}
 
 	transport := &http.Transport{
@@ -31,9 +53,14 @@ func NewHTTPClient(config ProxyConfig) (*http.Client, error) {
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
 		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
+		// 只限制等待响应头的时间，响应体的传输时长由请求的上下文控制，避免大文件传输被中途切断
+		ResponseHeaderTimeout: ResponseHeaderTimeout,
+		// 自定义 DialContext 后需要显式开启 HTTP/2，同一主机的并发请求复用一条连接
+		ForceAttemptHTTP2: true,
 	}
 
 	if config.Enabled {
@@ -44,12 +71,45 @@ func NewHTTPClient(config ProxyConfig) (*http.Client, error) {
 		transport.Proxy = http.ProxyURL(proxyURL)
 	}
 
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   30 * time.Second,
+	return transport, nil
+}
+
+// ParseProxyURL 解析 http://、socks5:// 或 trojan:// 形式的代理地址，空字符串表示直连
+func ParseProxyURL(proxyURL string) (ProxyConfig, error) {
+	if proxyURL == "" {
+		return ProxyConfig{Enabled: false}, nil
 	}
 
-	return client, nil
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Hostname() == "" {
+		return ProxyConfig{}, fmt.Errorf("invalid proxy URL: %s", proxyURL)
+	}
+
+	var config *ProxyConfig
+	switch u.Scheme {
+	case "trojan":
+		config = parseTrojanURL(proxyURL)
+	case "http", "socks5":
+		config = parseSimpleProxyURL(proxyURL)
+	default:
+		return ProxyConfig{}, fmt.Errorf("unsupported proxy type: %s", u.Scheme)
+	}
+	if config == nil {
+		return ProxyConfig{}, fmt.Errorf("invalid proxy URL: %s", proxyURL)
+	}
+
+	// 未指定端口时使用协议的默认端口
+	if config.Port == 0 {
+		switch config.Type {
+		case "http":
+			config.Port = 80
+		case "socks5":
+			config.Port = 1080
+		case "trojan":
+			config.Port = 443
+		}
+	}
+	return *config, nil
 }
 
 // createProxyURL 创建代理 URL
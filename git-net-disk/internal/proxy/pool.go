@@ -0,0 +1,327 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolConfig 客户端池的配置
+type PoolConfig struct {
+	// IdleTimeout 传输层闲置超过这个时间后关闭连接并移出池
+	IdleTimeout time.Duration
+	// MaxClients 池中传输层的上限，超出时先淘汰最久未使用的闲置传输层
+	MaxClients int
+}
+
+// DefaultPoolConfig 默认的客户端池配置
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		IdleTimeout: 10 * time.Minute,
+		MaxClients:  256,
+	}
+}
+
+// PoolConfigFromEnv 读取 HTTP_POOL_IDLE_TIMEOUT 和 HTTP_POOL_MAX_CLIENTS，未设置或无法解析时使用默认值
+func PoolConfigFromEnv() PoolConfig {
+	config := DefaultPoolConfig()
+	if d, err := time.ParseDuration(os.Getenv("HTTP_POOL_IDLE_TIMEOUT")); err == nil && d > 0 {
+		config.IdleTimeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("HTTP_POOL_MAX_CLIENTS")); err == nil && n > 0 {
+		config.MaxClients = n
+	}
+	return config
+}
+
+// DefaultPool 进程内共用的客户端池，首次使用时从环境变量读取配置
+var DefaultPool = sync.OnceValue(func() *Pool {
+	return NewPool(PoolConfigFromEnv())
+})
+
+// TokenFingerprint 返回 token 的指纹，用作池的键和统计中的标识，不暴露 token 本身
+func TokenFingerprint(token string) string {
+	if token == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:8])
+}
+
+// poolKey 池的键，同一 token 经同一代理的请求共享连接
+type poolKey struct {
+	fingerprint string
+	proxy       ProxyConfig
+}
+
+// poolEntry 池中的一个传输层及其使用情况
+type poolEntry struct {
+	key       poolKey
+	transport http.RoundTripper
+	createdAt time.Time
+	// lastUsed 最近一次请求开始或结束的时间，UnixNano
+	lastUsed atomic.Int64
+	requests atomic.Int64
+	http2    atomic.Int64
+	// active 已发出但响应体尚未关闭的请求数，大于 0 时不会被淘汰
+	active atomic.Int64
+}
+
+// Pool 按 token 指纹和代理配置复用 HTTP 传输层，避免每个请求重复 TLS 和代理握手
+type Pool struct {
+	config PoolConfig
+
+	mu        sync.Mutex
+	entries   map[poolKey]*poolEntry
+	hits      int64
+	misses    int64
+	evictions int64
+
+	stop chan struct{}
+}
+
+// NewPool 创建客户端池，并在后台定期关闭闲置的传输层
+func NewPool(config PoolConfig) *Pool {
+	p := &Pool{
+		config:  config,
+		entries: make(map[poolKey]*poolEntry),
+		stop:    make(chan struct{}),
+	}
+	go p.evictLoop()
+	return p
+}
+
+// Client 返回使用池中传输层的 HTTP 客户端
+// 客户端本身每次新建，调用方可以包装它的 Transport，底层连接在相同指纹和代理配置的客户端之间共享
+func (p *Pool) Client(fingerprint string, config ProxyConfig) (*http.Client, error) {
+	entry, err := p.get(poolKey{fingerprint: fingerprint, proxy: normalizeProxyConfig(config)})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: &pooledTransport{entry: entry},
+	}, nil
+}
+
+// get 取出或创建键对应的传输层
+func (p *Pool) get(key poolKey) (*poolEntry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry, ok := p.entries[key]; ok {
+		p.hits++
+		return entry, nil
+	}
+
+	transport, err := newTransport(key.proxy)
+	if err != nil {
+		return nil, err
+	}
+	p.misses++
+
+	if len(p.entries) >= p.config.MaxClients {
+		p.evictOldestLocked()
+	}
+	entry := &poolEntry{
+		key:       key,
+		transport: transport,
+		createdAt: time.Now(),
+	}
+	entry.lastUsed.Store(entry.createdAt.UnixNano())
+	p.entries[key] = entry
+	return entry, nil
+}
+
+// evictOldestLocked 淘汰最久未使用的闲置传输层，全部在使用中时允许暂时超出上限
+func (p *Pool) evictOldestLocked() {
+	var oldest *poolEntry
+	for _, entry := range p.entries {
+		if entry.active.Load() > 0 {
+			continue
+		}
+		if oldest == nil || entry.lastUsed.Load() < oldest.lastUsed.Load() {
+			oldest = entry
+		}
+	}
+	if oldest != nil {
+		p.removeLocked(oldest)
+	}
+}
+
+// removeLocked 把传输层移出池并关闭它的闲置连接
+func (p *Pool) removeLocked(entry *poolEntry) {
+	delete(p.entries, entry.key)
+	p.evictions++
+	if closer, ok := entry.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// evictLoop 定期淘汰闲置超时的传输层，直到 Close 被调用
+func (p *Pool) evictLoop() {
+	interval := p.config.IdleTimeout / 2
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.evictIdle()
+		}
+	}
+}
+
+// evictIdle 淘汰闲置超过 IdleTimeout 的传输层
+func (p *Pool) evictIdle() {
+	deadline := time.Now().Add(-p.config.IdleTimeout).UnixNano()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, entry := range p.entries {
+		if entry.active.Load() == 0 && entry.lastUsed.Load() < deadline {
+			p.removeLocked(entry)
+		}
+	}
+}
+
+// Close 停止后台淘汰并关闭所有闲置连接
+func (p *Pool) Close() {
+	close(p.stop)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, entry := range p.entries {
+		p.removeLocked(entry)
+	}
+}
+
+// PoolStats 客户端池的统计信息
+type PoolStats struct {
+	Clients    int `json:"clients"`
+	MaxClients int `json:"max_clients"`
+	// IdleTimeout 闲置淘汰时间，单位秒
+	IdleTimeout int64 `json:"idle_timeout"`
+	// Hits 复用已有传输层的次数，Misses 新建传输层的次数
+	Hits      int64            `json:"hits"`
+	Misses    int64            `json:"misses"`
+	Evictions int64            `json:"evictions"`
+	Entries   []PoolEntryStats `json:"entries"`
+}
+
+// PoolEntryStats 池中单个传输层的统计信息
+type PoolEntryStats struct {
+	Fingerprint string `json:"fingerprint"`
+	// Proxy 代理地址，不含认证信息，直连时为 direct
+	Proxy      string    `json:"proxy"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Requests   int64     `json:"requests"`
+	// HTTP2Requests 通过 HTTP/2 完成的请求数
+	HTTP2Requests int64 `json:"http2_requests"`
+	Active        int64 `json:"active"`
+}
+
+// Stats 返回池的统计信息，传输层按最近使用时间排序
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := PoolStats{
+		Clients:     len(p.entries),
+		MaxClients:  p.config.MaxClients,
+		IdleTimeout: int64(p.config.IdleTimeout.Seconds()),
+		Hits:        p.hits,
+		Misses:      p.misses,
+		Evictions:   p.evictions,
+		Entries:     make([]PoolEntryStats, 0, len(p.entries)),
+	}
+	for _, entry := range p.entries {
+		stats.Entries = append(stats.Entries, PoolEntryStats{
+			Fingerprint:   entry.key.fingerprint,
+			Proxy:         describeProxy(entry.key.proxy),
+			CreatedAt:     entry.createdAt,
+			LastUsedAt:    time.Unix(0, entry.lastUsed.Load()),
+			Requests:      entry.requests.Load(),
+			HTTP2Requests: entry.http2.Load(),
+			Active:        entry.active.Load(),
+		})
+	}
+	sort.Slice(stats.Entries, func(i, j int) bool {
+		return stats.Entries[i].LastUsedAt.After(stats.Entries[j].LastUsedAt)
+	})
+	return stats
+}
+
+// pooledTransport 记录池中传输层的使用情况
+type pooledTransport struct {
+	entry *poolEntry
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := t.entry
+	entry.requests.Add(1)
+	entry.active.Add(1)
+	entry.lastUsed.Store(time.Now().UnixNano())
+
+	resp, err := entry.transport.RoundTrip(req)
+	if err != nil {
+		entry.done()
+		return nil, err
+	}
+	if resp.ProtoMajor == 2 {
+		entry.http2.Add(1)
+	}
+	// 响应体读完关闭之前连接仍在使用，下载等长时间传输期间不能淘汰
+	resp.Body = &pooledBody{ReadCloser: resp.Body, entry: entry}
+	return resp, nil
+}
+
+// done 标记一个请求结束
+func (e *poolEntry) done() {
+	e.lastUsed.Store(time.Now().UnixNano())
+	e.active.Add(-1)
+}
+
+// pooledBody 在关闭时标记请求结束的响应体
+type pooledBody struct {
+	io.ReadCloser
+	entry *poolEntry
+	once  sync.Once
+}
+
+// Close 实现 io.Closer
+func (b *pooledBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.entry.done)
+	return err
+}
+
+// normalizeProxyConfig 未启用的代理配置视为直连，避免残留字段产生不同的键
+func normalizeProxyConfig(config ProxyConfig) ProxyConfig {
+	if !config.Enabled {
+		return ProxyConfig{}
+	}
+	return config
+}
+
+// describeProxy 返回不含认证信息的代理描述
+func describeProxy(config ProxyConfig) string {
+	if !config.Enabled {
+		return "direct"
+	}
+	return fmt.Sprintf("%s://%s:%d", config.Type, config.Host, config.Port)
+}
@@ -49,8 +49,6 @@ func ParseSubscription(subURL string) (*ProxyConfig, error) {
 		Timeout: 30 * time.Second,
 	}
 
-	fmt.Printf("[DEBUG] Downloading subscription from: %s\n", subURL)
-	
 	resp, err := client.Get(subURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download subscription: %v", err)
@@ -67,7 +65,6 @@ func ParseSubscription(subURL string) (*ProxyConfig, error) {
 	}
 
 	fmt.Printf("[DEBUG] Subscription content length: %d bytes\n", len(body))
-	fmt.Printf("[DEBUG] First 200 chars: %s\n", string(body[:min(200, len(body))]))
 	
 	// 尝试解析为 Clash 配置
 	fmt.Println("[DEBUG] Trying to parse as Clash YAML...")
@@ -84,7 +81,6 @@ func ParseSubscription(subURL string) (*ProxyConfig, error) {
 	decoded, err := base64.StdEncoding.DecodeString(string(body))
 	if err == nil {
 		fmt.Printf("[DEBUG] Base64 decoded length: %d bytes\n", len(decoded))
-		fmt.Printf("[DEBUG] Decoded first 200 chars: %s\n", string(decoded[:min(200, len(decoded))]))
 		nodes := parseBase64Nodes(string(decoded))
 		if len(nodes) > 0 {
 			fmt.Printf("[DEBUG] Found %d nodes from Base64\n", len(nodes))
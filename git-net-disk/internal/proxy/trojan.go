@@ -9,6 +9,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -56,11 +57,11 @@ func NewTrojanHTTPClient(config ProxyConfig) (*http.Client, error) {
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: ResponseHeaderTimeout,
 	}
 
 	client := &http.Client{
 		Transport: transport,
-		Timeout:   60 * time.Second,
 	}
 
 	fmt.Printf("[INFO] Created Trojan client for %s:%d\n", config.Host, config.Port)
@@ -71,7 +72,7 @@ func NewTrojanHTTPClient(config ProxyConfig) (*http.Client, error) {
 // dialTrojan 建立 Trojan 连接
 func dialTrojan(config ProxyConfig, targetAddr string) (net.Conn, error) {
 	// 1. 连接到 Trojan 服务器
-	serverAddr := fmt.Sprintf("%s:%d", config.Host, config.Port)
+	serverAddr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
 	
 	dialer := &net.Dialer{
 		Timeout: 30 * time.Second,
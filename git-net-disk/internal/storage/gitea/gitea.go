@@ -0,0 +1,342 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/proxy"
+	"git-net-disk/internal/storage"
+)
+
+// 编译期检查 Client 实现了 storage.Backend
+var (
+	_ storage.Backend               = (*Client)(nil)
+	_ storage.CommitPager           = (*Client)(nil)
+	_ storage.DefaultBranchResolver = (*Client)(nil)
+)
+
+// maxCommitPages 读取提交历史时最多跟随的页数
+const maxCommitPages = 20
+
+// Client Gitea/Forgejo REST API 客户端
+// Gitea 的仓库和 contents 接口与 GitHub 基本兼容，响应可以直接解析为 GitHub 的结构
+type Client struct {
+	Client  *http.Client
+	token   string
+	baseURL string // 形如 https://gitea.example.com/api/v1
+}
+
+// NewClient 创建 Gitea 客户端，serverURL 为实例根地址
+func NewClient(serverURL, token string, proxyConfig proxy.ProxyConfig) (*Client, error) {
+	if serverURL == "" {
+		return nil, fmt.Errorf("gitea server URL is required")
+	}
+	client, err := proxy.DefaultPool().Client(proxy.TokenFingerprint(token), proxyConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		Client:  client,
+		token:   token,
+		baseURL: strings.TrimRight(serverURL, "/") + "/api/v1",
+	}, nil
+}
+
+// ListRepositories 列出当前用户的所有仓库
+// Gitea 的仓库列表接口不支持可见性和 affiliation 过滤，opts 被忽略
+func (c *Client) ListRepositories(ctx context.Context, opts github.RepoListOptions) ([]github.Repository, error) {
+	url := fmt.Sprintf("%s/user/repos?limit=50", c.baseURL)
+
+	return getAllPages[github.Repository](ctx, c, url, 0)
+}
+
+// CreateRepository 创建新仓库
+func (c *Client) CreateRepository(ctx context.Context, name, description string, isPrivate, autoInit bool) (*github.Repository, error) {
+	url := fmt.Sprintf("%s/user/repos", c.baseURL)
+
+	requestBody := struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Private     bool   `json:"private"`
+		AutoInit    bool   `json:"auto_init"`
+	}{
+		Name:        name,
+		Description: description,
+		Private:     isPrivate,
+		AutoInit:    autoInit,
+	}
+
+	var repo github.Repository
+	if err := c.doJSON(ctx, "POST", url, requestBody, &repo, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// DefaultBranch 返回仓库的默认分支名
+func (c *Client) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s", c.baseURL, neturl.PathEscape(owner), neturl.PathEscape(repo))
+
+	var repository github.Repository
+	if err := c.doJSON(ctx, "GET", url, nil, &repository, http.StatusOK); err != nil {
+		return "", err
+	}
+	return repository.DefaultBranch, nil
+}
+
+// ListFiles 列出目录内容
+func (c *Client) ListFiles(ctx context.Context, owner, repo, path, ref string) ([]github.FileEntry, error) {
+	var files []github.FileEntry
+	if err := c.doJSON(ctx, "GET", c.contentsURL(owner, repo, path, ref), nil, &files, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// GetFileContent 读取文件内容
+func (c *Client) GetFileContent(ctx context.Context, owner, repo, path, ref string) (*github.FileEntry, error) {
+	var file github.FileEntry
+	if err := c.doJSON(ctx, "GET", c.contentsURL(owner, repo, path, ref), nil, &file, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetFileSHA 获取文件当前的 blob SHA，文件不存在时返回空字符串
+func (c *Client) GetFileSHA(ctx context.Context, owner, repo, path, branch string) (string, error) {
+	req, err := c.newRequest(ctx, "GET", c.contentsURL(owner, repo, path, branch), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", handleError(resp)
+	}
+
+	var file github.FileEntry
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return "", err
+	}
+	return file.SHA, nil
+}
+
+// CreateOrUpdateFile 写入文件，Gitea 创建用 POST，更新用 PUT
+func (c *Client) CreateOrUpdateFile(ctx context.Context, owner, repo, path, content, message, branch, sha string) (*github.FileEntry, error) {
+	requestBody := struct {
+		Content string `json:"content"`
+		Message string `json:"message"`
+		Branch  string `json:"branch,omitempty"`
+		SHA     string `json:"sha,omitempty"`
+	}{
+		Content: content,
+		Message: message,
+		Branch:  branch,
+		SHA:     sha,
+	}
+
+	method := "POST"
+	if sha != "" {
+		method = "PUT"
+	}
+
+	var result struct {
+		Content github.FileEntry `json:"content"`
+	}
+	if err := c.doJSON(ctx, method, c.contentsURL(owner, repo, path, ""), requestBody, &result, http.StatusOK, http.StatusCreated); err != nil {
+		return nil, conflictError(err)
+	}
+	return &result.Content, nil
+}
+
+// DeleteFile 删除文件
+func (c *Client) DeleteFile(ctx context.Context, owner, repo, path, sha, message, branch string) error {
+	requestBody := struct {
+		Message string `json:"message"`
+		SHA     string `json:"sha"`
+		Branch  string `json:"branch,omitempty"`
+	}{
+		Message: message,
+		SHA:     sha,
+		Branch:  branch,
+	}
+
+	return conflictError(c.doJSON(ctx, "DELETE", c.contentsURL(owner, repo, path, ""), requestBody, nil, http.StatusOK, http.StatusNoContent))
+}
+
+// conflictError 把写入时的 422 版本冲突归类为 ErrConflict
+// Gitea 对文件已存在和 sha 不匹配返回 422，与其他校验错误只能按 message 区分
+func conflictError(err error) error {
+	var apiErr *storage.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity &&
+		(strings.Contains(apiErr.Message, "already exists") || strings.Contains(apiErr.Message, "does not match")) {
+		return fmt.Errorf("%w: %w", storage.ErrConflict, err)
+	}
+	return err
+}
+
+// ListCommits 列出影响指定路径的提交历史，最多读取 maxCommitPages 页
+func (c *Client) ListCommits(ctx context.Context, owner, repo, path, ref string) ([]github.Commit, error) {
+	query := neturl.Values{}
+	if path != "" {
+		query.Set("path", path)
+	}
+	if ref != "" {
+		query.Set("sha", ref)
+	}
+	query.Set("limit", "50")
+	url := fmt.Sprintf("%s/repos/%s/%s/commits?%s", c.baseURL, owner, repo, query.Encode())
+
+	return getAllPages[github.Commit](ctx, c, url, maxCommitPages)
+}
+
+// ListCommitsPage 读取从第 offset 个提交开始的至多 limit 个提交，只请求覆盖这一段的上游分页
+func (c *Client) ListCommitsPage(ctx context.Context, owner, repo, path, ref string, offset, limit int) ([]github.Commit, bool, error) {
+	const perPage = 50
+	query := neturl.Values{}
+	if path != "" {
+		query.Set("path", path)
+	}
+	if ref != "" {
+		query.Set("sha", ref)
+	}
+	query.Set("limit", strconv.Itoa(perPage))
+
+	return github.CollectPage(offset, limit, perPage, func(page int) ([]github.Commit, error) {
+		query.Set("page", strconv.Itoa(page))
+		url := fmt.Sprintf("%s/repos/%s/%s/commits?%s", c.baseURL, owner, repo, query.Encode())
+		var commits []github.Commit
+		if err := c.doJSON(ctx, "GET", url, nil, &commits, http.StatusOK); err != nil {
+			return nil, err
+		}
+		return commits, nil
+	})
+}
+
+// contentsURL 构造 contents 接口地址，根目录不带结尾斜杠
+func (c *Client) contentsURL(owner, repo, path, ref string) string {
+	url := fmt.Sprintf("%s/repos/%s/%s/contents", c.baseURL, owner, repo)
+	if path = strings.Trim(path, "/"); path != "" {
+		url += "/" + path
+	}
+	if ref != "" {
+		url += "?ref=" + neturl.QueryEscape(ref)
+	}
+	return url
+}
+
+// newRequest 创建带认证头的请求
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "GitNetDisk")
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+	return req, nil
+}
+
+// doJSON 发送 JSON 请求并解析响应，expected 为可接受的状态码
+func (c *Client) doJSON(ctx context.Context, method, url string, in, out interface{}, expected ...int) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := c.newRequest(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			if out == nil {
+				return nil
+			}
+			return json.NewDecoder(resp.Body).Decode(out)
+		}
+	}
+	return handleError(resp)
+}
+
+// getAllPages 沿 Link 头的 next 链接读取所有分页，maxPages 为 0 时不限制页数
+func getAllPages[T any](ctx context.Context, c *Client, url string, maxPages int) ([]T, error) {
+	items := []T{}
+	for pages := 0; url != "" && (maxPages == 0 || pages < maxPages); pages++ {
+		req, err := c.newRequest(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := handleError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var page []T
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page...)
+		url = github.NextPageURL(resp.Header.Get("Link"))
+	}
+	return items, nil
+}
+
+// handleError 将错误响应转换为 *storage.APIError
+func handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	apiErr := &storage.APIError{Backend: "Gitea", StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	var errorResponse struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &errorResponse); err == nil && errorResponse.Message != "" {
+		apiErr.Message = errorResponse.Message
+	}
+	return apiErr
+}
@@ -0,0 +1,160 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/proxy"
+	"git-net-disk/internal/storage"
+)
+
+// newTestClient 创建指向 httptest 服务器的客户端
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL, "secret", proxy.ProxyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// writeError 按 Gitea 的格式写出错误响应
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+func TestHandleErrorReturnsTypedErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		target error
+	}{
+		{http.StatusNotFound, storage.ErrNotFound},
+		{http.StatusConflict, storage.ErrConflict},
+		{http.StatusForbidden, storage.ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, tt.status, "upstream says no")
+			})
+
+			_, err := client.GetFileContent(context.Background(), "alice", "drive", "a.txt", "")
+			if !errors.Is(err, tt.target) {
+				t.Fatalf("err = %v, want %v", err, tt.target)
+			}
+			var apiErr *storage.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Message != "upstream says no" {
+				t.Fatalf("err = %#v, want *storage.APIError with status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandleErrorKeepsNonJSONBody(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+
+	_, err := client.ListFiles(context.Background(), "alice", "drive", "", "")
+	var apiErr *storage.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Message != "bad gateway" {
+		t.Fatalf("err = %#v", err)
+	}
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("502 should not match a sentinel: %v", err)
+	}
+}
+
+func TestCreateOrUpdateFileConflict(t *testing.T) {
+	for _, message := range []string{"repository file already exists [path: a.txt]", "sha does not match [given: x, expected: y]"} {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, http.StatusUnprocessableEntity, message)
+		})
+
+		_, err := client.CreateOrUpdateFile(context.Background(), "alice", "drive", "a.txt", "aGk=", "add", "", "")
+		if !errors.Is(err, storage.ErrConflict) {
+			t.Fatalf("%q: err = %v, want ErrConflict", message, err)
+		}
+	}
+
+	// 其他 422 校验错误不是冲突
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusUnprocessableEntity, "path contains a malformed path component")
+	})
+	_, err := client.CreateOrUpdateFile(context.Background(), "alice", "drive", "a.txt", "aGk=", "add", "", "")
+	if err == nil || errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("err = %v, want a non-conflict error", err)
+	}
+}
+
+func TestCreateOrUpdateFileMethod(t *testing.T) {
+	var methods []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		methods = append(methods, r.Method)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"content":{"name":"a.txt","path":"a.txt","sha":"abc"}}`)
+	})
+
+	ctx := context.Background()
+	file, err := client.CreateOrUpdateFile(ctx, "alice", "drive", "a.txt", "aGk=", "add", "", "")
+	if err != nil || file.SHA != "abc" {
+		t.Fatalf("create: %v %+v", err, file)
+	}
+	if _, err := client.CreateOrUpdateFile(ctx, "alice", "drive", "a.txt", "aGk=", "update", "", "abc"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if len(methods) != 2 || methods[0] != "POST" || methods[1] != "PUT" {
+		t.Fatalf("methods = %v, want [POST PUT]", methods)
+	}
+}
+
+func TestGetFileSHAMissingFile(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "object does not exist")
+	})
+
+	sha, err := client.GetFileSHA(context.Background(), "alice", "drive", "a.txt", "main")
+	if err != nil || sha != "" {
+		t.Fatalf("GetFileSHA = %q, %v; want empty sha and no error", sha, err)
+	}
+}
+
+func TestListRepositoriesFollowsLinkHeader(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v1/user/repos?limit=50&page=2>; rel="next"`, server.URL))
+			fmt.Fprint(w, `[{"name":"one"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"name":"two"}]`)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "secret", proxy.ProxyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos, err := client.ListRepositories(context.Background(), github.RepoListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 || repos[0].Name != "one" || repos[1].Name != "two" {
+		t.Fatalf("repos = %+v", repos)
+	}
+}
@@ -0,0 +1,568 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/proxy"
+	"git-net-disk/internal/storage"
+)
+
+// 编译期检查 Client 实现了 storage.Backend
+var (
+	_ storage.Backend               = (*Client)(nil)
+	_ storage.CommitPager           = (*Client)(nil)
+	_ storage.DefaultBranchResolver = (*Client)(nil)
+)
+
+// maxCommitPages 读取提交历史时最多跟随的页数
+const maxCommitPages = 10
+
+// Client GitLab REST API v4 客户端，响应转换为 GitHub 的结构
+type Client struct {
+	Client  *http.Client
+	token   string
+	baseURL string // 形如 https://gitlab.com/api/v4
+}
+
+// project GitLab 项目
+type project struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	Path              string    `json:"path"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	Description       string    `json:"description"`
+	Visibility        string    `json:"visibility"`
+	WebURL            string    `json:"web_url"`
+	DefaultBranch     string    `json:"default_branch"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+	Namespace         struct {
+		ID        int64  `json:"id"`
+		Path      string `json:"path"`
+		AvatarURL string `json:"avatar_url"`
+	} `json:"namespace"`
+}
+
+// treeEntry GitLab 目录树条目
+type treeEntry struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"` // blob 或 tree
+	Path string `json:"path"`
+}
+
+// commitEntry GitLab 提交记录
+type commitEntry struct {
+	ID           string    `json:"id"`
+	AuthorName   string    `json:"author_name"`
+	AuthorEmail  string    `json:"author_email"`
+	AuthoredDate time.Time `json:"authored_date"`
+	Message      string    `json:"message"`
+}
+
+// repositoryFile GitLab 文件接口的响应
+type repositoryFile struct {
+	FileName     string `json:"file_name"`
+	FilePath     string `json:"file_path"`
+	Size         int    `json:"size"`
+	Encoding     string `json:"encoding"`
+	Content      string `json:"content"`
+	Ref          string `json:"ref"`
+	BlobID       string `json:"blob_id"`
+	LastCommitID string `json:"last_commit_id"`
+}
+
+// NewClient 创建 GitLab 客户端，serverURL 为实例根地址，为空时使用 gitlab.com
+func NewClient(serverURL, token string, proxyConfig proxy.ProxyConfig) (*Client, error) {
+	if serverURL == "" {
+		serverURL = "https://gitlab.com"
+	}
+	client, err := proxy.DefaultPool().Client(proxy.TokenFingerprint(token), proxyConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		Client:  client,
+		token:   token,
+		baseURL: strings.TrimRight(serverURL, "/") + "/api/v4",
+	}, nil
+}
+
+// ListRepositories 列出当前用户参与的所有项目
+// affiliation 仅为 owner 时只列出自己拥有的项目
+func (c *Client) ListRepositories(ctx context.Context, opts github.RepoListOptions) ([]github.Repository, error) {
+	query := neturl.Values{}
+	query.Set("membership", "true")
+	query.Set("per_page", "100")
+	query.Set("order_by", "last_activity_at")
+	if opts.Visibility == "public" || opts.Visibility == "private" {
+		query.Set("visibility", opts.Visibility)
+	}
+	if opts.Affiliation == "owner" {
+		query.Set("owned", "true")
+	}
+	url := fmt.Sprintf("%s/projects?%s", c.baseURL, query.Encode())
+
+	projects, err := getAllPages[project](ctx, c, url, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	repositories := make([]github.Repository, 0, len(projects))
+	for i := range projects {
+		repositories = append(repositories, projects[i].toRepository())
+	}
+	return repositories, nil
+}
+
+// CreateRepository 在当前用户名下创建项目
+func (c *Client) CreateRepository(ctx context.Context, name, description string, isPrivate, autoInit bool) (*github.Repository, error) {
+	url := fmt.Sprintf("%s/projects", c.baseURL)
+
+	visibility := "public"
+	if isPrivate {
+		visibility = "private"
+	}
+	requestBody := struct {
+		Name                 string `json:"name"`
+		Path                 string `json:"path"`
+		Description          string `json:"description"`
+		Visibility           string `json:"visibility"`
+		InitializeWithReadme bool   `json:"initialize_with_readme"`
+	}{
+		Name:                 name,
+		Path:                 name,
+		Description:          description,
+		Visibility:           visibility,
+		InitializeWithReadme: autoInit,
+	}
+
+	var created project
+	if err := c.doJSON(ctx, "POST", url, requestBody, &created, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	repository := created.toRepository()
+	return &repository, nil
+}
+
+// ListFiles 列出目录内容
+func (c *Client) ListFiles(ctx context.Context, owner, repo, filePath, ref string) ([]github.FileEntry, error) {
+	query := neturl.Values{}
+	query.Set("per_page", "100")
+	if filePath = strings.Trim(filePath, "/"); filePath != "" {
+		query.Set("path", filePath)
+	}
+	if ref != "" {
+		query.Set("ref", ref)
+	}
+	url := fmt.Sprintf("%s/projects/%s/repository/tree?%s", c.baseURL, projectID(owner, repo), query.Encode())
+
+	entries, err := getAllPages[treeEntry](ctx, c, url, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]github.FileEntry, 0, len(entries))
+	for _, entry := range entries {
+		fileType := "file"
+		if entry.Type == "tree" {
+			fileType = "dir"
+		}
+		files = append(files, github.FileEntry{
+			Name: entry.Name,
+			Path: entry.Path,
+			SHA:  entry.ID,
+			Type: fileType,
+		})
+	}
+	return files, nil
+}
+
+// GetFileContent 读取文件内容
+func (c *Client) GetFileContent(ctx context.Context, owner, repo, filePath, ref string) (*github.FileEntry, error) {
+	file, err := c.getFile(ctx, owner, repo, filePath, ref)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, filePath)
+	}
+
+	return &github.FileEntry{
+		Name:     file.FileName,
+		Path:     file.FilePath,
+		SHA:      file.BlobID,
+		Size:     file.Size,
+		Type:     "file",
+		Content:  file.Content,
+		Encoding: file.Encoding,
+	}, nil
+}
+
+// GetFileSHA 获取文件当前的 blob SHA，文件不存在时返回空字符串
+func (c *Client) GetFileSHA(ctx context.Context, owner, repo, filePath, branch string) (string, error) {
+	file, err := c.getFile(ctx, owner, repo, filePath, branch)
+	if err != nil || file == nil {
+		return "", err
+	}
+	return file.BlobID, nil
+}
+
+// CreateOrUpdateFile 写入文件
+// GitLab 以 last_commit_id 做乐观锁，这里先比对 blob SHA 再带上对应的提交 ID
+func (c *Client) CreateOrUpdateFile(ctx context.Context, owner, repo, filePath, content, message, branch, sha string) (*github.FileEntry, error) {
+	branch, err := c.resolveBranch(ctx, owner, repo, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(content, "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: content is not valid base64: %v", storage.ErrInvalid, err)
+	}
+
+	requestBody := struct {
+		Branch        string `json:"branch"`
+		Content       string `json:"content"`
+		Encoding      string `json:"encoding"`
+		CommitMessage string `json:"commit_message"`
+		LastCommitID  string `json:"last_commit_id,omitempty"`
+	}{
+		Branch:        branch,
+		Content:       base64.StdEncoding.EncodeToString(data),
+		Encoding:      "base64",
+		CommitMessage: message,
+	}
+
+	method := "POST"
+	if sha != "" {
+		current, err := c.getFile(ctx, owner, repo, filePath, branch)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || current.BlobID != sha {
+			return nil, fmt.Errorf("%w: %s does not match %s", storage.ErrConflict, filePath, sha)
+		}
+		method = "PUT"
+		requestBody.LastCommitID = current.LastCommitID
+	}
+
+	if err := c.doJSON(ctx, method, c.fileURL(owner, repo, filePath, ""), requestBody, nil, http.StatusOK, http.StatusCreated); err != nil {
+		// GitLab 对已存在的文件返回 400 而不是 409
+		var apiErr *storage.APIError
+		if method == "POST" && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest &&
+			strings.Contains(apiErr.Message, "already exists") {
+			return nil, fmt.Errorf("%w: %w", storage.ErrConflict, err)
+		}
+		return nil, err
+	}
+
+	return &github.FileEntry{
+		Name: path.Base(filePath),
+		Path: filePath,
+		SHA:  blobSHA(data),
+		Size: len(data),
+		Type: "file",
+	}, nil
+}
+
+// DeleteFile 删除文件
+func (c *Client) DeleteFile(ctx context.Context, owner, repo, filePath, sha, message, branch string) error {
+	branch, err := c.resolveBranch(ctx, owner, repo, branch)
+	if err != nil {
+		return err
+	}
+
+	current, err := c.getFile(ctx, owner, repo, filePath, branch)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("%w: %s", storage.ErrNotFound, filePath)
+	}
+	if current.BlobID != sha {
+		return fmt.Errorf("%w: %s does not match %s", storage.ErrConflict, filePath, sha)
+	}
+
+	requestBody := struct {
+		Branch        string `json:"branch"`
+		CommitMessage string `json:"commit_message"`
+		LastCommitID  string `json:"last_commit_id"`
+	}{
+		Branch:        branch,
+		CommitMessage: message,
+		LastCommitID:  current.LastCommitID,
+	}
+
+	return c.doJSON(ctx, "DELETE", c.fileURL(owner, repo, filePath, ""), requestBody, nil, http.StatusOK, http.StatusNoContent)
+}
+
+// ListCommits 列出影响指定路径的提交历史，最多读取 maxCommitPages 页
+func (c *Client) ListCommits(ctx context.Context, owner, repo, filePath, ref string) ([]github.Commit, error) {
+	query := commitsQuery(filePath, ref)
+	url := fmt.Sprintf("%s/projects/%s/repository/commits?%s", c.baseURL, projectID(owner, repo), query.Encode())
+
+	entries, err := getAllPages[commitEntry](ctx, c, url, maxCommitPages)
+	if err != nil {
+		return nil, err
+	}
+	return toCommits(entries), nil
+}
+
+// ListCommitsPage 读取从第 offset 个提交开始的至多 limit 个提交，只请求覆盖这一段的上游分页
+func (c *Client) ListCommitsPage(ctx context.Context, owner, repo, filePath, ref string, offset, limit int) ([]github.Commit, bool, error) {
+	const perPage = 100
+	query := commitsQuery(filePath, ref)
+
+	return github.CollectPage(offset, limit, perPage, func(page int) ([]github.Commit, error) {
+		query.Set("page", strconv.Itoa(page))
+		url := fmt.Sprintf("%s/projects/%s/repository/commits?%s", c.baseURL, projectID(owner, repo), query.Encode())
+		var entries []commitEntry
+		if err := c.doJSON(ctx, "GET", url, nil, &entries, http.StatusOK); err != nil {
+			return nil, err
+		}
+		return toCommits(entries), nil
+	})
+}
+
+// commitsQuery 提交列表接口的查询参数
+func commitsQuery(filePath, ref string) neturl.Values {
+	query := neturl.Values{}
+	if filePath != "" {
+		query.Set("path", filePath)
+	}
+	if ref != "" {
+		query.Set("ref_name", ref)
+	}
+	query.Set("per_page", "100")
+	return query
+}
+
+// toCommits 将 GitLab 的提交转换为 GitHub 格式
+func toCommits(entries []commitEntry) []github.Commit {
+	commits := make([]github.Commit, 0, len(entries))
+	for _, entry := range entries {
+		var commit github.Commit
+		commit.SHA = entry.ID
+		commit.Commit.Author.Name = entry.AuthorName
+		commit.Commit.Author.Email = entry.AuthorEmail
+		commit.Commit.Author.Date = entry.AuthoredDate
+		commit.Commit.Message = entry.Message
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
+// getFile 读取文件元数据和内容，文件不存在时返回 nil
+func (c *Client) getFile(ctx context.Context, owner, repo, filePath, ref string) (*repositoryFile, error) {
+	ref, err := c.resolveBranch(ctx, owner, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, "GET", c.fileURL(owner, repo, filePath, ref), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, handleError(resp)
+	}
+
+	var file repositoryFile
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// DefaultBranch 返回项目的默认分支名
+func (c *Client) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	return c.resolveBranch(ctx, owner, repo, "")
+}
+
+// resolveBranch GitLab 的文件接口必须指定分支，为空时使用默认分支
+func (c *Client) resolveBranch(ctx context.Context, owner, repo, branch string) (string, error) {
+	if branch != "" {
+		return branch, nil
+	}
+
+	var p project
+	url := fmt.Sprintf("%s/projects/%s", c.baseURL, projectID(owner, repo))
+	if err := c.doJSON(ctx, "GET", url, nil, &p, http.StatusOK); err != nil {
+		return "", err
+	}
+	return p.DefaultBranch, nil
+}
+
+// fileURL 构造文件接口地址
+func (c *Client) fileURL(owner, repo, filePath, ref string) string {
+	url := fmt.Sprintf("%s/projects/%s/repository/files/%s", c.baseURL, projectID(owner, repo),
+		neturl.PathEscape(strings.Trim(filePath, "/")))
+	if ref != "" {
+		url += "?ref=" + neturl.QueryEscape(ref)
+	}
+	return url
+}
+
+// newRequest 创建带认证头的请求
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "GitNetDisk")
+	if c.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", c.token)
+	}
+	return req, nil
+}
+
+// doJSON 发送 JSON 请求并解析响应，expected 为可接受的状态码
+func (c *Client) doJSON(ctx context.Context, method, url string, in, out interface{}, expected ...int) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := c.newRequest(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			if out == nil {
+				return nil
+			}
+			return json.NewDecoder(resp.Body).Decode(out)
+		}
+	}
+	return handleError(resp)
+}
+
+// getAllPages 沿 Link 头的 next 链接读取所有分页，maxPages 为 0 时不限制页数
+func getAllPages[T any](ctx context.Context, c *Client, url string, maxPages int) ([]T, error) {
+	items := []T{}
+	for pages := 0; url != "" && (maxPages == 0 || pages < maxPages); pages++ {
+		req, err := c.newRequest(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := handleError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var page []T
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page...)
+		url = github.NextPageURL(resp.Header.Get("Link"))
+	}
+	return items, nil
+}
+
+// toRepository 转换为 GitHub 仓库结构
+func (p *project) toRepository() github.Repository {
+	return github.Repository{
+		ID:          p.ID,
+		Name:        p.Path,
+		FullName:    p.PathWithNamespace,
+		Description: p.Description,
+		Private:     p.Visibility != "public",
+		Owner: github.Owner{
+			Login:     p.Namespace.Path,
+			ID:        p.Namespace.ID,
+			AvatarURL: p.Namespace.AvatarURL,
+		},
+		HTMLURL:       p.WebURL,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.LastActivityAt,
+		DefaultBranch: p.DefaultBranch,
+	}
+}
+
+// projectID 使用 URL 编码的 "owner/repo" 作为项目 ID
+func projectID(owner, repo string) string {
+	return neturl.PathEscape(owner + "/" + repo)
+}
+
+// blobSHA 计算与 git hash-object 相同的 blob SHA
+func blobSHA(data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// handleError 将错误响应转换为 *storage.APIError，GitLab 的 message 可能是字符串或对象
+func handleError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	var errorResponse struct {
+		Message interface{} `json:"message"`
+		Error   string      `json:"error"`
+	}
+	apiErr := &storage.APIError{Backend: "GitLab", StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	if err := json.Unmarshal(body, &errorResponse); err == nil {
+		if msg, ok := errorResponse.Message.(string); ok && msg != "" {
+			apiErr.Message = msg
+		} else if errorResponse.Message != nil {
+			apiErr.Message = fmt.Sprintf("%v", errorResponse.Message)
+		} else if errorResponse.Error != "" {
+			apiErr.Message = errorResponse.Error
+		}
+	}
+	return apiErr
+}
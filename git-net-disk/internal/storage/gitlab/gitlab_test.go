@@ -0,0 +1,180 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git-net-disk/internal/proxy"
+	"git-net-disk/internal/storage"
+)
+
+// newTestClient 创建指向 httptest 服务器的客户端
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL, "secret", proxy.ProxyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// writeJSON 写出 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// fileResponse 模拟文件接口返回 blob_id 为 current 的文件
+func fileResponse(w http.ResponseWriter, current string) {
+	writeJSON(w, http.StatusOK, repositoryFile{
+		FileName:     "a.txt",
+		FilePath:     "a.txt",
+		Encoding:     "base64",
+		Content:      "aGk=",
+		BlobID:       current,
+		LastCommitID: "c1",
+	})
+}
+
+func TestHandleErrorReturnsTypedErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		target error
+	}{
+		{http.StatusNotFound, storage.ErrNotFound},
+		{http.StatusConflict, storage.ErrConflict},
+		{http.StatusForbidden, storage.ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.status, map[string]string{"message": "upstream says no"})
+			})
+
+			_, err := client.ListFiles(context.Background(), "alice", "drive", "", "main")
+			if !errors.Is(err, tt.target) {
+				t.Fatalf("err = %v, want %v", err, tt.target)
+			}
+			var apiErr *storage.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Message != "upstream says no" {
+				t.Fatalf("err = %#v, want *storage.APIError with status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandleErrorMessageObject(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"message": map[string][]string{"name": {"has already been taken"}}})
+	})
+
+	_, err := client.CreateRepository(context.Background(), "drive", "", true, false)
+	var apiErr *storage.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || !strings.Contains(apiErr.Message, "has already been taken") {
+		t.Fatalf("err = %#v", err)
+	}
+}
+
+func TestGetFileContentMissingFile(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "404 File Not Found"})
+	})
+
+	_, err := client.GetFileContent(context.Background(), "alice", "drive", "a.txt", "main")
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCreateFileThatAlreadyExists(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "A file with this name already exists"})
+	})
+
+	_, err := client.CreateOrUpdateFile(context.Background(), "alice", "drive", "a.txt", "aGk=", "add", "main", "")
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("err = %v, want ErrConflict", err)
+	}
+}
+
+func TestUpdateFileWithStaleSHA(t *testing.T) {
+	writes := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writes++
+		}
+		fileResponse(w, "current")
+	})
+
+	ctx := context.Background()
+	if _, err := client.CreateOrUpdateFile(ctx, "alice", "drive", "a.txt", "aGk=", "update", "main", "stale"); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("update err = %v, want ErrConflict", err)
+	}
+	if err := client.DeleteFile(ctx, "alice", "drive", "a.txt", "stale", "remove", "main"); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("delete err = %v, want ErrConflict", err)
+	}
+	if writes != 0 {
+		t.Fatalf("stale writes reached GitLab %d times", writes)
+	}
+}
+
+func TestDeleteFileSendsLastCommitID(t *testing.T) {
+	var body struct {
+		Branch       string `json:"branch"`
+		LastCommitID string `json:"last_commit_id"`
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			t.Errorf("PRIVATE-TOKEN = %q", r.Header.Get("PRIVATE-TOKEN"))
+		}
+		if r.Method == "GET" {
+			fileResponse(w, "current")
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := client.DeleteFile(context.Background(), "alice", "drive", "a.txt", "current", "remove", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if body.Branch != "main" || body.LastCommitID != "c1" {
+		t.Fatalf("delete body = %+v", body)
+	}
+}
+
+func TestListFilesFollowsLinkHeader(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=2>; rel="next"`, server.URL, r.URL.EscapedPath()))
+			fmt.Fprint(w, `[{"id":"1","name":"docs","type":"tree","path":"docs"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"id":"2","name":"a.txt","type":"blob","path":"a.txt"}]`)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "secret", proxy.ProxyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := client.ListFiles(context.Background(), "alice", "drive", "", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Type != "dir" || files[1].Type != "file" {
+		t.Fatalf("files = %+v", files)
+	}
+}
@@ -0,0 +1,706 @@
+package localgit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/storage"
+)
+
+// 编译期检查 Backend 实现了 storage.Backend
+var (
+	_ storage.Backend               = (*Backend)(nil)
+	_ storage.CommitPager           = (*Backend)(nil)
+	_ storage.DefaultBranchResolver = (*Backend)(nil)
+)
+
+// namePattern 合法的所有者和仓库名
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+// modeFile 新文件的树条目模式
+const modeFile = "100644"
+
+// defaultDescription git init 写入的默认描述
+const defaultDescription = "Unnamed repository; edit this file 'description' to name the repository."
+
+// Backend 基于本地裸仓库的存储后端，通过 git 命令行操作
+// 仓库按 <root>/<owner>/<repo>.git 布局存放
+type Backend struct {
+	root  string
+	owner string // 新建仓库的所有者
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex // 每个仓库的写锁
+}
+
+// New 创建本地 git 存储后端
+func New(root, owner string) (*Backend, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git executable not found: %v", err)
+	}
+	if !namePattern.MatchString(owner) {
+		return nil, fmt.Errorf("invalid owner name: %s", owner)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Backend{
+		root:  root,
+		owner: owner,
+		locks: make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// ListRepositories 列出根目录下的所有仓库，本地仓库没有可见性区分，opts 被忽略
+func (b *Backend) ListRepositories(ctx context.Context, opts github.RepoListOptions) ([]github.Repository, error) {
+	owners, err := os.ReadDir(b.root)
+	if err != nil {
+		return nil, err
+	}
+
+	repositories := []github.Repository{}
+	for _, ownerDir := range owners {
+		if !ownerDir.IsDir() || !namePattern.MatchString(ownerDir.Name()) {
+			continue
+		}
+		repos, err := os.ReadDir(filepath.Join(b.root, ownerDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, repoDir := range repos {
+			if !repoDir.IsDir() || !strings.HasSuffix(repoDir.Name(), ".git") {
+				continue
+			}
+			repository, err := b.repository(ctx, ownerDir.Name(), strings.TrimSuffix(repoDir.Name(), ".git"))
+			if err != nil {
+				return nil, err
+			}
+			repositories = append(repositories, *repository)
+		}
+	}
+
+	return repositories, nil
+}
+
+// CreateRepository 创建裸仓库，autoInit 时提交一个 README
+func (b *Backend) CreateRepository(ctx context.Context, name, description string, isPrivate, autoInit bool) (*github.Repository, error) {
+	if !namePattern.MatchString(name) || strings.HasSuffix(name, ".git") {
+		return nil, fmt.Errorf("%w: invalid repository name %s", storage.ErrInvalid, name)
+	}
+
+	dir := filepath.Join(b.root, b.owner, name+".git")
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("%w: repository %s/%s already exists", storage.ErrConflict, b.owner, name)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return nil, err
+	}
+
+	if out, err := exec.CommandContext(ctx, "git", "init", "--bare", "-q", "-b", "main", dir).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git init: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "description"), []byte(description+"\n"), 0o644); err != nil {
+		return nil, err
+	}
+
+	if autoInit {
+		readme := fmt.Sprintf("# %s\n\n%s\n", name, description)
+		_, err := b.commitChange(ctx, dir, "main", "Initial commit", func(env []string) error {
+			_, err := b.stageFile(ctx, dir, env, "README.md", modeFile, []byte(readme))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b.repository(ctx, b.owner, name)
+}
+
+// ListFiles 列出目录内容
+func (b *Backend) ListFiles(ctx context.Context, owner, repo, filePath, ref string) ([]github.FileEntry, error) {
+	dir, err := b.repoDir(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = cleanPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := b.resolveCommit(ctx, dir, refOrHead(ref))
+	if err != nil {
+		// 空仓库没有任何文件
+		if ref == "" && filePath == "" && errors.Is(err, storage.ErrNotFound) {
+			return []github.FileEntry{}, nil
+		}
+		return nil, err
+	}
+
+	if filePath != "" {
+		entry, found, err := b.treeEntry(ctx, dir, commit, filePath)
+		if err != nil {
+			return nil, err
+		}
+		if !found || entry.objType != "tree" {
+			return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, filePath)
+		}
+	}
+
+	out, err := b.git(ctx, dir, nil, nil, "ls-tree", "-l", "-z", "--end-of-options", commit+":"+filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []github.FileEntry{}
+	for _, record := range strings.Split(string(out), "\x00") {
+		meta, name, ok := strings.Cut(record, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 {
+			continue
+		}
+
+		entry := github.FileEntry{
+			Name: name,
+			Path: path.Join(filePath, name),
+			SHA:  fields[2],
+			Type: "file",
+		}
+		if fields[1] == "tree" {
+			entry.Type = "dir"
+		} else {
+			entry.Size, _ = strconv.Atoi(fields[3])
+		}
+		files = append(files, entry)
+	}
+
+	return files, nil
+}
+
+// GetFileContent 读取文件内容
+func (b *Backend) GetFileContent(ctx context.Context, owner, repo, filePath, ref string) (*github.FileEntry, error) {
+	dir, err := b.repoDir(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = cleanPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := b.resolveCommit(ctx, dir, refOrHead(ref))
+	if err != nil {
+		return nil, err
+	}
+	sha, err := b.blobSHA(ctx, dir, commit, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if sha == "" {
+		return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, filePath)
+	}
+
+	content, err := b.git(ctx, dir, nil, nil, "cat-file", "blob", sha)
+	if err != nil {
+		return nil, err
+	}
+
+	return &github.FileEntry{
+		Name:     path.Base(filePath),
+		Path:     filePath,
+		SHA:      sha,
+		Size:     len(content),
+		Type:     "file",
+		Content:  base64.StdEncoding.EncodeToString(content),
+		Encoding: "base64",
+	}, nil
+}
+
+// GetFileSHA 获取文件当前的 blob SHA，文件不存在时返回空字符串
+func (b *Backend) GetFileSHA(ctx context.Context, owner, repo, filePath, branch string) (string, error) {
+	dir, err := b.repoDir(owner, repo)
+	if err != nil {
+		return "", err
+	}
+	filePath, err = cleanPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	return b.blobSHA(ctx, dir, branchRef(branch), filePath)
+}
+
+// CreateOrUpdateFile 写入文件并提交
+func (b *Backend) CreateOrUpdateFile(ctx context.Context, owner, repo, filePath, content, message, branch, sha string) (*github.FileEntry, error) {
+	dir, err := b.repoDir(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = cleanPath(filePath)
+	if err != nil || filePath == "" {
+		return nil, fmt.Errorf("%w: invalid path %s", storage.ErrInvalid, filePath)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(content, "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: content is not valid base64: %v", storage.ErrInvalid, err)
+	}
+
+	var blob string
+	_, err = b.commitChange(ctx, dir, branch, message, func(env []string) error {
+		// 与 GitHub 保持一致的乐观并发检查
+		current, err := b.blobSHA(ctx, dir, branchRef(branch), filePath)
+		if err != nil {
+			return err
+		}
+		if current != "" && sha == "" {
+			return fmt.Errorf(`%w: %s already exists and "sha" wasn't supplied`, storage.ErrConflict, filePath)
+		}
+		if current != "" && sha != current {
+			return fmt.Errorf("%w: %s does not match %s", storage.ErrConflict, filePath, sha)
+		}
+
+		// 已有文件沿用原来的模式，可执行文件和符号链接更新后保持不变
+		mode := modeFile
+		if current != "" {
+			entry, _, err := b.treeEntry(ctx, dir, branchRef(branch), filePath)
+			if err != nil {
+				return err
+			}
+			mode = entry.mode
+		}
+		blob, err = b.stageFile(ctx, dir, env, filePath, mode, data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &github.FileEntry{
+		Name: path.Base(filePath),
+		Path: filePath,
+		SHA:  blob,
+		Size: len(data),
+		Type: "file",
+	}, nil
+}
+
+// DeleteFile 删除文件并提交
+func (b *Backend) DeleteFile(ctx context.Context, owner, repo, filePath, sha, message, branch string) error {
+	dir, err := b.repoDir(owner, repo)
+	if err != nil {
+		return err
+	}
+	filePath, err = cleanPath(filePath)
+	if err != nil || filePath == "" {
+		return fmt.Errorf("%w: invalid path %s", storage.ErrInvalid, filePath)
+	}
+
+	_, err = b.commitChange(ctx, dir, branch, message, func(env []string) error {
+		current, err := b.blobSHA(ctx, dir, branchRef(branch), filePath)
+		if err != nil {
+			return err
+		}
+		if current == "" {
+			return fmt.Errorf("%w: %s", storage.ErrNotFound, filePath)
+		}
+		if sha != current {
+			return fmt.Errorf("%w: %s does not match %s", storage.ErrConflict, filePath, sha)
+		}
+		// 裸仓库没有工作区，--force-remove 不可用；--index-info 中模式为 0 的条目表示删除
+		// 以 NUL 结尾，路径中的任何字符都不会被当作下一条记录
+		_, err = b.git(ctx, dir, env, []byte("0 "+strings.Repeat("0", 40)+"\t"+filePath+"\x00"), "update-index", "-z", "--index-info")
+		return err
+	})
+	return err
+}
+
+// ListCommits 列出影响指定路径的最近 100 个提交
+func (b *Backend) ListCommits(ctx context.Context, owner, repo, filePath, ref string) ([]github.Commit, error) {
+	commits, _, err := b.ListCommitsPage(ctx, owner, repo, filePath, ref, 0, 100)
+	return commits, err
+}
+
+// ListCommitsPage 列出影响指定路径的提交，跳过前 offset 个，至多返回 limit 个
+func (b *Backend) ListCommitsPage(ctx context.Context, owner, repo, filePath, ref string, offset, limit int) ([]github.Commit, bool, error) {
+	dir, err := b.repoDir(owner, repo)
+	if err != nil {
+		return nil, false, err
+	}
+	filePath, err = cleanPath(filePath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	commit, err := b.resolveCommit(ctx, dir, refOrHead(ref))
+	if err != nil {
+		// 空仓库没有历史
+		if ref == "" && errors.Is(err, storage.ErrNotFound) {
+			return []github.Commit{}, false, nil
+		}
+		return nil, false, err
+	}
+
+	// 多读一个提交以判断之后是否还有
+	args := []string{"log", "--skip", strconv.Itoa(offset), "-n", strconv.Itoa(limit + 1),
+		"--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%B%x1e", "--end-of-options", commit, "--"}
+	if filePath != "" {
+		args = append(args, filePath)
+	}
+	out, err := b.git(ctx, dir, []string{"GIT_LITERAL_PATHSPECS=1"}, nil, args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	commits := []github.Commit{}
+	for _, record := range strings.Split(string(out), "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		var commit github.Commit
+		commit.SHA = fields[0]
+		commit.Commit.Author.Name = fields[1]
+		commit.Commit.Author.Email = fields[2]
+		commit.Commit.Author.Date, _ = time.Parse(time.RFC3339, fields[3])
+		commit.Commit.Message = strings.TrimSpace(fields[4])
+		commits = append(commits, commit)
+	}
+
+	if len(commits) > limit {
+		return commits[:limit], true, nil
+	}
+	return commits, false, nil
+}
+
+// DefaultBranch 返回仓库 HEAD 指向的分支名
+func (b *Backend) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	dir, err := b.repoDir(owner, repo)
+	if err != nil {
+		return "", err
+	}
+	out, err := b.git(ctx, dir, nil, nil, "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// repository 读取仓库元信息
+func (b *Backend) repository(ctx context.Context, owner, name string) (*github.Repository, error) {
+	dir, err := b.repoDir(owner, name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	repository := &github.Repository{
+		ID:        repoID(owner + "/" + name),
+		Name:      name,
+		FullName:  owner + "/" + name,
+		Private:   true,
+		Owner:     github.Owner{Login: owner},
+		CreatedAt: info.ModTime(),
+		UpdatedAt: info.ModTime(),
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "description")); err == nil {
+		if desc := strings.TrimSpace(string(data)); desc != defaultDescription {
+			repository.Description = desc
+		}
+	}
+	if branch, err := b.DefaultBranch(ctx, owner, name); err == nil {
+		repository.DefaultBranch = branch
+	}
+	if out, err := b.git(ctx, dir, nil, nil, "log", "-1", "--format=%cI"); err == nil {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(out))); err == nil {
+			repository.UpdatedAt = t
+		}
+	}
+	if out, err := b.git(ctx, dir, nil, nil, "count-objects", "-v"); err == nil {
+		repository.Size = objectsSizeKB(string(out))
+	}
+
+	return repository, nil
+}
+
+// commitChange 在临时索引上应用修改并提交到分支
+func (b *Backend) commitChange(ctx context.Context, dir, branch, message string, apply func(env []string) error) (string, error) {
+	lock := b.repoLock(dir)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if branch == "" {
+		out, err := b.git(ctx, dir, nil, nil, "symbolic-ref", "--short", "HEAD")
+		if err != nil {
+			return "", err
+		}
+		branch = strings.TrimSpace(string(out))
+	}
+	ref := "refs/heads/" + branch
+	if _, err := b.git(ctx, dir, nil, nil, "check-ref-format", ref); err != nil {
+		return "", fmt.Errorf("%w: invalid branch name %s", storage.ErrInvalid, branch)
+	}
+
+	parent := ""
+	if out, err := b.git(ctx, dir, nil, nil, "rev-parse", "-q", "--verify", "--end-of-options", ref); err == nil {
+		parent = strings.TrimSpace(string(out))
+	}
+
+	tmpDir, err := os.MkdirTemp("", "gitnetdisk-index-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	env := append(identityEnv(), "GIT_INDEX_FILE="+filepath.Join(tmpDir, "index"))
+
+	if parent != "" {
+		if _, err := b.git(ctx, dir, env, nil, "read-tree", parent); err != nil {
+			return "", err
+		}
+	}
+	if err := apply(env); err != nil {
+		return "", err
+	}
+
+	out, err := b.git(ctx, dir, env, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	tree := strings.TrimSpace(string(out))
+
+	args := []string{"commit-tree", tree, "-m", message}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	out, err = b.git(ctx, dir, env, nil, args...)
+	if err != nil {
+		return "", err
+	}
+	commit := strings.TrimSpace(string(out))
+
+	// 带上旧值，防止绕过锁的并发写入被覆盖
+	if _, err := b.git(ctx, dir, env, nil, "update-ref", ref, commit, parent); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// stageFile 写入 blob 并以指定模式加入临时索引，返回 blob SHA
+func (b *Backend) stageFile(ctx context.Context, dir string, env []string, filePath, mode string, data []byte) (string, error) {
+	blob, err := b.hashObject(ctx, dir, data)
+	if err != nil {
+		return "", err
+	}
+	_, err = b.git(ctx, dir, env, nil, "update-index", "--add", "--cacheinfo", mode+","+blob+","+filePath)
+	return blob, err
+}
+
+// hashObject 将内容写入对象库并返回 blob SHA
+func (b *Backend) hashObject(ctx context.Context, dir string, data []byte) (string, error) {
+	out, err := b.git(ctx, dir, nil, data, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// resolveCommit 把请求中的 ref 解析为提交 SHA，后续命令只使用解析出的 SHA
+// 以 - 开头的 ref 会被 git 当作选项，直接拒绝
+func (b *Backend) resolveCommit(ctx context.Context, dir, ref string) (string, error) {
+	if strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("%w: invalid ref %s", storage.ErrInvalid, ref)
+	}
+	out, err := b.git(ctx, dir, nil, nil, "rev-parse", "-q", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: ref %s", storage.ErrNotFound, ref)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// blobSHA 获取 ref 中文件的 blob SHA，不存在或不是文件时返回空字符串
+func (b *Backend) blobSHA(ctx context.Context, dir, ref, filePath string) (string, error) {
+	entry, found, err := b.treeEntry(ctx, dir, ref, filePath)
+	if err != nil || !found || entry.objType != "blob" {
+		return "", err
+	}
+	return entry.sha, nil
+}
+
+// treeEntry ls-tree 输出的一个条目
+type treeEntry struct {
+	mode    string
+	objType string
+	sha     string
+}
+
+// treeEntry 获取 ref 中路径对应的条目，ref 或路径不存在时 found 为 false
+func (b *Backend) treeEntry(ctx context.Context, dir, ref, filePath string) (entry treeEntry, found bool, err error) {
+	if strings.HasPrefix(ref, "-") {
+		return treeEntry{}, false, fmt.Errorf("%w: invalid ref %s", storage.ErrInvalid, ref)
+	}
+	// 空仓库或分支尚不存在
+	if _, err := b.git(ctx, dir, nil, nil, "rev-parse", "-q", "--verify", "--end-of-options", ref+"^{commit}"); err != nil {
+		if ctx.Err() != nil {
+			return treeEntry{}, false, err
+		}
+		return treeEntry{}, false, nil
+	}
+
+	// 路径按字面匹配，不展开通配符
+	out, err := b.git(ctx, dir, []string{"GIT_LITERAL_PATHSPECS=1"}, nil, "ls-tree", "-z", "--end-of-options", ref, "--", filePath)
+	if err != nil {
+		return treeEntry{}, false, err
+	}
+	for _, record := range strings.Split(string(out), "\x00") {
+		meta, name, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(meta)
+		if !ok || name != filePath || len(fields) != 3 {
+			continue
+		}
+		return treeEntry{mode: fields[0], objType: fields[1], sha: fields[2]}, true, nil
+	}
+	return treeEntry{}, false, nil
+}
+
+// git 在指定裸仓库中执行 git 命令
+func (b *Backend) git(ctx context.Context, dir string, env []string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", dir}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// 上下文结束时 git 被终止，返回上下文的错误而不是 signal: killed
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("git %s: %w", args[0], ctx.Err())
+		}
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// repoDir 返回仓库目录，仓库不存在时返回错误
+func (b *Backend) repoDir(owner, repo string) (string, error) {
+	if !namePattern.MatchString(owner) || !namePattern.MatchString(repo) {
+		return "", fmt.Errorf("%w: repository %s/%s", storage.ErrNotFound, owner, repo)
+	}
+	dir := filepath.Join(b.root, owner, repo+".git")
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%w: repository %s/%s", storage.ErrNotFound, owner, repo)
+	}
+	return dir, nil
+}
+
+// repoLock 返回仓库的写锁
+func (b *Backend) repoLock(dir string) *sync.Mutex {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lock, ok := b.locks[dir]
+	if !ok {
+		lock = &sync.Mutex{}
+		b.locks[dir] = lock
+	}
+	return lock
+}
+
+// cleanPath 规范化仓库内路径并拒绝越界路径和含控制字符的路径
+func cleanPath(filePath string) (string, error) {
+	filePath = strings.Trim(filePath, "/")
+	if filePath == "" {
+		return "", nil
+	}
+	if strings.ContainsFunc(filePath, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return "", fmt.Errorf("%w: invalid path %q", storage.ErrInvalid, filePath)
+	}
+	for _, part := range strings.Split(filePath, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("%w: invalid path %s", storage.ErrInvalid, filePath)
+		}
+	}
+	return filePath, nil
+}
+
+// refOrHead 空 ref 表示默认分支
+func refOrHead(ref string) string {
+	if ref == "" {
+		return "HEAD"
+	}
+	return ref
+}
+
+// branchRef 将分支名转为完整引用，空分支表示默认分支
+func branchRef(branch string) string {
+	if branch == "" {
+		return "HEAD"
+	}
+	return "refs/heads/" + branch
+}
+
+// identityEnv 提交使用的作者信息
+func identityEnv() []string {
+	name := os.Getenv("GIT_AUTHOR_NAME")
+	if name == "" {
+		name = "GitNetDisk"
+	}
+	email := os.Getenv("GIT_AUTHOR_EMAIL")
+	if email == "" {
+		email = "gitnetdisk@localhost"
+	}
+	return []string{
+		"GIT_AUTHOR_NAME=" + name,
+		"GIT_AUTHOR_EMAIL=" + email,
+		"GIT_COMMITTER_NAME=" + name,
+		"GIT_COMMITTER_EMAIL=" + email,
+	}
+}
+
+// repoID 根据仓库全名生成稳定的 ID
+func repoID(fullName string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(fullName))
+	return int64(h.Sum64() >> 1)
+}
+
+// objectsSizeKB 从 count-objects -v 的输出中累加对象大小（KB）
+func objectsSizeKB(output string) int {
+	total := 0
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok || (key != "size" && key != "size-pack") {
+			continue
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(value))
+		total += n
+	}
+	return total
+}
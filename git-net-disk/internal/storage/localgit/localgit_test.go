@@ -0,0 +1,241 @@
+package localgit
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"git-net-disk/internal/storage"
+)
+
+// newTestBackend 在临时目录中创建后端和一个带 README 的仓库 local/drive
+func newTestBackend(t *testing.T) *Backend {
+	t.Helper()
+	b, err := New(t.TempDir(), "local")
+	if err != nil {
+		t.Skipf("local git backend unavailable: %v", err)
+	}
+	if _, err := b.CreateRepository(context.Background(), "drive", "test drive", true, true); err != nil {
+		t.Fatalf("CreateRepository: %v", err)
+	}
+	return b
+}
+
+// encode 返回内容的 base64 编码
+func encode(content string) string {
+	return base64.StdEncoding.EncodeToString([]byte(content))
+}
+
+// mode 返回默认分支上文件的模式
+func mode(t *testing.T, b *Backend, filePath string) string {
+	t.Helper()
+	dir, err := b.repoDir("local", "drive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, found, err := b.treeEntry(context.Background(), dir, "HEAD", filePath)
+	if err != nil || !found {
+		t.Fatalf("treeEntry(%s) = %v, %v", filePath, found, err)
+	}
+	return entry.mode
+}
+
+func TestCreateUpdateDelete(t *testing.T) {
+	ctx := context.Background()
+	b := newTestBackend(t)
+
+	created, err := b.CreateOrUpdateFile(ctx, "local", "drive", "docs/a.txt", encode("one"), "add", "", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		write   func(sha string) error
+		sha     string
+		wantErr error
+	}{
+		{"create existing without sha", func(string) error {
+			_, err := b.CreateOrUpdateFile(ctx, "local", "drive", "docs/a.txt", encode("two"), "again", "", "")
+			return err
+		}, "", storage.ErrConflict},
+		{"update with stale sha", func(string) error {
+			_, err := b.CreateOrUpdateFile(ctx, "local", "drive", "docs/a.txt", encode("two"), "update", "", "0123456789012345678901234567890123456789")
+			return err
+		}, "", storage.ErrConflict},
+		{"update with invalid content", func(sha string) error {
+			_, err := b.CreateOrUpdateFile(ctx, "local", "drive", "docs/a.txt", "not base64!", "update", "", sha)
+			return err
+		}, created.SHA, storage.ErrInvalid},
+		{"delete with stale sha", func(string) error {
+			return b.DeleteFile(ctx, "local", "drive", "docs/a.txt", "0123456789012345678901234567890123456789", "remove", "")
+		}, "", storage.ErrConflict},
+		{"delete missing file", func(string) error {
+			return b.DeleteFile(ctx, "local", "drive", "docs/missing.txt", created.SHA, "remove", "")
+		}, "", storage.ErrNotFound},
+		{"write to missing repository", func(string) error {
+			_, err := b.CreateOrUpdateFile(ctx, "local", "missing", "a.txt", encode("x"), "add", "", "")
+			return err
+		}, "", storage.ErrNotFound},
+	}
+	for _, tt := range tests {
+		if err := tt.write(tt.sha); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	updated, err := b.CreateOrUpdateFile(ctx, "local", "drive", "docs/a.txt", encode("two"), "update", "", created.SHA)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	file, err := b.GetFileContent(ctx, "local", "drive", "docs/a.txt", "")
+	if err != nil || file.SHA != updated.SHA || file.Content != encode("two") {
+		t.Fatalf("GetFileContent = %+v, %v", file, err)
+	}
+	if sha, err := b.GetFileSHA(ctx, "local", "drive", "docs/a.txt", "main"); err != nil || sha != updated.SHA {
+		t.Fatalf("GetFileSHA = %q, %v, want %q", sha, err, updated.SHA)
+	}
+
+	commits, err := b.ListCommits(ctx, "local", "drive", "docs/a.txt", "")
+	if err != nil || len(commits) != 2 || commits[0].Commit.Message != "update" {
+		t.Fatalf("ListCommits = %+v, %v", commits, err)
+	}
+
+	if err := b.DeleteFile(ctx, "local", "drive", "docs/a.txt", updated.SHA, "remove", ""); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := b.GetFileContent(ctx, "local", "drive", "docs/a.txt", ""); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetFileContent after delete = %v, want ErrNotFound", err)
+	}
+	if sha, err := b.GetFileSHA(ctx, "local", "drive", "docs/a.txt", ""); err != nil || sha != "" {
+		t.Fatalf("GetFileSHA after delete = %q, %v", sha, err)
+	}
+	if _, err := b.GetFileContent(ctx, "local", "drive", "README.md", ""); err != nil {
+		t.Fatalf("README.md after delete: %v", err)
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	ctx := context.Background()
+	b := newTestBackend(t)
+
+	for _, p := range []string{"docs/a.txt", "docs/sub/b.txt", "docs/50% off #1?.txt"} {
+		if _, err := b.CreateOrUpdateFile(ctx, "local", "drive", p, encode(p), "add "+p, "", ""); err != nil {
+			t.Fatalf("create %s: %v", p, err)
+		}
+	}
+
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr error
+	}{
+		{"", []string{"README.md:file", "docs:dir"}, nil},
+		{"docs", []string{"docs/50% off #1?.txt:file", "docs/a.txt:file", "docs/sub:dir"}, nil},
+		{"/docs/sub/", []string{"docs/sub/b.txt:file"}, nil},
+		{"missing", nil, storage.ErrNotFound},
+		{"docs/a.txt", nil, storage.ErrNotFound},
+		{"docs/../..", nil, storage.ErrInvalid},
+	}
+	for _, tt := range tests {
+		files, err := b.ListFiles(ctx, "local", "drive", tt.path, "")
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ListFiles(%q) err = %v, want %v", tt.path, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ListFiles(%q): %v", tt.path, err)
+			continue
+		}
+		var got []string
+		for _, f := range files {
+			got = append(got, f.Path+":"+f.Type)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ListFiles(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// 请求被取消不是 404
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := b.ListFiles(canceled, "local", "drive", "docs", ""); err == nil || errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("ListFiles with canceled context err = %v, want a non-404 error", err)
+	}
+}
+
+func TestRejectsInvalidPaths(t *testing.T) {
+	ctx := context.Background()
+	b := newTestBackend(t)
+	readme, err := b.GetFileSHA(ctx, "local", "drive", "README.md", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{
+		"",
+		"/",
+		"../escape.txt",
+		"docs/../../escape.txt",
+		"docs/./a.txt",
+		"docs//a.txt",
+		"a.txt\n0 0000000000000000000000000000000000000000\tREADME.md",
+		"tab\tname.txt",
+		"nul\x00name.txt",
+		"del\x7fname.txt",
+	} {
+		if _, err := b.CreateOrUpdateFile(ctx, "local", "drive", p, encode("x"), "add", "", ""); !errors.Is(err, storage.ErrInvalid) {
+			t.Errorf("CreateOrUpdateFile(%q) err = %v, want ErrInvalid", p, err)
+		}
+		if err := b.DeleteFile(ctx, "local", "drive", p, readme, "remove", ""); !errors.Is(err, storage.ErrInvalid) {
+			t.Errorf("DeleteFile(%q) err = %v, want ErrInvalid", p, err)
+		}
+	}
+
+	if sha, err := b.GetFileSHA(ctx, "local", "drive", "README.md", ""); err != nil || sha != readme {
+		t.Fatalf("README.md changed by a rejected path: %q, %v", sha, err)
+	}
+	if _, err := b.GetFileContent(ctx, "local", "drive", "README.md", "--output=/tmp/x"); !errors.Is(err, storage.ErrInvalid) {
+		t.Fatalf("option-like ref err = %v, want ErrInvalid", err)
+	}
+}
+
+func TestUpdateKeepsFileMode(t *testing.T) {
+	ctx := context.Background()
+	b := newTestBackend(t)
+	dir, err := b.repoDir("local", "drive")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 直接提交一个可执行文件，模拟从其他客户端推送的仓库
+	_, err = b.commitChange(ctx, dir, "", "add run.sh", func(env []string) error {
+		_, err := b.stageFile(ctx, dir, env, "bin/run.sh", "100755", []byte("#!/bin/sh\n"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha, err := b.GetFileSHA(ctx, "local", "drive", "bin/run.sh", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.CreateOrUpdateFile(ctx, "local", "drive", "bin/run.sh", encode("#!/bin/sh\necho hi\n"), "update", "", sha); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got := mode(t, b, "bin/run.sh"); got != "100755" {
+		t.Fatalf("bin/run.sh mode after update = %s, want 100755", got)
+	}
+
+	if _, err := b.CreateOrUpdateFile(ctx, "local", "drive", "bin/new.txt", encode("new"), "add", "", ""); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got := mode(t, b, "bin/new.txt"); got != modeFile {
+		t.Fatalf("bin/new.txt mode = %s, want %s", got, modeFile)
+	}
+}
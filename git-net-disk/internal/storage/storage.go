@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"git-net-disk/internal/github"
+)
+
+// 后端返回的哨兵错误，与 GitHub 客户端共用，ErrorMiddleware 据此返回 404、409、403 和 400
+var (
+	ErrNotFound  = github.ErrNotFound
+	ErrConflict  = github.ErrConflict
+	ErrForbidden = github.ErrForbidden
+	ErrInvalid   = github.ErrInvalid
+)
+
+// APIError Gitea、GitLab 等后端接口返回的错误响应，保留上游状态码
+type APIError struct {
+	// Backend 后端名称，例如 Gitea 或 GitLab
+	Backend string
+	// StatusCode 上游返回的 HTTP 状态码
+	StatusCode int
+	// Message 上游返回的错误信息，响应体无法解析时为原始内容
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s API error: %d %s", e.Backend, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s API error: %s", e.Backend, e.Message)
+}
+
+// Is 让 errors.Is 按状态码把后端的错误与哨兵错误比较
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// Backend 网盘存储后端
+// 各实现统一返回 GitHub 的数据结构，前端无需关心实际存储位置
+type Backend interface {
+	// ListRepositories 列出当前用户可见的全部仓库，不支持的过滤条件由实现忽略
+	ListRepositories(ctx context.Context, opts github.RepoListOptions) ([]github.Repository, error)
+	// CreateRepository 创建新仓库
+	CreateRepository(ctx context.Context, name, description string, isPrivate, autoInit bool) (*github.Repository, error)
+
+	// ListFiles 列出目录内容，ref 为空时使用默认分支
+	ListFiles(ctx context.Context, owner, repo, path, ref string) ([]github.FileEntry, error)
+	// GetFileContent 读取文件，内容以 base64 编码
+	GetFileContent(ctx context.Context, owner, repo, path, ref string) (*github.FileEntry, error)
+	// GetFileSHA 获取文件当前的 blob SHA，文件不存在时返回空字符串
+	GetFileSHA(ctx context.Context, owner, repo, path, branch string) (string, error)
+	// CreateOrUpdateFile 写入 base64 编码的内容，更新已有文件时 sha 必须与当前版本一致
+	CreateOrUpdateFile(ctx context.Context, owner, repo, path, content, message, branch, sha string) (*github.FileEntry, error)
+	// DeleteFile 删除文件，sha 必须与当前版本一致
+	DeleteFile(ctx context.Context, owner, repo, path, sha, message, branch string) error
+
+	// ListCommits 列出影响指定路径的提交历史
+	ListCommits(ctx context.Context, owner, repo, path, ref string) ([]github.Commit, error)
+}
+
+// SharedRepositoryLister 能区分他人共享仓库的后端
+type SharedRepositoryLister interface {
+	// ListSharedRepositories 列出他人以协作者身份共享给当前用户的仓库
+	ListSharedRepositories(ctx context.Context) ([]github.Repository, error)
+}
+
+// PermissionChecker 能在执行操作前检查 token 权限的后端
+type PermissionChecker interface {
+	// CheckRepoPermission 检查对仓库是否有 permission 级别的权限，不足时返回 *github.PermissionError
+	CheckRepoPermission(ctx context.Context, owner, repo, permission string) error
+}
+
+// CommitPager 能只读取一段提交历史的后端，翻页时不必从头读取全部历史
+type CommitPager interface {
+	// ListCommitsPage 返回从第 offset 个提交开始的至多 limit 个提交，more 表示之后还有提交
+	ListCommitsPage(ctx context.Context, owner, repo, path, ref string, offset, limit int) (commits []github.Commit, more bool, err error)
+}
+
+// DefaultBranchResolver 能查询仓库默认分支的后端，写队列据此把空分支与默认分支名归入同一队列
+type DefaultBranchResolver interface {
+	// DefaultBranch 返回仓库的默认分支名
+	DefaultBranch(ctx context.Context, owner, repo string) (string, error)
+}
+
+// 编译期检查 GitHub 客户端实现了 Backend
+var (
+	_ Backend                = (*github.Client)(nil)
+	_ SharedRepositoryLister = (*github.Client)(nil)
+	_ PermissionChecker      = (*github.Client)(nil)
+	_ CommitPager            = (*github.Client)(nil)
+	_ DefaultBranchResolver  = (*github.Client)(nil)
+)
@@ -0,0 +1,86 @@
+package syncer
+
+import "testing"
+
+func TestIgnoreMatcher(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		// 不含斜杠的规则匹配任意层级
+		{[]string{"*.log"}, "debug.log", false, true},
+		{[]string{"*.log"}, "logs/debug.log", false, true},
+		{[]string{"*.log"}, "debug.log.txt", false, false},
+		{[]string{"?.txt"}, "a.txt", false, true},
+		{[]string{"?.txt"}, "ab.txt", false, false},
+		{[]string{"[ab].txt"}, "b.txt", false, true},
+		{[]string{"[!ab].txt"}, "b.txt", false, false},
+
+		// 含斜杠的规则相对根目录匹配
+		{[]string{"/build"}, "build", true, true},
+		{[]string{"/build"}, "src/build", true, false},
+		{[]string{"docs/*.md"}, "docs/a.md", false, true},
+		{[]string{"docs/*.md"}, "docs/sub/a.md", false, false},
+		{[]string{"docs/*.md"}, "src/docs/a.md", false, false},
+
+		// "**" 匹配零个或多个目录
+		{[]string{"**/cache"}, "cache", true, true},
+		{[]string{"**/cache"}, "a/b/cache", true, true},
+		{[]string{"a/**/b"}, "a/b", false, true},
+		{[]string{"a/**/b"}, "a/x/y/b", false, true},
+		{[]string{"a/**"}, "a/x/y", false, true},
+		{[]string{"a/**"}, "b/a/x", false, false},
+
+		// 目录规则只匹配目录，但目录中的文件随之被忽略
+		{[]string{"tmp/"}, "tmp", false, false},
+		{[]string{"tmp/"}, "tmp", true, true},
+		{[]string{"tmp/"}, "tmp/a.txt", false, true},
+		{[]string{"tmp/"}, "src/tmp/a.txt", false, true},
+
+		// 否定规则，后出现的规则优先
+		{[]string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{[]string{"*.log", "!keep.log"}, "other.log", false, true},
+		{[]string{"!keep.log", "*.log"}, "keep.log", false, true},
+		// 父目录被忽略时无法用否定规则取回其中的文件
+		{[]string{"logs/", "!logs/keep.log"}, "logs/keep.log", false, true},
+
+		// 注释、空行和转义
+		{[]string{"# comment", "", "   "}, "# comment", false, false},
+		{[]string{`\#notes`}, "#notes", false, true},
+		{[]string{`\!important`}, "!important", false, true},
+		{[]string{`a\*b`}, "a*b", false, true},
+		{[]string{`a\*b`}, "axb", false, false},
+	}
+
+	for _, tt := range tests {
+		m := NewIgnoreMatcher(tt.patterns)
+		if got := m.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q.Match(%q, %v) = %v, want %v", tt.patterns, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultIgnorePatterns(t *testing.T) {
+	m := NewIgnoreMatcher(defaultIgnorePatterns)
+
+	for _, tt := range []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{".git", true, true},
+		{".git/config", false, true},
+		{stateDirName, true, true},
+		{stateDirName + "/state.json", false, true},
+		{"report.pdf" + tempSuffix, false, true},
+		{"docs/report.pdf" + tempSuffix, false, true},
+		{"report.pdf", false, false},
+		{".gitignore", false, false},
+	} {
+		if got := m.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
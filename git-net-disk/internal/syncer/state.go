@@ -0,0 +1,76 @@
+package syncer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateDirName 本地状态目录，位于同步目录根下且不会被同步
+const stateDirName = ".gitnetdisk"
+
+// tempSuffix 拉取文件时临时文件的后缀，写入完成后改名为目标文件
+const tempSuffix = ".gitnetdisk-tmp"
+
+// FileState 上次同步时文件的状态
+type FileState struct {
+	SHA        string    `json:"sha"`                  // Git blob SHA
+	Executable bool      `json:"executable,omitempty"` // 是否为可执行文件
+	MTime      time.Time `json:"mtime"`                // 本地修改时间
+	Size       int64     `json:"size"`                 // 本地文件大小
+}
+
+// State 本地同步状态数据库
+type State struct {
+	Owner      string               `json:"owner"`
+	Repo       string               `json:"repo"`
+	Branch     string               `json:"branch"`
+	Path       string               `json:"path,omitempty"` // 仓库中的同步目录
+	LastCommit string               `json:"last_commit"`    // 上次同步时远端分支指向的提交
+	Files      map[string]FileState `json:"files"`
+
+	path string
+}
+
+// LoadState 加载状态文件，不存在时返回空状态
+func LoadState(path string) (*State, error) {
+	state := &State{
+		Files: make(map[string]FileState),
+		path:  path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]FileState)
+	}
+	return state, nil
+}
+
+// Save 原子写入状态文件
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,547 @@
+package syncer
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"git-net-disk/internal/github"
+)
+
+// Config 同步配置
+type Config struct {
+	Dir       string        // 本地目录
+	Owner     string        // 仓库所有者
+	Repo      string        // 仓库名
+	Branch    string        // 分支，为空时使用仓库默认分支
+	Path      string        // 仓库中的同步目录，为空时同步整个仓库
+	Interval  time.Duration // 两次同步之间的间隔
+	BatchSize int           // 每个提交最多包含的文件数
+}
+
+// Result 单次同步的结果
+type Result struct {
+	Pushed    []string `json:"pushed"`
+	Pulled    []string `json:"pulled"`
+	Deleted   []string `json:"deleted"`   // 本地被删除的文件
+	Conflicts []string `json:"conflicts"` // 生成的冲突副本
+}
+
+// Syncer 本地目录与仓库路径的双向同步器
+type Syncer struct {
+	client *github.Client
+	config Config
+	state  *State
+	ignore *IgnoreMatcher
+	host   string
+}
+
+// 树条目的文件模式，同步只处理普通文件和可执行文件
+const (
+	modeFile       = "100644"
+	modeExecutable = "100755"
+)
+
+// localFile 本地扫描得到的文件
+type localFile struct {
+	sha        string
+	executable bool
+	mtime      time.Time
+	size       int64
+}
+
+// remoteFile 远端树中的文件
+type remoteFile struct {
+	sha        string
+	executable bool
+}
+
+// version 用于比较两边文件是否相同的标识，可执行位的变化也视为修改，文件不存在时为空
+func version(sha string, executable bool) string {
+	if sha != "" && executable {
+		return sha + ":x"
+	}
+	return sha
+}
+
+// syncAction 单个文件在一次同步中的处理方式
+type syncAction int
+
+const (
+	actionKeep     syncAction = iota // 两边都没有变化或做了相同的修改，只更新本地状态
+	actionPush                       // 上传本地版本，本地已删除时删除远端文件
+	actionPull                       // 拉取远端版本，远端已删除时删除本地文件
+	actionConflict                   // 两边做了不同的修改，本地版本另存为冲突副本后拉取远端版本
+)
+
+// decide 根据上次同步时的版本 base 和两边的当前版本决定处理方式，参数为 version 的返回值
+func decide(base, local, remote string) syncAction {
+	switch {
+	case local == remote:
+		return actionKeep
+	case remote == base:
+		return actionPush
+	case local == base:
+		return actionPull
+	case local == "":
+		// 本地删除而远端修改，保留远端版本
+		return actionPull
+	case remote == "":
+		// 远端删除而本地修改，重新上传本地版本
+		return actionPush
+	default:
+		return actionConflict
+	}
+}
+
+// pushChange 待推送的变更，sha 为空表示删除
+type pushChange struct {
+	path  string
+	local localFile
+}
+
+// New 创建同步器并加载本地状态
+func New(ctx context.Context, client *github.Client, config Config) (*Syncer, error) {
+	if config.Dir == "" || config.Owner == "" || config.Repo == "" {
+		return nil, fmt.Errorf("dir, owner and repo are required")
+	}
+	if config.Interval <= 0 {
+		config.Interval = 30 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	config.Path = cleanRepoPath(config.Path)
+
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	state, err := LoadState(filepath.Join(config.Dir, stateDirName, "state.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync state: %v", err)
+	}
+	if state.Owner != "" && (state.Owner != config.Owner || state.Repo != config.Repo || state.Path != config.Path) {
+		return nil, fmt.Errorf("directory is already synced with %s/%s/%s", state.Owner, state.Repo, state.Path)
+	}
+
+	if config.Branch == "" {
+		config.Branch = state.Branch
+	}
+	if config.Branch == "" {
+		repository, err := client.GetRepository(ctx, config.Owner, config.Repo)
+		if err != nil {
+			return nil, err
+		}
+		config.Branch = repository.DefaultBranch
+	}
+	if state.Branch != "" && state.Branch != config.Branch {
+		return nil, fmt.Errorf("directory is already synced with branch %s", state.Branch)
+	}
+
+	state.Owner = config.Owner
+	state.Repo = config.Repo
+	state.Branch = config.Branch
+	state.Path = config.Path
+
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "local"
+	}
+
+	return &Syncer{
+		client: client,
+		config: config,
+		state:  state,
+		host:   host,
+	}, nil
+}
+
+// Run 按间隔持续同步，直到 ctx 被取消
+func (s *Syncer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := s.SyncOnce(ctx)
+		if err != nil {
+			fmt.Printf("[ERROR] Sync failed: %v\n", err)
+		} else if n := len(result.Pushed) + len(result.Pulled) + len(result.Deleted) + len(result.Conflicts); n > 0 {
+			fmt.Printf("[INFO] Sync done: %d pushed, %d pulled, %d deleted, %d conflicts\n",
+				len(result.Pushed), len(result.Pulled), len(result.Deleted), len(result.Conflicts))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncOnce 执行一次完整的双向同步
+func (s *Syncer) SyncOnce(ctx context.Context) (*Result, error) {
+	ignore, err := LoadIgnoreFile(filepath.Join(s.config.Dir, ".gitignore"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ignore rules: %v", err)
+	}
+	s.ignore = ignore
+
+	local, err := s.scanLocal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan local directory: %v", err)
+	}
+
+	head, treeSHA, remote, err := s.fetchRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	var pushes []pushChange
+
+	paths := make(map[string]struct{})
+	for p := range s.state.Files {
+		paths[p] = struct{}{}
+	}
+	for p := range local {
+		paths[p] = struct{}{}
+	}
+	for p := range remote {
+		paths[p] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	for _, p := range sorted {
+		prev := s.state.Files[p]
+		l, hasLocal := local[p]
+		r := remote[p]
+
+		switch decide(version(prev.SHA, prev.Executable), version(l.sha, l.executable), version(r.sha, r.executable)) {
+		case actionKeep:
+			if hasLocal {
+				s.state.Files[p] = l.state()
+			} else {
+				delete(s.state.Files, p)
+			}
+
+		case actionPush:
+			pushes = append(pushes, pushChange{path: p, local: l})
+
+		case actionPull:
+			if err := s.pull(ctx, p, r, result); err != nil {
+				return result, err
+			}
+
+		case actionConflict:
+			conflictPath, err := s.writeConflictCopy(p)
+			if err != nil {
+				return result, err
+			}
+			if err := s.pull(ctx, p, r, result); err != nil {
+				return result, err
+			}
+			pushes = append(pushes, pushChange{path: conflictPath, local: l})
+			result.Conflicts = append(result.Conflicts, conflictPath)
+		}
+	}
+
+	// 拉取完成后状态已与 head 一致
+	s.state.LastCommit = head
+	if err := s.state.Save(); err != nil {
+		return result, err
+	}
+
+	if err := s.push(ctx, pushes, head, treeSHA, result); err != nil {
+		return result, err
+	}
+
+	return result, s.state.Save()
+}
+
+// scanLocal 扫描本地目录并计算每个文件的 blob SHA
+func (s *Syncer) scanLocal() (map[string]localFile, error) {
+	files := make(map[string]localFile)
+
+	err := filepath.WalkDir(s.config.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.config.Dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+
+		if s.ignore.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		executable := info.Mode().Perm()&0o111 != 0
+
+		// 修改时间和大小未变时沿用上次计算的 SHA
+		if prev, ok := s.state.Files[rel]; ok && prev.MTime.Equal(info.ModTime()) && prev.Size == info.Size() {
+			files[rel] = localFile{sha: prev.SHA, executable: executable, mtime: info.ModTime(), size: info.Size()}
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = localFile{sha: blobSHA(data), executable: executable, mtime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+
+	return files, err
+}
+
+// fetchRemote 获取远端分支的 head、根树和同步目录下所有文件，文件路径相对于同步目录
+func (s *Syncer) fetchRemote(ctx context.Context) (head, treeSHA string, files map[string]remoteFile, err error) {
+	ref, err := s.client.GetRef(ctx, s.config.Owner, s.config.Repo, "heads/"+s.config.Branch)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to get branch %s: %v", s.config.Branch, err)
+	}
+	head = ref.Object.SHA
+
+	commit, err := s.client.GetGitCommit(ctx, s.config.Owner, s.config.Repo, head)
+	if err != nil {
+		return "", "", nil, err
+	}
+	treeSHA = commit.Tree.SHA
+
+	files = make(map[string]remoteFile)
+
+	// 远端没有变化时直接使用上次同步的结果
+	if head == s.state.LastCommit {
+		for p, f := range s.state.Files {
+			files[p] = remoteFile{sha: f.SHA, executable: f.Executable}
+		}
+		return head, treeSHA, files, nil
+	}
+
+	tree, err := s.client.GetTree(ctx, s.config.Owner, s.config.Repo, treeSHA, true)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if tree.Truncated {
+		return "", "", nil, fmt.Errorf("repository tree is too large to sync")
+	}
+
+	for _, entry := range tree.Tree {
+		// 符号链接和子模块无法映射为本地普通文件，不参与同步
+		if entry.Type != "blob" || entry.SHA == nil || (entry.Mode != modeFile && entry.Mode != modeExecutable) {
+			continue
+		}
+		rel, ok := s.relPath(entry.Path)
+		if !ok || s.ignore.Match(rel, false) {
+			continue
+		}
+		files[rel] = remoteFile{sha: *entry.SHA, executable: entry.Mode == modeExecutable}
+	}
+
+	return head, treeSHA, files, nil
+}
+
+// relPath 返回仓库路径相对于同步目录的路径，不在同步目录下时返回 false
+func (s *Syncer) relPath(repoPath string) (string, bool) {
+	if s.config.Path == "" {
+		return repoPath, true
+	}
+	rel, ok := strings.CutPrefix(repoPath, s.config.Path+"/")
+	return rel, ok && rel != ""
+}
+
+// repoPath 返回同步目录中的相对路径在仓库中的路径
+func (s *Syncer) repoPath(relPath string) string {
+	if s.config.Path == "" {
+		return relPath
+	}
+	return s.config.Path + "/" + relPath
+}
+
+// pull 将远端版本写入本地，remote.sha 为空时删除本地文件
+func (s *Syncer) pull(ctx context.Context, relPath string, remote remoteFile, result *Result) error {
+	fullPath := filepath.Join(s.config.Dir, filepath.FromSlash(relPath))
+
+	if remote.sha == "" {
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		s.removeEmptyParents(filepath.Dir(fullPath))
+		delete(s.state.Files, relPath)
+		result.Deleted = append(result.Deleted, relPath)
+		return nil
+	}
+
+	data, err := s.client.GetBlob(ctx, s.config.Owner, s.config.Repo, remote.sha)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", relPath, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return err
+	}
+	perm := os.FileMode(0o644)
+	if remote.executable {
+		perm = 0o755
+	}
+	tmp := fullPath + tempSuffix
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	// WriteFile 只在创建文件时使用 perm，残留的临时文件需要显式设置
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, fullPath); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return err
+	}
+	s.state.Files[relPath] = FileState{SHA: remote.sha, Executable: remote.executable, MTime: info.ModTime(), Size: info.Size()}
+	result.Pulled = append(result.Pulled, relPath)
+	return nil
+}
+
+// push 分批提交本地变更
+func (s *Syncer) push(ctx context.Context, changes []pushChange, head, treeSHA string, result *Result) error {
+	for start := 0; start < len(changes); start += s.config.BatchSize {
+		end := start + s.config.BatchSize
+		if end > len(changes) {
+			end = len(changes)
+		}
+		batch := changes[start:end]
+
+		entries := make([]github.TreeEntry, 0, len(batch))
+		for _, change := range batch {
+			entry := github.TreeEntry{Path: s.repoPath(change.path), Mode: modeFile, Type: "blob"}
+			if change.local.executable {
+				entry.Mode = modeExecutable
+			}
+			if change.local.sha != "" {
+				data, err := os.ReadFile(filepath.Join(s.config.Dir, filepath.FromSlash(change.path)))
+				if err != nil {
+					return err
+				}
+				sha, err := s.client.CreateBlob(ctx, s.config.Owner, s.config.Repo, data)
+				if err != nil {
+					return fmt.Errorf("failed to upload %s: %v", change.path, err)
+				}
+				entry.SHA = &sha
+			}
+			entries = append(entries, entry)
+		}
+
+		tree, err := s.client.CreateTree(ctx, s.config.Owner, s.config.Repo, treeSHA, entries)
+		if err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("Sync %d file(s) from %s", len(batch), s.host)
+		commit, err := s.client.CreateGitCommit(ctx, s.config.Owner, s.config.Repo, message, tree.SHA, []string{head})
+		if err != nil {
+			return err
+		}
+
+		// 不强制更新，远端在此期间有新提交时留给下一轮同步处理
+		if err := s.client.UpdateRef(ctx, s.config.Owner, s.config.Repo, "heads/"+s.config.Branch, commit.SHA, false); err != nil {
+			return fmt.Errorf("failed to update branch %s: %v", s.config.Branch, err)
+		}
+
+		head = commit.SHA
+		treeSHA = tree.SHA
+		for _, change := range batch {
+			if change.local.sha == "" {
+				delete(s.state.Files, change.path)
+			} else {
+				s.state.Files[change.path] = change.local.state()
+			}
+			result.Pushed = append(result.Pushed, change.path)
+		}
+		s.state.LastCommit = head
+		if err := s.state.Save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeConflictCopy 将本地版本改名为冲突副本，返回副本的相对路径
+func (s *Syncer) writeConflictCopy(relPath string) (string, error) {
+	dir, name := path.Split(relPath)
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	conflictPath := fmt.Sprintf("%s%s (conflict copy from %s %s)%s",
+		dir, stem, s.host, time.Now().Format("2006-01-02 150405"), ext)
+
+	from := filepath.Join(s.config.Dir, filepath.FromSlash(relPath))
+	to := filepath.Join(s.config.Dir, filepath.FromSlash(conflictPath))
+	if err := os.Rename(from, to); err != nil {
+		return "", fmt.Errorf("failed to write conflict copy of %s: %v", relPath, err)
+	}
+
+	fmt.Printf("[WARN] Conflict on %s, local version saved as %s\n", relPath, conflictPath)
+	return conflictPath, nil
+}
+
+// removeEmptyParents 删除因拉取删除而变空的父目录
+func (s *Syncer) removeEmptyParents(dir string) {
+	root := filepath.Clean(s.config.Dir)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
+
+// state 返回同步后记录的文件状态
+func (f localFile) state() FileState {
+	return FileState{SHA: f.sha, Executable: f.executable, MTime: f.mtime, Size: f.size}
+}
+
+// cleanRepoPath 规范化仓库中的同步目录，根目录返回空字符串
+func cleanRepoPath(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// blobSHA 计算与 git hash-object 相同的 blob SHA
+func blobSHA(data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,56 @@
+package syncer
+
+import "testing"
+
+func TestDecide(t *testing.T) {
+	const (
+		a  = "a"
+		b  = "b"
+		c  = "c"
+		ax = "a:x" // 内容为 a 的可执行文件
+	)
+
+	tests := []struct {
+		name                string
+		base, local, remote string
+		want                syncAction
+	}{
+		{"unchanged", a, a, a, actionKeep},
+		{"never synced and absent", "", "", "", actionKeep},
+		{"same change on both sides", a, b, b, actionKeep},
+		{"deleted on both sides", a, "", "", actionKeep},
+		{"created identically on both sides", "", a, a, actionKeep},
+
+		{"local change", a, b, a, actionPush},
+		{"local create", "", a, "", actionPush},
+		{"local delete", a, "", a, actionPush},
+		{"local chmod", a, ax, a, actionPush},
+
+		{"remote change", a, a, b, actionPull},
+		{"remote create", "", "", a, actionPull},
+		{"remote delete", a, a, "", actionPull},
+		{"remote chmod", a, a, ax, actionPull},
+
+		{"local delete and remote change", a, "", b, actionPull},
+		{"remote delete and local change", a, b, "", actionPush},
+
+		{"different changes", a, b, c, actionConflict},
+		{"different creates", "", a, b, actionConflict},
+		{"local change and remote chmod", a, b, ax, actionConflict},
+	}
+
+	for _, tt := range tests {
+		if got := decide(tt.base, tt.local, tt.remote); got != tt.want {
+			t.Errorf("%s: decide(%q, %q, %q) = %v, want %v", tt.name, tt.base, tt.local, tt.remote, got, tt.want)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	if version("", true) != "" {
+		t.Fatal("a missing file must have an empty version")
+	}
+	if version("abc", false) == version("abc", true) {
+		t.Fatal("a change of the executable bit must change the version")
+	}
+}
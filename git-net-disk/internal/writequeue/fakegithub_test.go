@@ -0,0 +1,468 @@
+package writequeue
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/proxy"
+)
+
+// fakeFile 树中的一个文件
+type fakeFile struct {
+	mode string
+	sha  string
+}
+
+// fakeCommit 提交对象
+type fakeCommit struct {
+	tree    string
+	parents []string
+	message string
+	date    time.Time
+}
+
+// fakeGitHub 内存中的单仓库 GitHub，实现 contents 接口和 Git 数据接口中写队列用到的部分
+// 树以扁平的路径表保存，子目录的 SHA 在列出时按需登记
+type fakeGitHub struct {
+	t      *testing.T
+	server *httptest.Server
+	owner  string
+	repo   string
+
+	mu       sync.Mutex
+	blobs    map[string][]byte
+	trees    map[string]map[string]fakeFile
+	subtrees map[string][2]string // 子目录 SHA -> 根树 SHA 和目录路径
+	commits  map[string]fakeCommit
+	refs     map[string]string
+	seq      int
+
+	// beforeUpdateRef 在处理更新引用请求之前调用，用于模拟其他客户端抢先提交
+	beforeUpdateRef func(f *fakeGitHub)
+	// createdCommits 通过 Git 数据接口创建的提交数，contentsWrites 通过 contents 接口完成的写入数
+	createdCommits int
+	contentsWrites int
+}
+
+// newFakeGitHub 创建只有一个 main 分支的仓库，files 为初始文件的路径和内容
+func newFakeGitHub(t *testing.T, files map[string]string) *fakeGitHub {
+	f := &fakeGitHub{
+		t:        t,
+		owner:    "octo",
+		repo:     "drive",
+		blobs:    make(map[string][]byte),
+		trees:    make(map[string]map[string]fakeFile),
+		subtrees: make(map[string][2]string),
+		commits:  make(map[string]fakeCommit),
+		refs:     make(map[string]string),
+	}
+	tree := make(map[string]fakeFile)
+	for p, content := range files {
+		tree[p] = fakeFile{mode: "100644", sha: f.putBlob([]byte(content))}
+	}
+	f.refs["heads/main"] = f.putCommit(f.putTree(tree), nil, "initial")
+
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// client 返回指向假服务器的 GitHub 客户端
+func (f *fakeGitHub) client() *github.Client {
+	client, err := github.NewClientWithEndpoints("test-token", proxy.ProxyConfig{}, github.Endpoints{
+		APIURL:    f.server.URL,
+		UploadURL: f.server.URL,
+		RawURL:    f.server.URL,
+		WebURL:    f.server.URL,
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return client
+}
+
+// head 返回分支的当前提交
+func (f *fakeGitHub) head(branch string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refs["heads/"+branch]
+}
+
+// file 返回分支上文件的内容和模式，文件不存在时 ok 为 false
+func (f *fakeGitHub) file(branch, filePath string) (content, mode string, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, ok := f.trees[f.commits[f.refs["heads/"+branch]].tree][filePath]
+	if !ok {
+		return "", "", false
+	}
+	return string(f.blobs[file.sha]), file.mode, true
+}
+
+// commitExternally 模拟其他客户端直接在分支上提交
+func (f *fakeGitHub) commitExternally(branch, filePath, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commitFileLocked(branch, filePath, &fakeFile{mode: "100644", sha: f.putBlob([]byte(content))}, "external change")
+}
+
+// setMode 直接修改分支上文件的模式
+func (f *fakeGitHub) setMode(branch, filePath, mode string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file := f.trees[f.commits[f.refs["heads/"+branch]].tree][filePath]
+	file.mode = mode
+	f.commitFileLocked(branch, filePath, &file, "chmod")
+}
+
+// commitFileLocked 在分支上提交单个文件的修改，file 为 nil 时删除
+func (f *fakeGitHub) commitFileLocked(branch, filePath string, file *fakeFile, message string) string {
+	parent := f.refs["heads/"+branch]
+	tree := make(map[string]fakeFile)
+	for p, entry := range f.trees[f.commits[parent].tree] {
+		tree[p] = entry
+	}
+	if file == nil {
+		delete(tree, filePath)
+	} else {
+		tree[filePath] = *file
+	}
+	sha := f.putCommit(f.putTree(tree), []string{parent}, message)
+	f.refs["heads/"+branch] = sha
+	return sha
+}
+
+// putBlob 保存 blob 并返回它的 SHA
+func (f *fakeGitHub) putBlob(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	sha := hex.EncodeToString(h.Sum(nil))
+	f.blobs[sha] = content
+	return sha
+}
+
+// putTree 保存树并返回它的 SHA
+func (f *fakeGitHub) putTree(tree map[string]fakeFile) string {
+	paths := make([]string, 0, len(tree))
+	for p := range tree {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	h := sha1.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s %s %s\n", tree[p].mode, p, tree[p].sha)
+	}
+	sha := hex.EncodeToString(h.Sum(nil))
+	f.trees[sha] = tree
+	return sha
+}
+
+// putCommit 保存提交并返回它的 SHA
+func (f *fakeGitHub) putCommit(tree string, parents []string, message string) string {
+	f.seq++
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s %v %s %d", tree, parents, message, f.seq)))
+	sha := hex.EncodeToString(sum[:])
+	f.commits[sha] = fakeCommit{tree: tree, parents: parents, message: message, date: time.Unix(1700000000+int64(f.seq), 0).UTC()}
+	return sha
+}
+
+// isAncestor 检查 ancestor 是否是 commit 或其祖先
+func (f *fakeGitHub) isAncestor(ancestor, commit string) bool {
+	if commit == ancestor {
+		return true
+	}
+	for _, parent := range f.commits[commit].parents {
+		if f.isAncestor(ancestor, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve 把分支名或提交 SHA 解析为提交，为空时使用 main
+func (f *fakeGitHub) resolve(ref string) (string, bool) {
+	if ref == "" {
+		ref = "main"
+	}
+	if sha, ok := f.refs["heads/"+ref]; ok {
+		return sha, true
+	}
+	_, ok := f.commits[ref]
+	return ref, ok
+}
+
+func (f *fakeGitHub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	prefix := fmt.Sprintf("/repos/%s/%s", f.owner, f.repo)
+	rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+		return
+	}
+
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"name":           f.repo,
+			"full_name":      f.owner + "/" + f.repo,
+			"default_branch": "main",
+		})
+
+	case strings.HasPrefix(rest, "/contents/"):
+		f.serveContents(w, r, strings.TrimPrefix(rest, "/contents/"))
+
+	case strings.HasPrefix(rest, "/git/ref/") && r.Method == http.MethodGet:
+		ref := strings.TrimPrefix(rest, "/git/ref/")
+		sha, ok := f.refs[ref]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"ref":    "refs/" + ref,
+			"object": map[string]string{"sha": sha, "type": "commit"},
+		})
+
+	case strings.HasPrefix(rest, "/git/refs/") && r.Method == http.MethodPatch:
+		if f.beforeUpdateRef != nil {
+			hook := f.beforeUpdateRef
+			f.beforeUpdateRef = nil
+			f.mu.Unlock()
+			hook(f)
+			f.mu.Lock()
+		}
+		var body struct {
+			SHA   string `json:"sha"`
+			Force bool   `json:"force"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		ref := strings.TrimPrefix(rest, "/git/refs/")
+		if !body.Force && !f.isAncestor(f.refs[ref], body.SHA) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"message": "Update is not a fast forward"})
+			return
+		}
+		f.refs[ref] = body.SHA
+		writeJSON(w, http.StatusOK, map[string]interface{}{"ref": "refs/" + ref, "object": map[string]string{"sha": body.SHA}})
+
+	case strings.HasPrefix(rest, "/git/commits/") && r.Method == http.MethodGet:
+		sha := strings.TrimPrefix(rest, "/git/commits/")
+		commit, ok := f.commits[sha]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, f.commitJSON(sha, commit))
+
+	case rest == "/git/commits" && r.Method == http.MethodPost:
+		var body struct {
+			Message string   `json:"message"`
+			Tree    string   `json:"tree"`
+			Parents []string `json:"parents"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		sha := f.putCommit(body.Tree, body.Parents, body.Message)
+		f.createdCommits++
+		writeJSON(w, http.StatusCreated, f.commitJSON(sha, f.commits[sha]))
+
+	case strings.HasPrefix(rest, "/git/trees/") && r.Method == http.MethodGet:
+		f.serveTree(w, strings.TrimPrefix(rest, "/git/trees/"), r.URL.Query().Get("recursive") != "")
+
+	case rest == "/git/trees" && r.Method == http.MethodPost:
+		var body struct {
+			BaseTree string             `json:"base_tree"`
+			Tree     []github.TreeEntry `json:"tree"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		tree := make(map[string]fakeFile)
+		for p, file := range f.trees[body.BaseTree] {
+			tree[p] = file
+		}
+		for _, entry := range body.Tree {
+			if entry.SHA == nil {
+				delete(tree, entry.Path)
+			} else {
+				tree[entry.Path] = fakeFile{mode: entry.Mode, sha: *entry.SHA}
+			}
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"sha": f.putTree(tree)})
+
+	case strings.HasPrefix(rest, "/git/blobs/") && r.Method == http.MethodGet:
+		content, ok := f.blobs[strings.TrimPrefix(rest, "/git/blobs/")]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"content": base64.StdEncoding.EncodeToString(content), "encoding": "base64"})
+
+	case rest == "/git/blobs" && r.Method == http.MethodPost:
+		var body struct {
+			Content string `json:"content"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		content, _ := base64.StdEncoding.DecodeString(body.Content)
+		writeJSON(w, http.StatusCreated, map[string]string{"sha": f.putBlob(content)})
+
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+	}
+}
+
+// serveTree 列出根树或子目录，子目录的 SHA 在这里登记
+func (f *fakeGitHub) serveTree(w http.ResponseWriter, sha string, recursive bool) {
+	root, dir := sha, ""
+	if sub, ok := f.subtrees[sha]; ok {
+		root, dir = sub[0], sub[1]
+	}
+	tree, ok := f.trees[root]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+		return
+	}
+
+	var entries []github.TreeEntry
+	seen := make(map[string]bool)
+	for p, file := range tree {
+		rel := p
+		if dir != "" {
+			var ok bool
+			if rel, ok = strings.CutPrefix(p, dir+"/"); !ok {
+				continue
+			}
+		}
+		parts := strings.Split(rel, "/")
+		// 列出经过的子目录，非递归时只列出第一层
+		for i := 1; i < len(parts); i++ {
+			name := strings.Join(parts[:i], "/")
+			if seen[name] || (!recursive && i > 1) {
+				continue
+			}
+			seen[name] = true
+			full := path.Join(dir, name)
+			subSHA := hex.EncodeToString(func() []byte { s := sha1.Sum([]byte(root + ":" + full)); return s[:] }())
+			f.subtrees[subSHA] = [2]string{root, full}
+			entries = append(entries, github.TreeEntry{Path: name, Mode: "040000", Type: "tree", SHA: &subSHA})
+		}
+		if !recursive && len(parts) > 1 {
+			continue
+		}
+		fileSHA := file.sha
+		entries = append(entries, github.TreeEntry{Path: rel, Mode: file.mode, Type: "blob", SHA: &fileSHA, Size: len(f.blobs[file.sha])})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sha": sha, "tree": entries, "truncated": false})
+}
+
+// serveContents 实现 contents 接口的读取、写入和删除
+func (f *fakeGitHub) serveContents(w http.ResponseWriter, r *http.Request, filePath string) {
+	if r.Method == http.MethodGet {
+		commit, ok := f.resolve(r.URL.Query().Get("ref"))
+		file, exists := f.trees[f.commits[commit].tree][filePath]
+		if !ok || !exists {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, f.fileJSON(filePath, file.sha))
+		return
+	}
+
+	var body struct {
+		Message string `json:"message"`
+		Content string `json:"content"`
+		SHA     string `json:"sha"`
+		Branch  string `json:"branch"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	if body.Branch == "" {
+		body.Branch = "main"
+	}
+	commit := f.refs["heads/"+body.Branch]
+	current, exists := f.trees[f.commits[commit].tree][filePath]
+
+	switch r.Method {
+	case http.MethodPut:
+		if exists && body.SHA == "" {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"message": "Invalid request.\n\n\"sha\" wasn't supplied."})
+			return
+		}
+		if exists && body.SHA != current.sha || !exists && body.SHA != "" {
+			writeJSON(w, http.StatusConflict, map[string]string{"message": fmt.Sprintf("%s does not match %s", filePath, body.SHA)})
+			return
+		}
+		content, _ := base64.StdEncoding.DecodeString(body.Content)
+		file := fakeFile{mode: "100644", sha: f.putBlob(content)}
+		if exists {
+			file.mode = current.mode
+		}
+		sha := f.commitFileLocked(body.Branch, filePath, &file, body.Message)
+		f.contentsWrites++
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"content": f.fileJSON(filePath, file.sha),
+			"commit":  map[string]string{"sha": sha},
+		})
+
+	case http.MethodDelete:
+		if !exists {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		if body.SHA != current.sha {
+			writeJSON(w, http.StatusConflict, map[string]string{"message": fmt.Sprintf("%s does not match %s", filePath, body.SHA)})
+			return
+		}
+		sha := f.commitFileLocked(body.Branch, filePath, nil, body.Message)
+		f.contentsWrites++
+		writeJSON(w, http.StatusOK, map[string]interface{}{"commit": map[string]string{"sha": sha}})
+	}
+}
+
+// fileJSON contents 接口返回的文件
+func (f *fakeGitHub) fileJSON(filePath, sha string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "file",
+		"name":     path.Base(filePath),
+		"path":     filePath,
+		"sha":      sha,
+		"size":     len(f.blobs[sha]),
+		"content":  base64.StdEncoding.EncodeToString(f.blobs[sha]),
+		"encoding": "base64",
+	}
+}
+
+// commitJSON Git 数据接口返回的提交
+func (f *fakeGitHub) commitJSON(sha string, commit fakeCommit) map[string]interface{} {
+	parents := make([]map[string]string, 0, len(commit.parents))
+	for _, parent := range commit.parents {
+		parents = append(parents, map[string]string{"sha": parent})
+	}
+	actor := map[string]interface{}{"name": "test", "email": "test@example.com", "date": commit.date}
+	return map[string]interface{}{
+		"sha":       sha,
+		"message":   commit.message,
+		"tree":      map[string]string{"sha": commit.tree},
+		"parents":   parents,
+		"author":    actor,
+		"committer": actor,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
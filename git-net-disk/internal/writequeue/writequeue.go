@@ -0,0 +1,641 @@
+package writequeue
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/storage"
+)
+
+// ErrFileNotFound 删除的文件不存在
+var ErrFileNotFound = errors.New("file not found")
+
+// ErrRefConflict 多次重试后分支仍被其他客户端抢先更新
+var ErrRefConflict = errors.New("branch was updated concurrently")
+
+// ConflictError 文件的当前版本与写入的前置条件不符
+type ConflictError struct {
+	Path string
+	// CurrentSHA 文件当前的 blob SHA，文件不存在时为空
+	CurrentSHA string
+	// ExpectedSHA 写入期望的 SHA，为空表示要求文件不存在
+	ExpectedSHA string
+}
+
+// Error 实现 error 接口
+func (e *ConflictError) Error() string {
+	if e.ExpectedSHA == "" {
+		return fmt.Sprintf("%s already exists at %s", e.Path, e.CurrentSHA)
+	}
+	return fmt.Sprintf("%s is at %s but expected %s", e.Path, e.CurrentSHA, e.ExpectedSHA)
+}
+
+// Write 排队执行的一次文件写入或删除
+type Write struct {
+	Path string
+	// Content base64 编码的文件内容，Delete 为 true 时忽略
+	Content string
+	Delete  bool
+	Message string
+	// ExpectedSHA 期望的当前 blob SHA，为空时由 Overwrite 决定能否覆盖已有文件，删除时必填
+	ExpectedSHA string
+	Overwrite   bool
+}
+
+// Target 写入的目标分支
+type Target struct {
+	// Instance 区分不同的存储实例，例如 github.com 与 GitHub Enterprise 上的同名仓库
+	Instance string
+	Owner    string
+	Repo     string
+	// Branch 为空时使用默认分支
+	Branch string
+}
+
+// Result 一次写入的结果
+type Result struct {
+	// File 写入后的文件，删除时为 nil
+	File *github.FileEntry
+	// Commit 包含这次写入的提交，后端不返回提交时为空
+	Commit string
+	// Batched 与这次写入合并在同一个提交中的写入数量，包括自身
+	Batched int
+}
+
+// Config 写队列的配置
+type Config struct {
+	// MaxBatch 合并到同一个提交中的写入上限
+	MaxBatch int
+	// MaxRetries 分支被抢先更新时的重试次数
+	MaxRetries int
+	// CommitTimeout 执行一批写入的时限，写入开始执行后不再受请求取消的影响
+	CommitTimeout time.Duration
+}
+
+// DefaultConfig 默认的写队列配置
+func DefaultConfig() Config {
+	return Config{
+		MaxBatch:      50,
+		MaxRetries:    5,
+		CommitTimeout: 2 * time.Minute,
+	}
+}
+
+// ConfigFromEnv 读取 WRITE_QUEUE_MAX_BATCH、WRITE_QUEUE_MAX_RETRIES 和 WRITE_QUEUE_COMMIT_TIMEOUT，未设置或无法解析时使用默认值
+func ConfigFromEnv() Config {
+	config := DefaultConfig()
+	if n, err := strconv.Atoi(os.Getenv("WRITE_QUEUE_MAX_BATCH")); err == nil && n > 0 {
+		config.MaxBatch = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("WRITE_QUEUE_MAX_RETRIES")); err == nil && n >= 0 {
+		config.MaxRetries = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("WRITE_QUEUE_COMMIT_TIMEOUT")); err == nil && d > 0 {
+		config.CommitTimeout = d
+	}
+	return config
+}
+
+// laneKey 队列按实例、仓库和分支划分，仓库名不区分大小写
+type laneKey struct {
+	instance string
+	owner    string
+	repo     string
+	branch   string
+}
+
+// lane 同一分支上等待执行的写入
+type lane struct {
+	pending []*job
+}
+
+// job 队列中的一次写入
+type job struct {
+	ctx      context.Context
+	backend  storage.Backend
+	identity string
+	write    Write
+	done     chan outcome
+	// blobSHA 已上传的 blob 及其大小，重试时复用
+	blobSHA string
+	size    int
+}
+
+// outcome 写入的结果或错误
+type outcome struct {
+	result *Result
+	err    error
+}
+
+// finish 返回写入的结果，每个 job 只调用一次
+func (j *job) finish(result *Result, err error) {
+	j.done <- outcome{result: result, err: err}
+}
+
+// Queue 按分支串行执行写入，避免并发提交在分支引用上竞争
+// 排队期间到达的同一身份的写入合并为一个提交，分支被其他客户端抢先更新时自动重试
+type Queue struct {
+	config Config
+
+	mu    sync.Mutex
+	lanes map[laneKey]*lane
+}
+
+// New 创建写队列
+func New(config Config) *Queue {
+	return &Queue{
+		config: config,
+		lanes:  make(map[laneKey]*lane),
+	}
+}
+
+// Submit 把写入加入目标分支的队列并等待结果
+// identity 区分调用者，只有同一身份的写入才会合并到同一个提交中
+// ctx 在排队期间被取消时撤回写入；写入开始执行后会等待它完成，避免调用方无法得知写入是否生效
+func (q *Queue) Submit(ctx context.Context, backend storage.Backend, target Target, identity string, write Write) (*Result, error) {
+	// 默认分支与显式指定的分支名必须进入同一队列
+	if resolver, ok := backend.(storage.DefaultBranchResolver); ok && target.Branch == "" {
+		branch, err := resolver.DefaultBranch(ctx, target.Owner, target.Repo)
+		if err != nil {
+			return nil, err
+		}
+		target.Branch = branch
+	}
+
+	j := &job{
+		ctx:      ctx,
+		backend:  backend,
+		identity: identity,
+		write:    write,
+		done:     make(chan outcome, 1),
+	}
+	key := laneKey{
+		instance: target.Instance,
+		owner:    strings.ToLower(target.Owner),
+		repo:     strings.ToLower(target.Repo),
+		branch:   target.Branch,
+	}
+
+	q.mu.Lock()
+	l, ok := q.lanes[key]
+	if !ok {
+		l = &lane{}
+		q.lanes[key] = l
+		go q.run(key, l, target)
+	}
+	l.pending = append(l.pending, j)
+	q.mu.Unlock()
+
+	select {
+	case out := <-j.done:
+		return out.result, out.err
+	case <-ctx.Done():
+		if q.withdraw(l, j) {
+			return nil, ctx.Err()
+		}
+		out := <-j.done
+		return out.result, out.err
+	}
+}
+
+// withdraw 从队列中撤回尚未开始执行的写入
+func (q *Queue) withdraw(l *lane, j *job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, pending := range l.pending {
+		if pending == j {
+			l.pending = append(l.pending[:i], l.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// run 依次执行分支上的写入，队列为空时退出
+func (q *Queue) run(key laneKey, l *lane, target Target) {
+	for {
+		batch := q.next(key, l)
+		if batch == nil {
+			return
+		}
+		q.execute(target, batch)
+	}
+}
+
+// next 取出下一批写入：队首及其后连续的同一身份的写入
+func (q *Queue) next(key laneKey, l *lane) []*job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(l.pending) == 0 {
+		delete(q.lanes, key)
+		return nil
+	}
+	first := l.pending[0]
+	n := 1
+	for n < len(l.pending) && n < q.config.MaxBatch && l.pending[n].identity == first.identity {
+		n++
+	}
+	batch := l.pending[:n:n]
+	l.pending = l.pending[n:]
+	return batch
+}
+
+// execute 执行一批写入，多个写入在 GitHub 上合并为一个提交，其他后端逐个写入
+func (q *Queue) execute(target Target, batch []*job) {
+	// 保留首个请求上下文中的值，但不随请求取消
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(batch[0].ctx), q.config.CommitTimeout)
+	defer cancel()
+
+	if gh, ok := batch[0].backend.(*github.Client); ok && len(batch) > 1 {
+		if q.commitBatch(ctx, gh, target, batch) {
+			return
+		}
+	}
+	for _, j := range batch {
+		j.finish(q.writeOne(ctx, j.backend, target, j.write))
+	}
+}
+
+// writeOne 通过后端的文件接口写入单个文件，分支被抢先更新时重试
+func (q *Queue) writeOne(ctx context.Context, backend storage.Backend, target Target, write Write) (*Result, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := tryWrite(ctx, backend, target, write)
+		if err == nil || !isRefConflict(err) {
+			return result, err
+		}
+		if attempt >= q.config.MaxRetries {
+			return nil, fmt.Errorf("%w: %w", ErrRefConflict, err)
+		}
+		if err := sleepBeforeRetry(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// tryWrite 按前置条件写入或删除单个文件
+func tryWrite(ctx context.Context, backend storage.Backend, target Target, write Write) (*Result, error) {
+	owner, repo, branch := target.Owner, target.Repo, target.Branch
+
+	if write.Delete {
+		if err := backend.DeleteFile(ctx, owner, repo, write.Path, write.ExpectedSHA, write.Message, branch); err != nil {
+			return nil, err
+		}
+		return &Result{Batched: 1}, nil
+	}
+
+	sha := write.ExpectedSHA
+	if sha == "" && write.Overwrite {
+		// 显式覆盖：使用当前 SHA，后写者胜出
+		var err error
+		sha, err = backend.GetFileSHA(ctx, owner, repo, write.Path, branch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := backend.CreateOrUpdateFile(ctx, owner, repo, write.Path, write.Content, write.Message, branch, sha)
+	if err != nil {
+		if !isPreconditionFailure(err) {
+			return nil, err
+		}
+		// 文件在此期间被修改，或未带前置条件就试图覆盖已有文件
+		currentSHA, lookupErr := backend.GetFileSHA(ctx, owner, repo, write.Path, branch)
+		if lookupErr == nil && currentSHA != "" && currentSHA != sha {
+			return nil, &ConflictError{Path: write.Path, CurrentSHA: currentSHA, ExpectedSHA: sha}
+		}
+		return nil, err
+	}
+	return &Result{File: file, Batched: 1}, nil
+}
+
+// commitBatch 用 Git 数据接口把一批写入合并为一个提交，分支被抢先更新时重新检查前置条件后重试
+// 仓库为空或分支不存在时返回 false，由调用方逐个写入
+func (q *Queue) commitBatch(ctx context.Context, gh *github.Client, target Target, batch []*job) bool {
+	owner, repo, ref := target.Owner, target.Repo, "heads/"+target.Branch
+
+	for attempt := 0; ; attempt++ {
+		head, err := gh.GetRef(ctx, owner, repo, ref)
+		if err != nil {
+			if attempt == 0 && isMissingRef(err) {
+				return false
+			}
+			finishAll(batch, nil, err)
+			return true
+		}
+		parent, err := gh.GetGitCommit(ctx, owner, repo, head.Object.SHA)
+		if err != nil {
+			finishAll(batch, nil, err)
+			return true
+		}
+
+		plan := q.plan(ctx, gh, target, parent.Tree.SHA, batch)
+		if len(plan.accepted) == 0 {
+			plan.finishRejected()
+			return true
+		}
+
+		commit, err := q.createCommit(ctx, gh, target, parent.Tree.SHA, head.Object.SHA, plan)
+		if err == nil {
+			err = gh.UpdateRef(ctx, owner, repo, ref, commit.SHA, false)
+		}
+		if err != nil && isRefConflict(err) {
+			if attempt < q.config.MaxRetries {
+				if err := sleepBeforeRetry(ctx, attempt); err == nil {
+					continue
+				}
+			}
+			err = fmt.Errorf("%w: %w", ErrRefConflict, err)
+		}
+		if err != nil {
+			finishAll(plan.accepted, nil, err)
+			plan.finishRejected()
+			return true
+		}
+
+		for _, j := range plan.accepted {
+			result := &Result{Commit: commit.SHA, Batched: len(plan.accepted)}
+			if !j.write.Delete {
+				result.File = &github.FileEntry{
+					Name: path.Base(j.write.Path),
+					Path: j.write.Path,
+					SHA:  j.blobSHA,
+					Size: j.size,
+					Type: "file",
+				}
+			}
+			j.finish(result, nil)
+		}
+		plan.finishRejected()
+		return true
+	}
+}
+
+// batchPlan 一批写入中通过前置条件检查的写入及对应的树条目
+type batchPlan struct {
+	accepted []*job
+	entries  []github.TreeEntry
+	rejected map[*job]error
+}
+
+// finishRejected 返回未通过检查的写入的错误
+func (p *batchPlan) finishRejected() {
+	for j, err := range p.rejected {
+		j.finish(nil, err)
+	}
+}
+
+// plan 以 rootTree 为基准依次检查每个写入的前置条件，后面的写入基于前面写入后的状态
+// 已有文件沿用基准树中的模式，可执行文件和符号链接更新后保持不变
+func (q *Queue) plan(ctx context.Context, gh *github.Client, target Target, rootTree string, batch []*job) *batchPlan {
+	plan := &batchPlan{rejected: make(map[*job]error)}
+	tree := &baseTree{gh: gh, owner: target.Owner, repo: target.Repo, root: rootTree, dirs: make(map[string]map[string]github.TreeEntry)}
+	// base 文件在基准树中的 SHA，current 应用前面的写入后的 SHA，空字符串表示不存在
+	base := make(map[string]string)
+	current := make(map[string]string)
+	modes := make(map[string]string)
+	entries := make(map[string]int)
+
+	for _, j := range batch {
+		filePath := j.write.Path
+		sha, ok := current[filePath]
+		if !ok {
+			existing, found, err := tree.lookup(ctx, filePath)
+			if err == nil && found && existing.Type != "blob" {
+				err = fmt.Errorf("%w: %s is a %s, not a file", storage.ErrConflict, filePath, existing.Type)
+			}
+			if err != nil {
+				plan.rejected[j] = err
+				continue
+			}
+			if found {
+				sha = *existing.SHA
+				modes[filePath] = existing.Mode
+			}
+			base[filePath], current[filePath] = sha, sha
+		}
+		if err := CheckPrecondition(j.write, sha); err != nil {
+			plan.rejected[j] = err
+			continue
+		}
+
+		mode := modes[filePath]
+		if mode == "" || current[filePath] == "" {
+			// 新文件，或本批中删除后重新创建的文件
+			mode = modeFile
+		}
+		entry := github.TreeEntry{Path: filePath, Mode: mode, Type: "blob"}
+		if j.write.Delete {
+			current[filePath] = ""
+		} else {
+			if j.blobSHA == "" {
+				content, err := base64.StdEncoding.DecodeString(j.write.Content)
+				if err == nil {
+					j.size = len(content)
+					j.blobSHA, err = gh.CreateBlob(ctx, target.Owner, target.Repo, content)
+				}
+				if err != nil {
+					plan.rejected[j] = err
+					continue
+				}
+			}
+			entry.SHA = &j.blobSHA
+			current[filePath] = j.blobSHA
+		}
+
+		plan.accepted = append(plan.accepted, j)
+		if i, ok := entries[filePath]; ok {
+			plan.entries[i] = entry
+		} else {
+			entries[filePath] = len(plan.entries)
+			plan.entries = append(plan.entries, entry)
+		}
+	}
+
+	// 本批中创建后又删除的文件不在基准树中，不能作为删除条目
+	kept := plan.entries[:0]
+	for _, entry := range plan.entries {
+		if entry.SHA != nil || base[entry.Path] != "" {
+			kept = append(kept, entry)
+		}
+	}
+	plan.entries = kept
+	return plan
+}
+
+// 新文件的树条目模式
+const modeFile = "100644"
+
+// baseTree 按目录读取并缓存基准树，只读取写入路径经过的目录，不展开整个仓库
+type baseTree struct {
+	gh          *github.Client
+	owner, repo string
+	root        string
+	// dirs 目录路径到其中条目的映射，根目录为空字符串，值为 nil 表示目录不存在
+	dirs map[string]map[string]github.TreeEntry
+}
+
+// lookup 返回路径在基准树中的条目，不存在时 found 为 false
+func (t *baseTree) lookup(ctx context.Context, filePath string) (entry github.TreeEntry, found bool, err error) {
+	dir, name := path.Split(filePath)
+	entries, err := t.dir(ctx, strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return github.TreeEntry{}, false, err
+	}
+	entry, found = entries[name]
+	return entry, found, nil
+}
+
+// dir 返回目录中的条目，目录不存在或不是目录时返回 nil
+func (t *baseTree) dir(ctx context.Context, dir string) (map[string]github.TreeEntry, error) {
+	if entries, ok := t.dirs[dir]; ok {
+		return entries, nil
+	}
+
+	sha := t.root
+	if dir != "" {
+		parent, name := path.Split(dir)
+		parentEntries, err := t.dir(ctx, strings.TrimSuffix(parent, "/"))
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := parentEntries[name]
+		if !ok || entry.Type != "tree" {
+			t.dirs[dir] = nil
+			return nil, nil
+		}
+		sha = *entry.SHA
+	}
+
+	tree, err := t.gh.GetTree(ctx, t.owner, t.repo, sha, false)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]github.TreeEntry, len(tree.Tree))
+	for _, entry := range tree.Tree {
+		entries[entry.Path] = entry
+	}
+	t.dirs[dir] = entries
+	return entries, nil
+}
+
+// createCommit 创建包含计划中所有写入的提交
+func (q *Queue) createCommit(ctx context.Context, gh *github.Client, target Target, baseTree, parent string, plan *batchPlan) (*github.GitCommit, error) {
+	treeSHA := baseTree
+	if len(plan.entries) > 0 {
+		tree, err := gh.CreateTree(ctx, target.Owner, target.Repo, baseTree, plan.entries)
+		if err != nil {
+			return nil, err
+		}
+		treeSHA = tree.SHA
+	}
+	return gh.CreateGitCommit(ctx, target.Owner, target.Repo, batchMessage(plan.accepted), treeSHA, []string{parent})
+}
+
+// batchMessage 合并提交的说明，单个写入时沿用它的说明
+func batchMessage(jobs []*job) string {
+	if len(jobs) == 1 {
+		return jobs[0].write.Message
+	}
+	lines := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		lines = append(lines, fmt.Sprintf("- %s: %s", j.write.Path, firstLine(j.write.Message)))
+	}
+	return fmt.Sprintf("Update %d files\n\n%s", len(jobs), strings.Join(lines, "\n"))
+}
+
+// firstLine 返回提交说明的第一行
+func firstLine(message string) string {
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		return message[:i]
+	}
+	return message
+}
+
+// CheckPrecondition 检查写入的前置条件，current 为文件的当前 SHA，空字符串表示不存在
+func CheckPrecondition(write Write, current string) error {
+	if write.Delete {
+		if current == "" {
+			return ErrFileNotFound
+		}
+		if current != write.ExpectedSHA {
+			return &ConflictError{Path: write.Path, CurrentSHA: current, ExpectedSHA: write.ExpectedSHA}
+		}
+		return nil
+	}
+	if write.ExpectedSHA != "" && current != write.ExpectedSHA {
+		return &ConflictError{Path: write.Path, CurrentSHA: current, ExpectedSHA: write.ExpectedSHA}
+	}
+	if write.ExpectedSHA == "" && !write.Overwrite && current != "" {
+		return &ConflictError{Path: write.Path, CurrentSHA: current}
+	}
+	return nil
+}
+
+// finishAll 以相同的结果结束所有写入
+func finishAll(jobs []*job, result *Result, err error) {
+	for _, j := range jobs {
+		j.finish(result, err)
+	}
+}
+
+// isRefConflict 检查错误是否因分支在提交期间被其他客户端更新
+// contents 接口返回 409 "is at X but expected Y"，更新引用返回 422 "Update is not a fast forward"
+func isRefConflict(err error) bool {
+	var apiErr *github.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	message := strings.ToLower(apiErr.Message)
+	switch apiErr.StatusCode {
+	case http.StatusConflict:
+		return strings.Contains(message, "but expected")
+	case http.StatusUnprocessableEntity:
+		return strings.Contains(message, "fast forward")
+	}
+	return false
+}
+
+// isPreconditionFailure 检查写入是否因文件的当前版本与 sha 不符而被拒绝，权限、校验和网络错误原样返回
+// 各后端把版本冲突归类为 ErrConflict，GitHub 对未带 sha 覆盖已有文件返回 422 "sha" wasn't supplied
+func isPreconditionFailure(err error) bool {
+	if isRefConflict(err) {
+		return false
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		return true
+	}
+	var apiErr *github.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity &&
+		strings.Contains(apiErr.Message, `"sha"`)
+}
+
+// isMissingRef 检查分支是否不存在或仓库为空，这时只能通过 contents 接口创建第一个提交
+func isMissingRef(err error) bool {
+	var apiErr *github.APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusConflict)
+}
+
+// sleepBeforeRetry 重试前随机等待一段时间，避免多个服务器同时重试再次冲突
+func sleepBeforeRetry(ctx context.Context, attempt int) error {
+	delay := time.Duration(rand.Int63n(int64(100*time.Millisecond) * int64(attempt+1)))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,258 @@
+package writequeue
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/storage"
+)
+
+// testTarget 假服务器上的 main 分支
+var testTarget = Target{Owner: "octo", Repo: "drive", Branch: "main"}
+
+// newWrite 创建写入 content 的请求
+func newWrite(filePath, content string) Write {
+	return Write{
+		Path:      filePath,
+		Content:   base64.StdEncoding.EncodeToString([]byte(content)),
+		Message:   "update " + filePath,
+		Overwrite: true,
+	}
+}
+
+// pending 返回分支队列中等待执行的写入数
+func (q *Queue) pending(target Target) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	l, ok := q.lanes[laneKey{owner: target.Owner, repo: target.Repo, branch: target.Branch}]
+	if !ok {
+		return -1
+	}
+	return len(l.pending)
+}
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrentWritesShareOneCommit(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{"README.md": "hello"})
+	gh := f.client()
+	q := New(DefaultConfig())
+
+	// 暂停假服务器，让后续写入在第一个写入执行期间排队
+	f.mu.Lock()
+	first := make(chan error, 1)
+	go func() {
+		_, err := q.Submit(context.Background(), gh, testTarget, "alice", newWrite("first.txt", "first"))
+		first <- err
+	}()
+	waitFor(t, "first write to start", func() bool { return q.pending(testTarget) == 0 })
+
+	const n = 5
+	var wg sync.WaitGroup
+	results := make([]*Result, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = q.Submit(context.Background(), gh, testTarget, "alice", newWrite(fmt.Sprintf("docs/%d.txt", i), fmt.Sprint(i)))
+		}(i)
+	}
+	waitFor(t, "writes to queue", func() bool { return q.pending(testTarget) == n })
+	f.mu.Unlock()
+
+	if err := <-first; err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("write %d: %v", i, errs[i])
+		}
+		if results[i].Batched != n {
+			t.Errorf("write %d batched with %d writes, want %d", i, results[i].Batched, n)
+		}
+		if results[i].Commit != results[0].Commit {
+			t.Errorf("write %d in commit %s, want %s", i, results[i].Commit, results[0].Commit)
+		}
+		if content, _, ok := f.file("main", fmt.Sprintf("docs/%d.txt", i)); !ok || content != fmt.Sprint(i) {
+			t.Errorf("docs/%d.txt = %q, %v", i, content, ok)
+		}
+	}
+	if f.createdCommits != 1 {
+		t.Fatalf("created %d commits, want 1", f.createdCommits)
+	}
+}
+
+func TestBatchRetriesWhenBranchMoves(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{"README.md": "hello"})
+	gh := f.client()
+	q := New(DefaultConfig())
+
+	// 第一次更新引用前，其他客户端抢先提交了另一个文件
+	f.beforeUpdateRef = func(f *fakeGitHub) {
+		f.commitExternally("main", "other.txt", "external")
+	}
+
+	batch := []*job{
+		{ctx: context.Background(), backend: gh, identity: "alice", write: newWrite("a.txt", "a"), done: make(chan outcome, 1)},
+		{ctx: context.Background(), backend: gh, identity: "alice", write: newWrite("b.txt", "b"), done: make(chan outcome, 1)},
+	}
+	q.execute(testTarget, batch)
+
+	for _, j := range batch {
+		out := <-j.done
+		if out.err != nil {
+			t.Fatalf("%s: %v", j.write.Path, out.err)
+		}
+		if out.result.Commit != f.head("main") {
+			t.Errorf("%s committed in %s, branch at %s", j.write.Path, out.result.Commit, f.head("main"))
+		}
+	}
+	for filePath, want := range map[string]string{"a.txt": "a", "b.txt": "b", "other.txt": "external", "README.md": "hello"} {
+		if content, _, ok := f.file("main", filePath); !ok || content != want {
+			t.Errorf("%s = %q, %v, want %q", filePath, content, ok, want)
+		}
+	}
+	if f.createdCommits != 2 {
+		t.Fatalf("created %d commits, want 2 (one rejected, one retried)", f.createdCommits)
+	}
+}
+
+func TestBatchKeepsFileModes(t *testing.T) {
+	f := newFakeGitHub(t, map[string]string{"bin/run.sh": "#!/bin/sh\n"})
+	f.setMode("main", "bin/run.sh", "100755")
+	gh := f.client()
+	q := New(DefaultConfig())
+
+	batch := []*job{
+		{ctx: context.Background(), backend: gh, identity: "alice", write: newWrite("bin/run.sh", "#!/bin/sh\necho hi\n"), done: make(chan outcome, 1)},
+		{ctx: context.Background(), backend: gh, identity: "alice", write: newWrite("bin/new.txt", "new"), done: make(chan outcome, 1)},
+	}
+	q.execute(testTarget, batch)
+	for _, j := range batch {
+		if out := <-j.done; out.err != nil {
+			t.Fatalf("%s: %v", j.write.Path, out.err)
+		}
+	}
+
+	if content, mode, _ := f.file("main", "bin/run.sh"); mode != "100755" || content != "#!/bin/sh\necho hi\n" {
+		t.Errorf("bin/run.sh = %q mode %s, want mode 100755", content, mode)
+	}
+	if _, mode, _ := f.file("main", "bin/new.txt"); mode != modeFile {
+		t.Errorf("bin/new.txt mode %s, want %s", mode, modeFile)
+	}
+}
+
+// stubBackend 只实现写队列用到的文件接口，其他方法未实现
+type stubBackend struct {
+	storage.Backend
+	// writeErr CreateOrUpdateFile 返回的错误
+	writeErr error
+	// currentSHA GetFileSHA 返回的当前版本
+	currentSHA string
+	// release 不为 nil 时写入等待它关闭后才返回
+	release chan struct{}
+
+	mu       sync.Mutex
+	branches []string
+}
+
+func (s *stubBackend) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	return "main", nil
+}
+
+func (s *stubBackend) GetFileSHA(ctx context.Context, owner, repo, path, branch string) (string, error) {
+	return s.currentSHA, nil
+}
+
+func (s *stubBackend) CreateOrUpdateFile(ctx context.Context, owner, repo, path, content, message, branch, sha string) (*github.FileEntry, error) {
+	s.mu.Lock()
+	s.branches = append(s.branches, branch)
+	s.mu.Unlock()
+	if s.release != nil {
+		<-s.release
+	}
+	if s.writeErr != nil {
+		return nil, s.writeErr
+	}
+	return &github.FileEntry{Path: path, SHA: "new"}, nil
+}
+
+func TestWriteErrorsPassThrough(t *testing.T) {
+	network := errors.New("connection reset by peer")
+	tests := []struct {
+		name         string
+		err          error
+		wantConflict bool
+	}{
+		{"forbidden", &github.APIError{StatusCode: http.StatusForbidden, Message: "Resource not accessible by integration"}, false},
+		{"validation", &github.APIError{StatusCode: http.StatusUnprocessableEntity, Message: "path contains a malformed path component"}, false},
+		{"network", network, false},
+		{"sha mismatch", &github.APIError{StatusCode: http.StatusConflict, Message: "a.txt does not match abc"}, true},
+		{"sha not supplied", &github.APIError{StatusCode: http.StatusUnprocessableEntity, Message: "Invalid request.\n\n\"sha\" wasn't supplied."}, true},
+		{"backend conflict", fmt.Errorf("%w: a.txt does not match abc", storage.ErrConflict), true},
+	}
+	for _, tt := range tests {
+		backend := &stubBackend{writeErr: tt.err, currentSHA: "other"}
+		write := newWrite("a.txt", "a")
+		write.ExpectedSHA = "abc"
+		_, err := New(DefaultConfig()).Submit(context.Background(), backend, testTarget, "alice", write)
+
+		var conflict *ConflictError
+		if got := errors.As(err, &conflict); got != tt.wantConflict {
+			t.Errorf("%s: err = %v, want conflict %v", tt.name, err, tt.wantConflict)
+			continue
+		}
+		if !tt.wantConflict && !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestDefaultBranchSharesLane(t *testing.T) {
+	backend := &stubBackend{release: make(chan struct{})}
+	q := New(DefaultConfig())
+	implicit := Target{Owner: "octo", Repo: "drive"}
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := q.Submit(context.Background(), backend, implicit, "alice", newWrite("first.txt", "first"))
+		errs <- err
+	}()
+	waitFor(t, "first write to start", func() bool { return q.pending(testTarget) == 0 })
+
+	go func() {
+		_, err := q.Submit(context.Background(), backend, testTarget, "bob", newWrite("second.txt", "second"))
+		errs <- err
+	}()
+	waitFor(t, "second write to queue behind the first", func() bool { return q.pending(testTarget) == 1 })
+
+	close(backend.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(backend.branches) != "[main main]" {
+		t.Fatalf("writes went to branches %v, want both on main", backend.branches)
+	}
+}
@@ -1,13 +1,30 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"git-net-disk/api"
+	"git-net-disk/internal/github"
+	"git-net-disk/internal/proxy"
+	"git-net-disk/internal/syncer"
 )
 
 func main() {
+	// 同步模式: main sync -dir ./Drive -repo owner/repo
+	if len(os.Args) > 1 && os.Args[1] == "sync" {
+		if err := runSync(os.Args[2:]); err != nil {
+			log.Fatalf("Sync failed: %v", err)
+		}
+		return
+	}
+
 	// 创建服务器
 	server := api.NewServer()
 
@@ -23,3 +40,79 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// runSync 运行本地目录双向同步守护进程
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dir := fs.String("dir", ".", "local directory to sync")
+	repoPath := fs.String("repo", "", "repository in owner/repo form")
+	subPath := fs.String("path", "", "directory inside the repository to sync (defaults to the repository root)")
+	proxyURL := fs.String("proxy", os.Getenv("SYNC_PROXY_URL"), "proxy URL (http://, socks5:// or trojan://), defaults to SYNC_PROXY_URL")
+	branch := fs.String("branch", "", "branch to sync (defaults to the repository default branch)")
+	interval := fs.Duration("interval", 30*time.Second, "interval between sync runs")
+	batchSize := fs.Int("batch", 100, "maximum number of files per commit")
+	once := fs.Bool("once", false, "run a single sync and exit")
+	fs.Parse(args)
+
+	owner, repo := github.ParseRepoPath(*repoPath)
+	if owner == "" {
+		return fmt.Errorf("invalid -repo %q, expected owner/repo", *repoPath)
+	}
+
+	proxyConfig, err := proxy.ParseProxyURL(*proxyURL)
+	if err != nil {
+		return err
+	}
+
+	// 优先使用 GITHUB_TOKEN，未设置时使用 GitHub App 安装身份
+	endpoints := github.EndpointsFromEnv()
+	app, err := github.AppAuthFromEnv(endpoints)
+	if err != nil {
+		return err
+	}
+
+	var client *github.Client
+	switch token := os.Getenv("GITHUB_TOKEN"); {
+	case token != "":
+		client, err = github.NewClientWithEndpoints(token, proxyConfig, endpoints)
+	case app != nil:
+		client, err = github.NewAppClient(app, proxyConfig, endpoints)
+	default:
+		return fmt.Errorf("GITHUB_TOKEN or GitHub App configuration (GITHUB_APP_ID) is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s, err := syncer.New(ctx, client, syncer.Config{
+		Dir:       *dir,
+		Owner:     owner,
+		Repo:      repo,
+		Branch:    *branch,
+		Path:      *subPath,
+		Interval:  *interval,
+		BatchSize: *batchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *once {
+		result, err := s.SyncOnce(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pushed: %d, pulled: %d, deleted: %d, conflicts: %d\n",
+			len(result.Pushed), len(result.Pulled), len(result.Deleted), len(result.Conflicts))
+		return nil
+	}
+
+	fmt.Printf("Syncing %s with %s/%s every %v\n", *dir, owner, repo, *interval)
+	if err := s.Run(ctx); err != nil && err != context.Canceled {
+		return err
+	}
+	return nil
+}
@@ -72,6 +72,9 @@ const RepoList: React.FC<RepoListProps> = ({ repos, selectedRepo, onSelectRepo,
                  {repo.language}
               </span>
               <span>{repo.updatedAt}</span>
+              {repo.sharedWithMe && (
+                <span className="px-1.5 py-0.5 rounded-md bg-blue-50 text-blue-500">与我共享</span>
+              )}
             </div>
           </div>
         ))}
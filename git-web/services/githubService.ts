@@ -96,7 +96,8 @@ export const githubService = {
       isPrivate: repo.private,
       language: repo.language || 'Text',
       htmlUrl: repo.html_url,
-      defaultBranch: repo.default_branch
+      defaultBranch: repo.default_branch,
+      sharedWithMe: repo.shared_with_me || false
     }));
   },
 
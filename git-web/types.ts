@@ -13,6 +13,7 @@ export interface Repository {
   isPrivate: boolean;
   language: string;
   htmlUrl: string; // Add URL field
+  sharedWithMe?: boolean; // 他人共享给当前用户的仓库
 }
 
 export enum FileType {